@@ -0,0 +1,603 @@
+// Package fumen reads and writes fumen, the format that boards and sequences
+// of placements are shared in by the Tetris community, as text like
+// "v115@vhAAgH". Only version 115, which every current fumen editor writes,
+// is supported. A fumen is a list of pages, each of which shows a board and
+// usually a piece placed on it, and the board of each page follows from the
+// one before by placing its piece and clearing any lines that it completes.
+package fumen
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+
+	"github.com/omustardo/tetris/glfw-tetris/tetronimoes"
+)
+
+// Fumen boards are Width columns by Height rows, with an extra row below them
+// holding garbage that can be raised into the board.
+const (
+	Width  = 10
+	Height = 23
+)
+
+// Characters for the cells of boards, as in puzzle files and
+// gamestate.State.Rows. Fumen's gray blocks are garbage, and plain blocks are
+// written as gray.
+const (
+	emptyCell   = '.'
+	plainCell   = 'X'
+	garbageCell = 'G'
+)
+
+// Page is one page of a fumen.
+type Page struct {
+	// Board holds the rows of the board, top first, using the characters of
+	// puzzle files. Decoded boards leave out the empty rows above the highest
+	// block, and boards being encoded fill the bottom of the board.
+	Board []string
+	// Garbage is the row below the board, or empty if there's nothing there.
+	Garbage string
+	// Piece is the piece placed on the board, if there is one.
+	Piece *Placement
+	// Comment is the text shown with the page. Pages without a comment of
+	// their own show the one before's, so it's filled in from there when
+	// decoding and only written when it changes when encoding.
+	Comment string
+	// Lock places the piece before the next page, clearing any lines that it
+	// completes, and then raises the garbage row into the board if Rise is
+	// set and flips the board from left to right if Mirror is. Otherwise the
+	// next page carries on from this one's board as it is.
+	Lock         bool
+	Rise, Mirror bool
+}
+
+// Placement is where a piece is placed: the number of times it's been rotated
+// clockwise from how fumen spawns it, flat side down, from 0 to 3, and the
+// position of its center on the board, counting from the bottom left. The
+// center is the one fumen uses, which is that of the Super Rotation System
+// except for the O, I, S and Z pieces. See Cells.
+type Placement struct {
+	Kind     tetronimoes.Kind
+	Rotation int
+	X, Y     int
+}
+
+// offsets holds the cells of each kind of piece around its center, as fumen
+// spawns it, before fumen's adjustments to the center. The pieces called S and
+// Z here are shaped like fumen's Z and S; see blockKinds.
+var offsets = map[tetronimoes.Kind][4][2]int{
+	tetronimoes.I: {{0, 0}, {-1, 0}, {1, 0}, {2, 0}},
+	tetronimoes.T: {{0, 0}, {-1, 0}, {1, 0}, {0, 1}},
+	tetronimoes.O: {{0, 0}, {1, 0}, {0, 1}, {1, 1}},
+	tetronimoes.L: {{0, 0}, {-1, 0}, {1, 0}, {1, 1}},
+	tetronimoes.J: {{0, 0}, {-1, 0}, {1, 0}, {-1, 1}},
+	tetronimoes.S: {{0, 0}, {1, 0}, {0, 1}, {-1, 1}},
+	tetronimoes.Z: {{0, 0}, {-1, 0}, {0, 1}, {1, 1}},
+}
+
+// Cells returns the cells of the board covered by the piece, each as its
+// column and row counted from the bottom left. It returns nil for pieces of
+// an unknown kind.
+func (p Placement) Cells() [][2]int {
+	cells, ok := offsets[p.Kind]
+	if !ok {
+		return nil
+	}
+	var rotated [][2]int
+	for _, c := range cells {
+		x, y := c[0], c[1]
+		for i := 0; i < (p.Rotation%4+4)%4; i++ {
+			x, y = y, -x
+		}
+		rotated = append(rotated, [2]int{p.X + x, p.Y + y})
+	}
+	return rotated
+}
+
+// PlacementOf returns the placement of a piece of the given kind that covers
+// the given cells, if there is one. When more than one placement covers the
+// same cells, like an O turned any way, the one rotated the least is used.
+func PlacementOf(kind tetronimoes.Kind, cells [][2]int) (Placement, bool) {
+	if _, ok := offsets[kind]; !ok || len(cells) != 4 {
+		return Placement{}, false
+	}
+	want := make(map[[2]int]bool)
+	for _, c := range cells {
+		want[c] = true
+	}
+	for rotation := 0; rotation < 4; rotation++ {
+		// The center is one of the piece's cells, so each choice of the
+		// first cell pins down where it would have to be.
+		p := Placement{Kind: kind, Rotation: rotation}
+		first := p.Cells()[0]
+		for _, c := range cells {
+			p.X, p.Y = c[0]-first[0], c[1]-first[1]
+			covered := 0
+			for _, c := range p.Cells() {
+				if want[c] {
+					covered++
+				}
+			}
+			if covered == 4 {
+				return p, true
+			}
+		}
+	}
+	return Placement{}, false
+}
+
+// Fumen numbers each kind of block. The pieces that this game calls S and Z
+// are shaped like the ones that fumen calls Z and S, so their letters are
+// swapped, to keep boards and pieces looking the same as in fumen editors.
+var blockKinds = []byte{emptyCell, 'I', 'L', 'O', 'S', 'T', 'J', 'Z', garbageCell}
+
+func blockNumber(c byte) (int, error) {
+	if c == plainCell {
+		c = garbageCell
+	}
+	for n, kind := range blockKinds {
+		if kind == c {
+			return n, nil
+		}
+	}
+	return 0, fmt.Errorf("unknown cell %q", c)
+}
+
+// Fumen numbers rotations counter-clockwise from upside down, rather than
+// clockwise from how pieces spawn.
+var rotationNumbers = []int{2, 1, 0, 3}
+
+// field is a board with the garbage row below it, as block numbers, with
+// row 0 the top row and the garbage row last.
+type field [Height + 1][Width]int
+
+// cellCount is the number of cells in a field.
+const cellCount = (Height + 1) * Width
+
+// newField makes a field from a page's rows.
+func newField(board []string, garbage string) (*field, error) {
+	if len(board) > Height {
+		return nil, fmt.Errorf("board has %d rows, but fumen only has room for %d", len(board), Height)
+	}
+	f := &field{}
+	rows := append(append([]string(nil), board...), garbage)
+	for i, line := range rows {
+		row := Height - len(board) + i
+		if line == "" && i == len(board) {
+			continue
+		}
+		if len(line) != Width {
+			return nil, fmt.Errorf("board row %q is %d wide, but fumen boards are %d", line, len(line), Width)
+		}
+		for col := 0; col < Width; col++ {
+			n, err := blockNumber(line[col])
+			if err != nil {
+				return nil, err
+			}
+			f[row][col] = n
+		}
+	}
+	return f, nil
+}
+
+// rows returns the rows of the board, leaving out empty rows at the top, and
+// the garbage row.
+func (f *field) rows() (board []string, garbage string) {
+	text := func(row int) string {
+		line := make([]byte, Width)
+		for col, n := range f[row] {
+			line[col] = blockKinds[n]
+		}
+		return string(line)
+	}
+	top := 0
+	for top < Height && f.empty(top) {
+		top++
+	}
+	for row := top; row < Height; row++ {
+		board = append(board, text(row))
+	}
+	if !f.empty(Height) {
+		garbage = text(Height)
+	}
+	return board, garbage
+}
+
+func (f *field) empty(row int) bool {
+	for _, n := range f[row] {
+		if n != 0 {
+			return false
+		}
+	}
+	return true
+}
+
+// place carries out a page's lock: placing its piece, clearing lines, and
+// then raising the garbage and mirroring the board as it asks.
+func (f *field) place(p *Page) {
+	if p.Piece != nil {
+		n, _ := blockNumber(byte(p.Piece.Kind))
+		for _, c := range p.Piece.Cells() {
+			if row := Height - 1 - c[1]; row >= 0 && row < Height && c[0] >= 0 && c[0] < Width {
+				f[row][c[0]] = n
+			}
+		}
+	}
+	// Clear full rows from the bottom up, shifting the rest down. The garbage
+	// row is never cleared.
+	for row := Height - 1; row >= 0; {
+		full := true
+		for _, n := range f[row] {
+			full = full && n != 0
+		}
+		if !full {
+			row--
+			continue
+		}
+		copy(f[1:row+1], f[:row])
+		f[0] = [Width]int{}
+	}
+	if p.Rise {
+		copy(f[:Height-1], f[1:Height])
+		f[Height-1] = f[Height]
+		f[Height] = [Width]int{}
+	}
+	if p.Mirror {
+		for row := 0; row < Height; row++ {
+			for l, r := 0, Width-1; l < r; l, r = l+1, r-1 {
+				f[row][l], f[row][r] = f[row][r], f[row][l]
+			}
+		}
+	}
+}
+
+// The characters of fumen data, each standing for a number from 0 to 63.
+const alphabet = "ABCDEFGHIJKLMNOPQRSTUVWXYZabcdefghijklmnopqrstuvwxyz0123456789+/"
+
+// The characters that comments are made of, once escaped, numbered from 0.
+// Comments are written four characters at a time, as a number with a digit
+// for each character, in base one more than the number of characters.
+const commentAlphabet = " !\"#$%&'()*+,-./0123456789:;<=>?@ABCDEFGHIJKLMNOPQRSTUVWXYZ[\\]^_`abcdefghijklmnopqrstuvwxyz{|}~"
+
+// The longest comment that fits, once escaped.
+const maxComment = 4095
+
+const prefix = "115@"
+
+// Each page is written as:
+//
+//	the difference between its board and the one before's, after the one
+//	  before's lock, as runs of cells from the top left to the bottom right,
+//	  each as two characters holding the difference plus 8 times the number
+//	  of cells, plus the length of the run minus one. A page whose board is
+//	  unchanged is followed by a character counting the pages after it whose
+//	  boards are also unchanged, which are left out of those pages.
+//	the piece, its rotation and its position, with flags for rising,
+//	  mirroring, color, whether there's a comment and not locking, all in
+//	  three characters
+//	the comment, if flagged: its length in two characters and then each four
+//	  of its characters in five
+//
+// Numbers of more than one character are written with the least significant
+// character first.
+
+// Decode reads the pages of a fumen. Anything before the version, like the
+// address of a fumen viewer, is ignored, as are the question marks that long
+// fumens are broken up with.
+func Decode(text string) ([]*Page, error) {
+	i := strings.Index(text, prefix)
+	if i < 1 || !strings.ContainsRune("vmd", rune(text[i-1])) {
+		if strings.Contains(text, "110@") {
+			return nil, errors.New("only version 115 of fumen is supported, not 110")
+		}
+		return nil, errors.New("not a fumen")
+	}
+	d := &decoder{data: strings.Map(func(c rune) rune {
+		if c == '?' || c == ' ' || c == '\n' || c == '\r' || c == '\t' {
+			return -1
+		}
+		return c
+	}, text[i+len(prefix):])}
+
+	var pages []*Page
+	prev := &field{}
+	repeat := 0
+	comment := ""
+	for d.more() {
+		f := *prev
+		if repeat > 0 {
+			repeat--
+		} else {
+			unchanged := false
+			for i := 0; i < cellCount && d.err == nil; {
+				run := d.number(2)
+				diff, length := run/cellCount-8, run%cellCount+1
+				unchanged = diff == 0 && length == cellCount
+				if i+length > cellCount {
+					d.fail("cells run off the end of the board")
+				}
+				for end := i + length; i < end && d.err == nil; i++ {
+					n := &f[i/Width][i%Width]
+					*n += diff
+					if *n < 0 || *n >= len(blockKinds) {
+						d.fail("unknown block %d", *n)
+					}
+				}
+			}
+			if unchanged {
+				repeat = d.number(1)
+			}
+		}
+
+		action := d.number(3)
+		p := &Page{}
+		kind := action % 8
+		action /= 8
+		rotation := action % 4
+		action /= 4
+		position := action % cellCount
+		action /= cellCount
+		p.Rise = action&1 != 0
+		p.Mirror = action&2 != 0
+		// action&4 is whether the page is colored in, which every page is
+		// here.
+		hasComment := action&8 != 0
+		p.Lock = action&16 == 0
+		if kind != 0 {
+			p.Piece = &Placement{Kind: tetronimoes.Kind(blockKinds[kind])}
+			if kind == 8 {
+				d.fail("gray piece placed")
+			}
+			for r, n := range rotationNumbers {
+				if n == rotation {
+					p.Piece.Rotation = r
+				}
+			}
+			p.Piece.X = position % Width
+			p.Piece.Y = Height - 1 - position/Width
+			adjust(p.Piece, -1)
+		}
+
+		if hasComment {
+			length := d.number(2)
+			var escaped []byte
+			for i := 0; i < (length+3)/4; i++ {
+				n := d.number(5)
+				for j := 0; j < 4; j++ {
+					c := byte(' ')
+					if i := n % (len(commentAlphabet) + 1); i < len(commentAlphabet) {
+						c = commentAlphabet[i]
+					}
+					escaped = append(escaped, c)
+					n /= len(commentAlphabet) + 1
+				}
+			}
+			if length <= len(escaped) {
+				comment = unescape(string(escaped[:length]))
+			}
+		}
+		p.Comment = comment
+		if d.err != nil {
+			return nil, fmt.Errorf("bad fumen page %d: %v", len(pages)+1, d.err)
+		}
+		p.Board, p.Garbage = f.rows()
+		pages = append(pages, p)
+
+		*prev = f
+		if p.Lock {
+			prev.place(p)
+		}
+	}
+	if len(pages) == 0 {
+		return nil, errors.New("fumen has no pages")
+	}
+	return pages, nil
+}
+
+// adjust moves a placement's center between where fumen writes it and where
+// it is, in the direction of sign: -1 when reading and 1 when writing. For
+// the pieces whose rotations share a center in the Super Rotation System
+// fumen writes the center of another rotation.
+func adjust(p *Placement, sign int) {
+	dx, dy := 0, 0
+	switch {
+	case p.Kind == tetronimoes.O && p.Rotation == 3:
+		dx, dy = 1, -1
+	case p.Kind == tetronimoes.O && p.Rotation == 2:
+		dx = 1
+	case p.Kind == tetronimoes.O && p.Rotation == 0:
+		dy = -1
+	case p.Kind == tetronimoes.I && p.Rotation == 2:
+		dx = 1
+	case p.Kind == tetronimoes.I && p.Rotation == 3:
+		dy = -1
+	case (p.Kind == tetronimoes.S || p.Kind == tetronimoes.Z) && p.Rotation == 0:
+		dy = -1
+	case p.Kind == tetronimoes.Z && p.Rotation == 1:
+		dx = -1
+	case p.Kind == tetronimoes.S && p.Rotation == 3:
+		dx = 1
+	}
+	p.X -= sign * dx
+	p.Y -= sign * dy
+}
+
+// Encode writes pages as a fumen, like "v115@vhAAgH". Boards must be Width
+// wide and at most Height high.
+func Encode(pages []*Page) (string, error) {
+	e := &encoder{}
+	prev := &field{}
+	// repeat is the index in e.data of the count of unchanged boards being
+	// added to, or -1.
+	repeat := -1
+	comment := ""
+	for i, p := range pages {
+		f, err := newField(p.Board, p.Garbage)
+		if err != nil {
+			return "", fmt.Errorf("page %d: %v", i+1, err)
+		}
+
+		unchanged := *f == *prev
+		switch {
+		case !unchanged:
+			// Write each run of cells with the same difference.
+			run, diff := 0, 0
+			for i := 0; i <= cellCount; i++ {
+				d := 0
+				if i < cellCount {
+					d = f[i/Width][i%Width] - prev[i/Width][i%Width]
+				}
+				if i > 0 && (i == cellCount || d != diff) {
+					e.number((diff+8)*cellCount+run-1, 2)
+					run = 0
+				}
+				diff = d
+				run++
+			}
+			repeat = -1
+		case repeat < 0 || e.data[repeat] == len(alphabet)-1:
+			e.number(8*cellCount+cellCount-1, 2)
+			e.number(0, 1)
+			repeat = len(e.data) - 1
+		default:
+			e.data[repeat]++
+		}
+
+		action := 0
+		position := 0
+		rotation := 0
+		if p.Piece != nil {
+			n, err := blockNumber(byte(p.Piece.Kind))
+			if err != nil || n == 0 || n == 8 {
+				return "", fmt.Errorf("page %d: unknown piece %q", i+1, p.Piece.Kind)
+			}
+			piece := *p.Piece
+			piece.Rotation = (piece.Rotation%4 + 4) % 4
+			adjust(&piece, 1)
+			if piece.X < 0 || piece.X >= Width || piece.Y < 0 || piece.Y >= Height {
+				return "", fmt.Errorf("page %d: piece at %d,%d is off the board", i+1, p.Piece.X, p.Piece.Y)
+			}
+			action = n
+			rotation = rotationNumbers[piece.Rotation]
+			position = (Height-1-piece.Y)*Width + piece.X
+		}
+		escaped := escape(p.Comment)
+		if len(escaped) > maxComment {
+			escaped = escaped[:maxComment]
+		}
+		hasComment := p.Comment != comment
+		flags := 0
+		if p.Rise {
+			flags |= 1
+		}
+		if p.Mirror {
+			flags |= 2
+		}
+		if i == 0 {
+			flags |= 4
+		}
+		if hasComment {
+			flags |= 8
+		}
+		if !p.Lock {
+			flags |= 16
+		}
+		action += 8 * (rotation + 4*(position+cellCount*flags))
+		e.number(action, 3)
+
+		if hasComment {
+			e.number(len(escaped), 2)
+			for i := 0; i < len(escaped); i += 4 {
+				n, scale := 0, 1
+				for j := i; j < i+4; j++ {
+					if j < len(escaped) {
+						n += strings.IndexByte(commentAlphabet, escaped[j]) * scale
+					}
+					scale *= len(commentAlphabet) + 1
+				}
+				e.number(n, 5)
+			}
+			comment = p.Comment
+		}
+
+		*prev = *f
+		if p.Lock {
+			prev.place(p)
+		}
+	}
+
+	// Long fumens are broken up with question marks, as fumen editors do.
+	data := make([]byte, len(e.data))
+	for i, n := range e.data {
+		data[i] = alphabet[n]
+	}
+	text := "v" + prefix
+	for len(data) > 0 {
+		n := 47
+		if text == "v"+prefix {
+			n = 42
+		} else {
+			text += "?"
+		}
+		if n > len(data) {
+			n = len(data)
+		}
+		text += string(data[:n])
+		data = data[n:]
+	}
+	return text, nil
+}
+
+// decoder reads numbers from fumen data. After the first error it reads only
+// zeros, and the error is kept in err.
+type decoder struct {
+	data string
+	err  error
+}
+
+func (d *decoder) more() bool {
+	return d.err == nil && len(d.data) > 0
+}
+
+func (d *decoder) fail(format string, args ...interface{}) {
+	if d.err == nil {
+		d.err = fmt.Errorf(format, args...)
+	}
+}
+
+// number reads a number written in the given number of characters.
+func (d *decoder) number(chars int) int {
+	if d.err != nil {
+		return 0
+	}
+	if len(d.data) < chars {
+		d.fail("fumen ends partway through a page")
+		return 0
+	}
+	n, scale := 0, 1
+	for i := 0; i < chars; i++ {
+		digit := strings.IndexByte(alphabet, d.data[i])
+		if digit < 0 {
+			d.fail("unexpected %q", d.data[i])
+			return 0
+		}
+		n += digit * scale
+		scale *= len(alphabet)
+	}
+	d.data = d.data[chars:]
+	return n
+}
+
+// encoder collects the digits of fumen data.
+type encoder struct {
+	data []int
+}
+
+// number writes a number in the given number of characters.
+func (e *encoder) number(n, chars int) {
+	for i := 0; i < chars; i++ {
+		e.data = append(e.data, n%len(alphabet))
+		n /= len(alphabet)
+	}
+}
@@ -0,0 +1,97 @@
+package fumen
+
+import (
+	"fmt"
+	"reflect"
+	"testing"
+
+	"github.com/omustardo/tetris/glfw-tetris/tetronimoes"
+)
+
+func TestDecodeEncode(t *testing.T) {
+	tests := []struct {
+		text  string
+		pages []*Page
+	}{
+		// An empty board.
+		{"v115@vhAAgH", []*Page{{Lock: true}}},
+		// An I lying flat in the bottom left corner.
+		{"v115@vhAxOJ", []*Page{{Piece: &Placement{Kind: tetronimoes.I, X: 1, Y: 0}, Lock: true}}},
+	}
+	for _, test := range tests {
+		pages, err := Decode(test.text)
+		if err != nil {
+			t.Errorf("%s: %v", test.text, err)
+			continue
+		}
+		if !reflect.DeepEqual(pages, test.pages) {
+			t.Errorf("%s: decoded %s, want %s", test.text, describe(pages), describe(test.pages))
+		}
+		if text, err := Encode(test.pages); err != nil || text != test.text {
+			t.Errorf("encoded %s as %s, %v, want %s", describe(test.pages), text, err, test.text)
+		}
+	}
+}
+
+func TestRoundTrip(t *testing.T) {
+	pages := []*Page{
+		{
+			Board:   []string{"L.........", "LLL.OO....", "GGGG.GGGGG"},
+			Garbage: "GGGGGGGGG.",
+			Piece:   &Placement{Kind: tetronimoes.I, Rotation: 1, X: 4, Y: 1},
+			Comment: "rise & shine: 100%",
+			Lock:    true,
+			Rise:    true,
+		},
+		{
+			// The comment carries on from the page before.
+			Board:   []string{"....I.....", "L...I.....", "LLL.IOO...", "GGGGIGGGG."},
+			Piece:   &Placement{Kind: tetronimoes.T, Rotation: 2, X: 2, Y: 2},
+			Comment: "rise & shine: 100%",
+			Lock:    true,
+			Mirror:  true,
+		},
+		{
+			// Pieces can be shown without being placed.
+			Board:   []string{"....G....."},
+			Piece:   &Placement{Kind: tetronimoes.S, Rotation: 3, X: 7, Y: 5},
+			Comment: "ノート",
+		},
+		{
+			Board:   []string{"....G....."},
+			Comment: "",
+			Lock:    true,
+		},
+	}
+	text, err := Encode(pages)
+	if err != nil {
+		t.Fatal(err)
+	}
+	decoded, err := Decode(text)
+	if err != nil {
+		t.Fatalf("%s: %v", text, err)
+	}
+	if !reflect.DeepEqual(decoded, pages) {
+		t.Errorf("encoded %s as %s, which decoded as %s", describe(pages), text, describe(decoded))
+	}
+}
+
+func TestDecodeErrors(t *testing.T) {
+	for _, text := range []string{"", "hello", "v115@", "v115@vh", "v115@!!!", "v110@vhAAgH"} {
+		if pages, err := Decode(text); err == nil {
+			t.Errorf("decoded %q as %s", text, describe(pages))
+		}
+	}
+}
+
+// describe prints pages along with the pieces that they point to.
+func describe(pages []*Page) string {
+	s := ""
+	for _, p := range pages {
+		s += "\n" + fmt.Sprintf("%+v", *p)
+		if p.Piece != nil {
+			s += fmt.Sprintf(" %+v", *p.Piece)
+		}
+	}
+	return s
+}
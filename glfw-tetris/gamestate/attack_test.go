@@ -0,0 +1,161 @@
+package gamestate
+
+import (
+	"reflect"
+	"strings"
+	"testing"
+)
+
+// clear is a piece locking, clearing lines, with or without a T-spin.
+type clear struct {
+	lines int
+	tSpin bool
+}
+
+func TestAttackFor(t *testing.T) {
+	var (
+		miss    = clear{0, false}
+		single  = clear{1, false}
+		double  = clear{2, false}
+		triple  = clear{3, false}
+		tetris  = clear{4, false}
+		tSingle = clear{1, true}
+		tDouble = clear{2, true}
+		tTriple = clear{3, true}
+	)
+	tests := []struct {
+		name   string
+		clears []clear
+		want   []int
+	}{
+		{"lines", []clear{single, miss, double, miss, triple, miss, tetris}, []int{0, 0, 1, 0, 2, 0, 4}},
+		{"t-spins", []clear{tSingle, miss, tDouble, miss, tTriple}, []int{2, 0, 4 + 1, 0, 6 + 1}},
+		{"combo", []clear{single, single, single, single, single, single}, []int{0, 1, 1, 2, 2, 3}},
+		{"combo of doubles", []clear{double, double, double}, []int{1, 1 + 1, 1 + 1}},
+		{"combo broken", []clear{single, single, miss, single, single}, []int{0, 1, 0, 0, 1}},
+		{"back to back", []clear{tetris, miss, tetris, miss, tDouble}, []int{4, 0, 4 + 1, 0, 4 + 1}},
+		{"back to back broken", []clear{tetris, miss, double, miss, tetris}, []int{4, 0, 1, 0, 4}},
+		{"back to back kept by misses", []clear{tSingle, miss, miss, tetris}, []int{2, 0, 0, 4 + 1}},
+		{"back to back combo", []clear{tetris, tetris, tetris}, []int{4, 4 + 1 + 1, 4 + 1 + 1}},
+	}
+	for _, test := range tests {
+		s := NewState(Config{Width: 10, Height: 20})
+		// Leave something on the board, so that no clear is a perfect clear.
+		b, err := ParseBoard("GGGGGGGGG.")
+		if err != nil {
+			t.Fatal(err)
+		}
+		if err := s.SetBoard(b); err != nil {
+			t.Fatal(err)
+		}
+		var got []int
+		for _, c := range test.clears {
+			got = append(got, s.attackFor(c.lines, c.tSpin))
+		}
+		if !reflect.DeepEqual(got, test.want) {
+			t.Errorf("%s: sent %v, want %v", test.name, got, test.want)
+		}
+	}
+}
+
+func TestAttackForPerfectClear(t *testing.T) {
+	s := NewState(Config{Width: 10, Height: 20})
+	if got, want := s.attackFor(4, false), 4+DefaultAttackTable.PerfectClear; got != want {
+		t.Errorf("perfect clear tetris sent %d, want %d", got, want)
+	}
+}
+
+func TestCancel(t *testing.T) {
+	tests := []struct {
+		incoming []int
+		attack   int
+		sent     int
+		left     []int
+	}{
+		{nil, 4, 4, nil},
+		{[]int{2, 3}, 0, 0, []int{2, 3}},
+		{[]int{2, 3}, 1, 0, []int{1, 3}},
+		{[]int{2, 3}, 2, 0, []int{3}},
+		{[]int{2, 3}, 4, 0, []int{1}},
+		{[]int{2, 3}, 5, 0, nil},
+		{[]int{2, 3}, 7, 2, nil},
+	}
+	for _, test := range tests {
+		s := NewState(Config{Width: 10, Height: 20})
+		for _, rows := range test.incoming {
+			s.ReceiveGarbage(rows)
+		}
+		sent := s.cancel(test.attack)
+		if sent != test.sent || len(s.incoming) != len(test.left) || len(test.left) > 0 && !reflect.DeepEqual(s.incoming, test.left) {
+			t.Errorf("attacking with %d against %v sent %d and left %v, want %d and %v", test.attack, test.incoming, sent, s.incoming, test.sent, test.left)
+		}
+	}
+}
+
+func TestIncomingGarbage(t *testing.T) {
+	s := NewState(Config{Width: 6, Height: 6, BufferHeight: 2, Seed: 1})
+	s.SetAttack(Attack{Table: DefaultAttackTable, ChangeHole: 0})
+	b, err := ParseBoard(`
+		....oo
+		....oo
+		......
+		TTT...`)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := s.SetBoard(b); err != nil {
+		t.Fatal(err)
+	}
+	s.ReceiveGarbage(2)
+	s.ReceiveGarbage(1)
+	if got := s.IncomingGarbage(); got != 3 {
+		t.Fatalf("%d rows incoming, want 3", got)
+	}
+	if got := s.Board().Rows; got[len(got)-1] != "TTT..." {
+		t.Fatalf("garbage was pushed before a piece locked:\n%s", s.Board())
+	}
+
+	// Locking a piece without clearing pushes all of it, with the holes
+	// lined up.
+	s.Apply(Input{HardDrop: true})
+	if got := s.IncomingGarbage(); got != 0 {
+		t.Errorf("%d rows still incoming after locking", got)
+	}
+	rows := s.Board().Rows
+	bottom := rows[len(rows)-5:]
+	hole := strings.IndexByte(bottom[2], '.')
+	want := []string{"....OO", "TTT.OO"}
+	for _, row := range bottom[2:] {
+		if strings.Count(row, "G") != len(row)-1 || strings.IndexByte(row, '.') != hole {
+			t.Errorf("garbage rows aren't the same:\n%s", s.Board())
+			break
+		}
+	}
+	if !reflect.DeepEqual(bottom[:2], want) {
+		t.Errorf("board wasn't raised by the garbage:\n%s", s.Board())
+	}
+}
+
+func TestChangeHole(t *testing.T) {
+	const rows = 200
+	for _, chance := range []float64{0, 0.3, 1} {
+		s := NewState(Config{Width: 10, Height: rows, Seed: 7})
+		s.SetAttack(Attack{Table: DefaultAttackTable, ChangeHole: chance})
+		for i := 0; i < rows; i += 10 {
+			s.ReceiveGarbage(10)
+		}
+		s.pushIncoming()
+		changes := 0
+		board := s.Board().Rows
+		for i := 1; i < len(board); i++ {
+			if strings.IndexByte(board[i], '.') != strings.IndexByte(board[i-1], '.') {
+				changes++
+			}
+		}
+		// The chance is of the hole moving, so it's never kept when it moves.
+		got := float64(changes) / float64(rows-1)
+		if got < chance-0.1 || got > chance+0.1 {
+			t.Errorf("with a chance of %v, the hole moved between %d of %d rows", chance, changes, rows-1)
+		}
+	}
+}
@@ -0,0 +1,209 @@
+package gamestate
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+
+	"github.com/omustardo/tetris/glfw-tetris/tetronimoes"
+)
+
+// Board is a board written out as text, like this one with an L falling
+// towards a gap next to some garbage:
+//
+//	....l.....
+//	....l.....
+//	....ll....
+//	..........
+//	TTT....OO.
+//	GGGG.GGGGG
+//
+// Each row is a line of text, top row first, using the characters of puzzle
+// files: '.' for empty cells, 'G' for garbage, 'X' for other blocks and the
+// letter of a piece's kind for blocks that were part of one. The falling piece
+// is drawn over the board in lower case, so that it can be told apart from the
+// blocks under it and read back. The letters are those of tetronimoes.Kind,
+// whose S is shaped like the guideline Z and whose Z like the guideline S, so
+// boards copied from elsewhere need the two swapped. Boards print like this and
+// are read back by ParseBoard, so they can be logged, and written inline to set
+// up a game.
+type Board struct {
+	// Rows holds the rows of the board without the falling piece, top first.
+	Rows []string
+	// Piece is the kind of the falling piece, or 0 if there isn't one.
+	Piece tetronimoes.Kind
+	// PieceCells holds the cells covered by the falling piece, each as its
+	// column and row counted from the bottom left.
+	PieceCells [][2]int
+}
+
+// ParseBoard reads a board written like Board.String. Spaces around rows and
+// blank lines are ignored, so boards can be indented along with the code
+// around them.
+func ParseBoard(text string) (*Board, error) {
+	var lines []string
+	for _, line := range strings.Split(text, "\n") {
+		if line = strings.TrimSpace(line); line != "" {
+			lines = append(lines, line)
+		}
+	}
+	if len(lines) == 0 {
+		return nil, errors.New("board has no rows")
+	}
+	b := &Board{}
+	for i, line := range lines {
+		if len(line) != len(lines[0]) {
+			return nil, fmt.Errorf("board row %d has %d cells, expected %d", i+1, len(line), len(lines[0]))
+		}
+		row := []byte(line)
+		for col, c := range row {
+			if c < 'a' || c > 'z' {
+				if _, err := newBlock(c); err != nil {
+					return nil, fmt.Errorf("%v in board row %d", err, i+1)
+				}
+				continue
+			}
+			kind := tetronimoes.Kind(c - 'a' + 'A')
+			if tetronimoes.NewShape(kind) == nil {
+				return nil, fmt.Errorf("unknown piece %q in board row %d", c, i+1)
+			}
+			if b.Piece != 0 && b.Piece != kind {
+				return nil, fmt.Errorf("board has both %c and %c falling", b.Piece, kind)
+			}
+			b.Piece = kind
+			b.PieceCells = append(b.PieceCells, [2]int{col, len(lines) - 1 - i})
+			row[col] = emptyCell
+		}
+		b.Rows = append(b.Rows, string(row))
+	}
+	return b, nil
+}
+
+// String returns the board as text, with the falling piece drawn over it in
+// lower case, one row to a line.
+func (b *Board) String() string {
+	rows := append([]string(nil), b.Rows...)
+	width := 0
+	if len(rows) > 0 {
+		width = len(rows[0])
+	}
+	for _, c := range b.PieceCells {
+		// Pieces above the rows get rows of their own.
+		for c[1] >= len(rows) {
+			rows = append([]string{strings.Repeat(string(emptyCell), width)}, rows...)
+		}
+		i := len(rows) - 1 - c[1]
+		if c[0] < 0 || c[0] >= len(rows[i]) || c[1] < 0 {
+			continue
+		}
+		line := []byte(rows[i])
+		line[c[0]] = byte(b.Piece) - 'A' + 'a'
+		rows[i] = string(line)
+	}
+	return strings.Join(rows, "\n")
+}
+
+// Board returns the board with the falling piece. It holds the visible rows,
+// and the hidden rows above them too if there are blocks or the falling piece
+// in them.
+func (s *State) Board() *Board {
+	height := s.config.Height
+	for row := height; row < len(s.board); row++ {
+		for _, b := range s.board[row] {
+			if b != nil {
+				height = row + 1
+			}
+		}
+	}
+	b := &Board{}
+	b.Piece, b.PieceCells = s.PieceCells()
+	for _, c := range b.PieceCells {
+		if c[1] >= height {
+			height = c[1] + 1
+		}
+	}
+	if height > len(s.board) {
+		height = len(s.board)
+	}
+	for row := height - 1; row >= 0; row-- {
+		b.Rows = append(b.Rows, rowText(s.board[row]))
+	}
+	return b
+}
+
+// SetBoard replaces the board and the falling piece with those of b. The rows
+// fill the bottom of the board, and anything above them is cleared. If b has
+// no falling piece then the next one spawns as usual.
+func (s *State) SetBoard(b *Board) error {
+	if len(b.Rows) > len(s.board) {
+		return fmt.Errorf("board has %d rows, but there's only room for %d", len(b.Rows), len(s.board))
+	}
+	for i, row := range b.Rows {
+		if len(row) != s.config.Width {
+			return fmt.Errorf("board row %d has %d cells, expected %d", i+1, len(row), s.config.Width)
+		}
+		for col := 0; col < len(row); col++ {
+			if _, err := newBlock(row[col]); err != nil {
+				return fmt.Errorf("%v in board row %d", err, i+1)
+			}
+		}
+	}
+	var piece *tetronimoes.Shape
+	if b.Piece != 0 {
+		var err error
+		if piece, err = s.shapeCovering(b.Piece, b.PieceCells); err != nil {
+			return err
+		}
+	}
+	s.SetRows(b.Rows)
+	if piece != nil && s.BoardIntersects(piece) {
+		return fmt.Errorf("falling %c overlaps the board", b.Piece)
+	}
+	s.fallingPiece = piece
+	s.lockTimer = 0
+	s.lastRotated = false
+	if s.practice && piece != nil {
+		// Like a piece that spawns, so that undoing and reordering pieces
+		// treat it as the latest one.
+		s.remember()
+	}
+	return nil
+}
+
+// shapeCovering returns a shape of the given kind, rotated and moved to
+// cover exactly the given cells.
+func (s *State) shapeCovering(kind tetronimoes.Kind, cells [][2]int) (*tetronimoes.Shape, error) {
+	shape := tetronimoes.NewShape(kind)
+	if shape == nil {
+		return nil, fmt.Errorf("unknown piece %q", kind)
+	}
+	if len(cells) == 0 {
+		return nil, fmt.Errorf("falling %c covers no cells", kind)
+	}
+	want := make(map[cell]bool)
+	for _, c := range cells {
+		want[cell{col: c[0], row: c[1]}] = true
+	}
+	for r := 0; r < 4; r++ {
+		// Line up the lowest, leftmost cell of the shape with that of the
+		// cells, and see if the rest fit.
+		*shape.Origin() = tetronimoes.Point{}
+		covered := s.cells(shape)
+		first, lowest := covered[0], cell{col: cells[0][0], row: cells[0][1]}
+		for c := range want {
+			if c.row < lowest.row || c.row == lowest.row && c.col < lowest.col {
+				lowest = c
+			}
+		}
+		*shape.Origin() = tetronimoes.Point{X: float32(lowest.col - first.col), Y: float32(lowest.row - first.row)}
+		matched := len(covered) == len(want)
+		for _, c := range s.cells(shape) {
+			matched = matched && want[c]
+		}
+		if matched {
+			return shape, nil
+		}
+		shape.RotateClockwise()
+	}
+	return nil, fmt.Errorf("falling cells %v aren't the shape of a %c", cells, kind)
+}
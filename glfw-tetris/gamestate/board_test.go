@@ -0,0 +1,116 @@
+package gamestate
+
+import (
+	"reflect"
+	"testing"
+
+	"github.com/omustardo/tetris/glfw-tetris/tetronimoes"
+)
+
+func TestParseBoard(t *testing.T) {
+	b, err := ParseBoard(`
+		..l...
+		..l...
+		..ll..
+
+		TTT.OO
+		GG.GGG
+	`)
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := &Board{
+		Rows:       []string{"......", "......", "......", "TTT.OO", "GG.GGG"},
+		Piece:      tetronimoes.L,
+		PieceCells: [][2]int{{2, 4}, {2, 3}, {2, 2}, {3, 2}},
+	}
+	if !reflect.DeepEqual(b, want) {
+		t.Errorf("got %+v, want %+v", b, want)
+	}
+	if got, want := b.String(), "..l...\n..l...\n..ll..\nTTT.OO\nGG.GGG"; got != want {
+		t.Errorf("printed\n%s\nwant\n%s", got, want)
+	}
+}
+
+func TestBoardRoundTrip(t *testing.T) {
+	tests := []struct {
+		name   string
+		config Config
+		text   string
+	}{
+		{"empty", Config{Width: 4, Height: 4, BufferHeight: 2}, "....\n....\n....\n...."},
+		{"blocks", Config{Width: 4, Height: 4, BufferHeight: 2}, "....\n....\nX...\nGGG.\n"},
+		{"falling piece", Config{Width: 6, Height: 5, BufferHeight: 2}, "......\n.t....\ntt....\n.tJJ..\nGGJ.GG"},
+		{"falling piece in the buffer", Config{Width: 6, Height: 4, BufferHeight: 2}, "..zz..\n.zz...\n......\n......\nIIII..\nOO.ZZ."},
+		{"big", Config{Width: 8, Height: 8, BufferHeight: 4, Big: true}, "........\n........\n..oooo..\n..oooo..\n..oooo..\n..oooo..\nLLLL....\nLLLL..GG"},
+	}
+	for _, test := range tests {
+		b, err := ParseBoard(test.text)
+		if err != nil {
+			t.Errorf("%s: %v", test.name, err)
+			continue
+		}
+		s := NewState(test.config)
+		if err := s.SetBoard(b); err != nil {
+			t.Errorf("%s: %v", test.name, err)
+			continue
+		}
+		got := s.Board()
+		if !reflect.DeepEqual(got.Rows, b.Rows) || got.Piece != b.Piece || !sameCells(got.PieceCells, b.PieceCells) {
+			t.Errorf("%s: set\n%s\ngot back\n%s", test.name, b, got)
+		}
+		if reprinted, err := ParseBoard(got.String()); err != nil || reprinted.String() != got.String() {
+			t.Errorf("%s: reparsing\n%s\ngave\n%s, %v", test.name, got, reprinted, err)
+		}
+	}
+}
+
+// sameCells returns whether a and b hold the same cells, in any order.
+func sameCells(a, b [][2]int) bool {
+	in := make(map[[2]int]bool)
+	for _, c := range a {
+		in[c] = true
+	}
+	for _, c := range b {
+		if !in[c] {
+			return false
+		}
+	}
+	return len(a) == len(b)
+}
+
+func TestParseBoardErrors(t *testing.T) {
+	for _, text := range []string{
+		"",
+		"\n  \n",
+		"....\n...",
+		"..?.",
+		"..q.",
+		"..tj",
+	} {
+		if b, err := ParseBoard(text); err == nil {
+			t.Errorf("parsed %q as\n%s", text, b)
+		}
+	}
+}
+
+func TestSetBoardErrors(t *testing.T) {
+	tests := []struct {
+		name  string
+		board *Board
+	}{
+		{"too wide", &Board{Rows: []string{"....."}}},
+		{"too high", &Board{Rows: []string{"....", "....", "....", "....", "....", "....", "...."}}},
+		{"bad block", &Board{Rows: []string{"..?."}}},
+		{"wrong shape", &Board{Rows: []string{"....", "...."}, Piece: tetronimoes.T, PieceCells: [][2]int{{0, 0}, {2, 0}, {1, 1}, {3, 1}}}},
+		{"no cells", &Board{Rows: []string{"....", "...."}, Piece: tetronimoes.T}},
+		{"unknown piece", &Board{Rows: []string{"...."}, Piece: 'Q', PieceCells: [][2]int{{0, 0}}}},
+		{"overlapping", &Board{Rows: []string{"....", "GGGG"}, Piece: tetronimoes.O, PieceCells: [][2]int{{0, 0}, {1, 0}, {0, 1}, {1, 1}}}},
+	}
+	for _, test := range tests {
+		s := NewState(Config{Width: 4, Height: 4, BufferHeight: 2})
+		if err := s.SetBoard(test.board); err == nil {
+			t.Errorf("%s: set\n%s", test.name, test.board)
+		}
+	}
+}
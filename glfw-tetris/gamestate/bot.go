@@ -0,0 +1,473 @@
+package gamestate
+
+import (
+	"log"
+	"math"
+
+	"github.com/omustardo/tetris/glfw-tetris/tetronimoes"
+)
+
+// Bot plays a game by itself. When it first sees a piece it tries locking it
+// everywhere that it can reach, tucks and spins included, each followed by
+// every place that the next piece can be dropped by turning it and moving it
+// sideways, and picks the one that leaves the best board by its weights,
+// unless it has an Engine to pick for it. In practice games it also tries
+// swapping the piece for the next one, since there's no hold. Then it presses
+// one key per tick to get the piece there.
+type Bot struct {
+	// Delay is the number of ticks to wait between key presses, to slow the
+	// bot down. At zero it presses a key every tick.
+	Delay int
+	// Weights are how the bot judges the boards left by its placements.
+	Weights Weights
+	// Engine, if set, picks where each piece goes in place of the bot's own
+	// judgement, like a bot running in another process. The bot still
+	// presses the keys.
+	Engine Engine
+
+	piece     *tetronimoes.Shape // The piece that the plan is for.
+	thinking  bool               // Whether the engine has yet to pick where it goes.
+	plan      []Input            // Keys left to press to place it, in order.
+	swapped   bool               // Whether the piece was swapped in, so it isn't swapped back out.
+	pressed   bool               // Whether the last key pressed should have moved or turned the piece.
+	lastCol   int                // Column of the piece's origin before that key was pressed.
+	lastTurns int                // Clockwise turns of the piece before that key was pressed.
+	wait      int                // Ticks left before the next key press.
+}
+
+// NewBot creates a bot with the default weights that waits delay ticks
+// between key presses.
+func NewBot(delay int) *Bot {
+	return &Bot{Delay: delay, Weights: DefaultWeights}
+}
+
+// Input returns the keys that the bot presses this tick in the given game.
+func (b *Bot) Input(s *State) Input {
+	s.botPlayed = true
+	// Keys pressed while paused, or counting down to resume, are ignored, so
+	// the plan waits until the game goes on.
+	if s.fallingPiece == nil || s.gameOver || s.Paused() {
+		return Input{}
+	}
+	fresh := s.fallingPiece != b.piece
+	if fresh {
+		b.piece = s.fallingPiece
+		b.thinking = b.Engine != nil
+		b.pressed = false
+		b.wait = b.Delay
+	}
+	if fresh && b.Engine == nil {
+		b.plan = s.plan(b.Weights, !b.swapped)
+		b.swapped = b.plan[0].Swap
+	}
+	if b.thinking {
+		plan, ready, err := b.Engine.Pick(s, fresh)
+		if err != nil {
+			log.Println("Bot engine failed, so the bot is carrying on by itself:", err)
+			b.Engine = nil
+			plan = s.plan(b.Weights, false)
+		} else if !ready {
+			return Input{}
+		}
+		b.plan, b.swapped, b.thinking = plan, false, false
+	}
+	if b.wait > 0 {
+		b.wait--
+		return Input{}
+	}
+	b.wait = b.Delay
+
+	col, turns := int(s.fallingPiece.Origin().X), rotation(s.fallingPiece)
+	if b.pressed && col == b.lastCol && turns == b.lastTurns || len(b.plan) == 0 {
+		// Something's in the way, so drop the piece where it is.
+		b.plan = []Input{{HardDrop: true}}
+	}
+	in := b.plan[0]
+	b.plan = b.plan[1:]
+	b.pressed = in.Left || in.Right || in.RotateClockwise || in.RotateCounterClockwise
+	b.lastCol, b.lastTurns = col, turns
+	return in
+}
+
+// Pick returns the keys that the bot would press to place the falling piece,
+// so that it can be the engine of another bot. It's always ready, and never
+// swaps pieces.
+func (b *Bot) Pick(s *State, fresh bool) ([]Input, bool, error) {
+	return s.plan(b.Weights, false), true, nil
+}
+
+// Watched returns the keys that the bot presses this tick in a game that a
+// player is watching, along with the keys that the player pressed to pause,
+// restart or undo.
+func (b *Bot) Watched(s *State, player Input) Input {
+	in := b.Input(s)
+	in.Pause, in.Restart, in.Undo = player.Pause, player.Restart, player.Undo
+	return in
+}
+
+// BotPlayed returns whether a Bot has pressed keys in the game, in which case
+// it shouldn't count towards personal bests.
+func (s *State) BotPlayed() bool {
+	return s.botPlayed
+}
+
+// Engine picks where a Bot puts each piece, in place of its own judgement.
+type Engine interface {
+	// Pick returns the keys to press to place the falling piece, ending with
+	// a hard drop, or ready is false if it hasn't picked yet. It's called
+	// every tick until it's ready, with fresh set on the first call for each
+	// piece. Once it returns an error the bot stops using it.
+	Pick(s *State, fresh bool) (inputs []Input, ready bool, err error)
+}
+
+// Weights say how much the bot cares about each feature of the board left by
+// a placement. Higher scores are better, so features to avoid have negative
+// weights.
+type Weights struct {
+	Height      float64 // Sum of the heights of every column.
+	Holes       float64 // Empty cells with a block somewhere above them.
+	Bumpiness   float64 // Sum of the differences in height between neighboring columns.
+	Wells       float64 // Sum of the depths of columns lower than their neighbors, except the deepest, which is kept for I pieces.
+	Lines       float64 // Rows cleared.
+	TSpinSetups float64 // Rows that a T could clear by spinning into a slot with three of its corners blocked.
+}
+
+// DefaultWeights are the weights that new bots play with.
+var DefaultWeights = Weights{
+	Height:      -0.51,
+	Holes:       -0.36,
+	Bumpiness:   -0.18,
+	Wells:       -0.1,
+	Lines:       0.76,
+	TSpinSetups: 0.15,
+}
+
+// plan picks the best place for the falling piece, and returns the keys to
+// press to get it there, ending with a hard drop. If swap is set and the game
+// allows it, and the next piece would do better, it returns a press of Swap
+// instead.
+func (s *State) plan(w Weights, swap bool) []Input {
+	g := s.grid()
+	queue := s.Queue()
+	plan, best := []Input{{HardDrop: true}}, math.Inf(-1)
+	for _, p := range s.reachable(g, s.fallingPiece, s.lastRotated) {
+		if score := s.lookahead(w, g, p, queue); score > best {
+			plan, best = p.inputs, score
+		}
+	}
+	if !swap || !s.practice || len(queue) == 0 {
+		return plan
+	}
+	// Swapping puts the falling piece next.
+	rest := append([]tetronimoes.Kind{s.fallingPiece.Kind()}, queue[1:]...)
+	for _, p := range s.reachable(g, s.newPiece(queue[0]), false) {
+		if score := s.lookahead(w, g, p, rest); score > best {
+			plan, best = []Input{{Swap: true}}, score
+		}
+	}
+	return plan
+}
+
+// lookahead scores a placement on g by the best board that it leaves once
+// the next piece in the queue is placed too, if there is one.
+func (s *State) lookahead(w Weights, g grid, p placement, queue []tetronimoes.Kind) float64 {
+	after, lines := g.place(p.cells)
+	if len(queue) == 0 {
+		return w.judge(after, lines)
+	}
+	// If the next piece doesn't fit then the game is over, which is as bad
+	// as it gets.
+	best := math.Inf(-1)
+	for _, next := range s.placements(after, s.newPiece(queue[0])) {
+		final, more := after.place(next.cells)
+		best = math.Max(best, w.judge(final, lines+more))
+	}
+	return best
+}
+
+// placement is somewhere that a piece can be dropped, and the keys to press
+// to get it there.
+type placement struct {
+	cells  []cell  // The cells it covers once it lands.
+	inputs []Input // The keys to press, ending with a hard drop.
+	tSpin  bool    // Whether it locks as a T-spin.
+}
+
+// placements returns every place on g that a piece can be dropped by turning
+// it where it is and then moving it sideways.
+func (s *State) placements(g grid, piece *tetronimoes.Shape) []placement {
+	var found []placement
+	if !g.fits(s.cells(piece)) {
+		return nil
+	}
+	for _, turns := range []int{0, 1, -1, 2} {
+		shape := piece.Copy()
+		if !s.turn(g, shape, turns) {
+			continue
+		}
+		origin := shape.Origin()
+		start := origin.X
+		for _, dir := range []int{-1, 1} {
+			for moves := 0; ; moves++ {
+				origin.X = start + float32(dir*moves*s.scale())
+				if !g.fits(s.cells(shape)) {
+					break
+				}
+				if dir > 0 && moves == 0 {
+					// Already tried on the way left.
+					continue
+				}
+				found = append(found, placement{
+					cells:  s.dropped(g, shape),
+					inputs: presses(turns, dir*moves),
+				})
+			}
+		}
+	}
+	return found
+}
+
+// turn rotates a shape in place, clockwise for positive turns and
+// counter-clockwise for negative ones, and returns whether it fit on g after
+// every turn.
+func (s *State) turn(g grid, shape *tetronimoes.Shape, turns int) bool {
+	for ; turns > 0; turns-- {
+		shape.RotateClockwise()
+		if !g.fits(s.cells(shape)) {
+			return false
+		}
+	}
+	for ; turns < 0; turns++ {
+		shape.RotateCounterClockwise()
+		if !g.fits(s.cells(shape)) {
+			return false
+		}
+	}
+	return true
+}
+
+// dropped returns the cells that a shape covers once it's dropped straight
+// down on g from where it is.
+func (s *State) dropped(g grid, shape *tetronimoes.Shape) []cell {
+	cells := s.cells(shape)
+	for {
+		for i := range cells {
+			cells[i].row -= s.scale()
+		}
+		if !g.fits(cells) {
+			break
+		}
+	}
+	for i := range cells {
+		cells[i].row += s.scale()
+	}
+	return cells
+}
+
+// presses returns the keys to press to turn a piece, clockwise for positive
+// turns, then move it sideways, right for positive moves, and then drop it.
+func presses(turns, moves int) []Input {
+	var inputs []Input
+	for ; turns > 0; turns-- {
+		inputs = append(inputs, Input{RotateClockwise: true})
+	}
+	for ; turns < 0; turns++ {
+		inputs = append(inputs, Input{RotateCounterClockwise: true})
+	}
+	for ; moves > 0; moves-- {
+		inputs = append(inputs, Input{Right: true})
+	}
+	for ; moves < 0; moves++ {
+		inputs = append(inputs, Input{Left: true})
+	}
+	return append(inputs, Input{HardDrop: true})
+}
+
+// grid records which cells of a board are filled, bottom row first, so that
+// the bot can try out placements without changing the game.
+type grid [][]bool
+
+// grid returns which cells of the board are filled.
+func (s *State) grid() grid {
+	g := make(grid, len(s.board))
+	for row := range s.board {
+		g[row] = make([]bool, s.config.Width)
+		for col, b := range s.board[row] {
+			g[row][col] = b != nil
+		}
+	}
+	return g
+}
+
+// fits returns whether the cells are all on the grid and empty.
+func (g grid) fits(cells []cell) bool {
+	for _, c := range cells {
+		if c.row < 0 || c.row >= len(g) || c.col < 0 || c.col >= len(g[c.row]) || g[c.row][c.col] {
+			return false
+		}
+	}
+	return true
+}
+
+// place returns the grid left by filling the cells and clearing the rows that
+// fills, and the number of rows cleared. Grids share the rows that they have
+// in common, so they mustn't be changed once they're made.
+func (g grid) place(cells []cell) (grid, int) {
+	left := make(grid, 0, len(g))
+	lines := 0
+	for row := range g {
+		line := g[row]
+		for _, c := range cells {
+			if c.row != row {
+				continue
+			}
+			if &line[0] == &g[row][0] {
+				line = append([]bool(nil), line...)
+			}
+			line[c.col] = true
+		}
+		if full(line) {
+			lines++
+			continue
+		}
+		left = append(left, line)
+	}
+	if lines > 0 {
+		empty := make([]bool, len(g[0]))
+		for len(left) < len(g) {
+			left = append(left, empty)
+		}
+	}
+	return left, lines
+}
+
+// blank returns whether every cell of a row is empty.
+func blank(line []bool) bool {
+	for _, filled := range line {
+		if filled {
+			return false
+		}
+	}
+	return true
+}
+
+// full returns whether every cell of a row is filled.
+func full(line []bool) bool {
+	for _, filled := range line {
+		if !filled {
+			return false
+		}
+	}
+	return true
+}
+
+// filled returns whether a cell is filled, counting the walls and floor as
+// filled and the space above the grid as empty.
+func (g grid) filled(col, row int) bool {
+	if row >= len(g) {
+		return false
+	}
+	return row < 0 || col < 0 || col >= len(g[row]) || g[row][col]
+}
+
+// judge scores a grid left by placements that cleared the given number of
+// rows.
+func (w Weights) judge(g grid, lines int) float64 {
+	// Only look as high as the stack goes.
+	top := len(g)
+	for top > 0 && blank(g[top-1]) {
+		top--
+	}
+	heights := make([]int, len(g[0]))
+	g = g[:top]
+	holes := 0
+	for col := range heights {
+		for row := len(g) - 1; row >= 0; row-- {
+			if !g[row][col] {
+				if heights[col] > 0 {
+					holes++
+				}
+				continue
+			}
+			if heights[col] == 0 {
+				heights[col] = row + 1
+			}
+		}
+	}
+	height, bumpiness, wells, deepest := 0, 0, 0, 0
+	for col, h := range heights {
+		height += h
+		if col > 0 {
+			bumpiness += abs(h - heights[col-1])
+		}
+		// The walls are as high as they need to be.
+		depth := math.MaxInt32
+		if col > 0 {
+			depth = heights[col-1] - h
+		}
+		if col < len(heights)-1 && heights[col+1]-h < depth {
+			depth = heights[col+1] - h
+		}
+		if depth > 0 && depth < math.MaxInt32 {
+			wells += depth
+			if depth > deepest {
+				deepest = depth
+			}
+		}
+	}
+	return w.Height*float64(height) +
+		w.Holes*float64(holes) +
+		w.Bumpiness*float64(bumpiness) +
+		w.Wells*float64(wells-deepest) +
+		w.Lines*float64(lines) +
+		w.TSpinSetups*float64(g.tSpinSetups())
+}
+
+// tSpinSetups counts the rows that could be cleared by spinning a T, pointing
+// down, into slots on the grid with both corners below its flat side blocked
+// and one above, like this:
+//
+//	X..
+//	...
+//	X.X
+func (g grid) tSpinSetups() int {
+	rows := 0
+	for row := 1; row < len(g)-1; row++ {
+		for col := 1; col < len(g[row])-1; col++ {
+			if g[row][col-1] || g[row][col] || g[row][col+1] || g[row-1][col] {
+				continue
+			}
+			if !g.filled(col-1, row-1) || !g.filled(col+1, row-1) ||
+				!g.filled(col-1, row+1) && !g.filled(col+1, row+1) {
+				continue
+			}
+			// Count the rows that the T would fill.
+			if g.fullWithout(row, col-1, col, col+1) {
+				rows++
+			}
+			if g.fullWithout(row-1, col) {
+				rows++
+			}
+		}
+	}
+	return rows
+}
+
+// fullWithout returns whether a row of the grid is full apart from the given
+// columns.
+func (g grid) fullWithout(row int, cols ...int) bool {
+	empty := 0
+	for _, filled := range g[row] {
+		if !filled {
+			empty++
+		}
+	}
+	return empty == len(cols)
+}
+
+func abs(n int) int {
+	if n < 0 {
+		return -n
+	}
+	return n
+}
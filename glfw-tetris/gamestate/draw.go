@@ -0,0 +1,128 @@
+package gamestate
+
+import (
+	"math"
+	"strconv"
+)
+
+// Each line of the HUD above the board takes up this many rows of blocks.
+const hudLineHeight = 1.5
+
+// Canvas is what Draw draws on, like a window. Positions have (0,0) in the
+// bottom left with y going up, and colors go from 0 to 1. Keeping windows
+// behind it means that the game can be played without one.
+type Canvas interface {
+	// RectFilled fills the rectangle between two corners.
+	RectFilled(x1, y1, x2, y2, r, g, b, a float32)
+	// RectColored draws the outline of the rectangle between two corners.
+	RectColored(x1, y1, x2, y2, r, g, b, a float32)
+}
+
+// Draw the game state on c within the area with its bottom left corner at
+// (x,y). Blocks are kept square, so the board is centered along whichever axis
+// has space left over. The hidden rows above the board aren't drawn.
+func (s *State) Draw(c Canvas, x, y, width, height float32) {
+	hud := s.hud()
+	x, y, blockSize := s.boardArea(x, y, width, height, len(hud))
+	width = blockSize * float32(s.config.Width)
+	height = blockSize * float32(s.config.Height)
+
+	// Draw the HUD above the board, first line at the top.
+	for i, line := range hud {
+		lineY := y + height + blockSize*hudLineHeight*(float32(len(hud)-i)-0.5)
+		drawTextCentered(c, x+width/2, lineY, textSize(line, width, blockSize/5), line, 1, 1, 1, 1)
+	}
+
+	// Draw bounding box
+	c.RectColored(x, y, x+width, y+height, 0.8, 0.8, 0.8, 1.0)
+
+	if s.paused {
+		// The board is hidden so pausing can't be used to plan ahead.
+		drawTextCentered(c, x+width/2, y+height/2, textSize("PAUSED", width*0.8, blockSize/2), "PAUSED", 1, 1, 1, 1)
+		return
+	}
+
+	drawBlock := func(col, row int, r, g, b, a float32) {
+		if row >= s.config.Height {
+			return
+		}
+		x1 := x + float32(col)*blockSize
+		y1 := y + float32(row)*blockSize
+		c.RectFilled(x1, y1, x1+blockSize, y1+blockSize, r, g, b, a)
+	}
+
+	// Draw all of the stable blocks.
+	for row := 0; row < s.config.Height; row++ {
+		for col := 0; col < s.config.Width; col++ {
+			cell := s.board[row][col]
+			if cell == nil {
+				continue
+			}
+			if v := s.visibility(cell); v > 0 {
+				drawBlock(col, row, cell.R, cell.G, cell.B, cell.A*v)
+			}
+		}
+	}
+
+	// Draw the falling piece.
+	if s.fallingPiece != nil {
+		r, g, b, a := s.fallingPiece.Color()
+		for _, cell := range s.cells(s.fallingPiece) {
+			drawBlock(cell.col, cell.row, r, g, b, a)
+		}
+	}
+
+	if s.countdown > 0 {
+		seconds := strconv.Itoa((s.countdown + TicksPerSecond - 1) / TicksPerSecond)
+		drawTextCentered(c, x+width/2, y+height/2, blockSize, seconds, 1, 1, 1, 1)
+	}
+
+	if s.gameOver {
+		// Darken the board and list the results over it, centered vertically.
+		c.RectFilled(x, y, x+width, y+height, 0, 0, 0, 0.7)
+		results := s.results()
+		for i, line := range results {
+			lineY := y + height/2 + blockSize*2*(float32(len(results))/2-float32(i)-0.5)
+			drawTextCentered(c, x+width/2, lineY, textSize(line, width*0.9, blockSize/4), line, 1, 1, 1, 1)
+		}
+	}
+}
+
+// boardArea returns where Draw puts the board within the area with its bottom
+// left corner at (x,y), when there are hudLines lines of HUD above it: the
+// bottom left corner of the board, and the size of each block.
+func (s *State) boardArea(x, y, width, height float32, hudLines int) (boardX, boardY, blockSize float32) {
+	rows := float32(s.config.Height) + hudLineHeight*float32(hudLines)
+	blockSize = width / float32(s.config.Width)
+	if size := height / rows; size < blockSize {
+		blockSize = size
+	}
+	boardX = x + (width-blockSize*float32(s.config.Width))/2
+	boardY = y + (height-blockSize*rows)/2
+	return boardX, boardY, blockSize
+}
+
+// CellAt returns the column and row of the board under the point (px,py), as
+// the board is drawn by Draw in the area with its bottom left corner at (x,y),
+// or false if the point isn't over the visible board.
+func (s *State) CellAt(px, py, x, y, width, height float32) (col, row int, ok bool) {
+	boardX, boardY, blockSize := s.boardArea(x, y, width, height, len(s.hud()))
+	col = int(math.Floor(float64((px - boardX) / blockSize)))
+	row = int(math.Floor(float64((py - boardY) / blockSize)))
+	if col < 0 || col >= s.config.Width || row < 0 || row >= s.config.Height {
+		return 0, 0, false
+	}
+	return col, row, true
+}
+
+// drawTextCentered draws text on c centered on (x,y), with each pixel of the
+// font taking up a size x size square.
+func drawTextCentered(c Canvas, x, y, size float32, text string, r, g, b, a float32) {
+	left := x - float32(textWidth(text))*size/2
+	top := y + float32(glyphHeight)*size/2
+	textPixels(text, func(px, py int) {
+		x1 := left + float32(px)*size
+		y1 := top - float32(py+1)*size
+		c.RectFilled(x1, y1, x1+size, y1+size, r, g, b, a)
+	})
+}
@@ -0,0 +1,516 @@
+package gamestate
+
+import (
+	"bytes"
+	"encoding/binary"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"math"
+	"sort"
+	"strconv"
+)
+
+// StateVersion is the version of the layout of saved games, as encoded by
+// both the JSON and the binary forms of Saved. Games saved in older versions
+// are migrated to this one as they're decoded. Newer versions can't be
+// decoded.
+const StateVersion = 1
+
+// jsonMigrations[v] upgrades the fields of a game saved as JSON in version v
+// to version v+1, so there must be StateVersion of them.
+var jsonMigrations = []func(fields map[string]json.RawMessage) error{
+	// Games saved before they had a version are laid out as in version 1.
+	0: func(fields map[string]json.RawMessage) error { return nil },
+}
+
+// savedFields is Saved without its methods, for encoding/json to fill in
+// once UnmarshalJSON has migrated it.
+type savedFields Saved
+
+// UnmarshalJSON decodes a saved game from JSON, migrating it from the version
+// it was saved in. Saved games are encoded to JSON as their fields, so there's
+// no MarshalJSON.
+func (saved *Saved) UnmarshalJSON(data []byte) error {
+	var fields map[string]json.RawMessage
+	if err := json.Unmarshal(data, &fields); err != nil {
+		return err
+	}
+	version := 0
+	if v, ok := fields["Version"]; ok {
+		if err := json.Unmarshal(v, &version); err != nil {
+			return fmt.Errorf("bad saved game version: %v", err)
+		}
+	}
+	if version < 0 || version > StateVersion {
+		return fmt.Errorf("unsupported saved game version %d, expected at most %d", version, StateVersion)
+	}
+	for ; version < StateVersion; version++ {
+		if err := jsonMigrations[version](fields); err != nil {
+			return fmt.Errorf("migrating saved game from version %d: %v", version, err)
+		}
+	}
+	fields["Version"] = json.RawMessage(strconv.Itoa(StateVersion))
+	data, err := json.Marshal(fields)
+	if err != nil {
+		return err
+	}
+	return json.Unmarshal(data, (*savedFields)(saved))
+}
+
+// Binary saved games start with stateMagic and then their version. The rest
+// is made of unsigned varints, except for signed varints where noted, in this
+// order:
+//
+//	width, height, buffer height, big (0 or 1), seed (signed)
+//	board: each row as a string of its length followed by its bytes
+//	lock times: 0, or 1 followed by a number for each cell of the board
+//	piece: 0, or 1 followed by its kind as a string, rotation, x and y
+//	  (signed)
+//	queue as a string, fixed queue
+//	seed (signed) and numbers drawn for the piece and garbage generators
+//	ticks, pieces, lines, keys
+//	frame, paused, countdown, game over, topped out
+//	score, level, gravity rows and ticks, fall progress, lock delay, lock
+//	  timer, ARE, ARE timer, last rotated, practice
+//	history: count, then for each snapshot its board, piece, queue, ticks,
+//	  pieces, lines, keys, score, combo (signed) and back to back
+//	fade: 0, or 1 followed by its after and over ticks
+//	attack: line, T-spin and combo tables as a count followed by each entry,
+//	  back to back and perfect clear bonuses, and the chance of changing the
+//	  hole as float64 bits, 8 bytes little endian
+//	combo (signed), back to back, incoming as a count followed by each, hole
+//	recording, then records as a count followed by each record as the
+//	  number of frames since the one before, its type and then whatever its
+//	  type holds, as in replay files:
+//	  input: its Bits
+//	  garbage: rows
+//	  paint: column, row, fill
+//	  pause, resume, end: nothing
+//	mode progress: count, then each name as a string and its value (signed),
+//	  in order of name
+//
+// Boards have a row for each of the height and buffer height, top first as in
+// Saved, so they aren't preceded by a count.
+const stateMagic = "TETRISSTATE"
+
+// MarshalBinary encodes a saved game in the compact binary form described
+// above, at StateVersion.
+func (saved *Saved) MarshalBinary() ([]byte, error) {
+	w := &writer{}
+	w.WriteString(stateMagic)
+	w.uint(StateVersion)
+
+	c := saved.Config
+	w.uint(c.Width)
+	w.uint(c.Height)
+	w.uint(c.BufferHeight)
+	w.bool(c.Big)
+	w.int64(c.Seed)
+	if len(saved.Board) != c.Height+c.BufferHeight {
+		return nil, fmt.Errorf("saved board has %d rows, expected %d", len(saved.Board), c.Height+c.BufferHeight)
+	}
+	for _, row := range saved.Board {
+		w.string(row)
+	}
+	w.bool(saved.LockedAt != nil)
+	if saved.LockedAt != nil {
+		if len(saved.LockedAt) != len(saved.Board) {
+			return nil, fmt.Errorf("saved lock times have %d rows, expected %d", len(saved.LockedAt), len(saved.Board))
+		}
+		for _, times := range saved.LockedAt {
+			if len(times) != c.Width {
+				return nil, fmt.Errorf("saved lock times have a row of %d cells, expected %d", len(times), c.Width)
+			}
+			for _, t := range times {
+				w.uint(t)
+			}
+		}
+	}
+	w.bool(saved.Piece != nil)
+	if p := saved.Piece; p != nil {
+		w.string(p.Kind)
+		w.uint(p.Rotation)
+		w.int(p.X)
+		w.int(p.Y)
+	}
+	w.string(saved.Queue)
+	w.bool(saved.FixedQueue)
+	w.int64(saved.Rand.Seed)
+	w.uint(saved.Rand.Drawn)
+	w.int64(saved.GarbageRand.Seed)
+	w.uint(saved.GarbageRand.Drawn)
+
+	w.stats(saved.Stats)
+	w.uint(saved.Frame)
+	w.bool(saved.Paused)
+	w.uint(saved.Countdown)
+	w.bool(saved.GameOver)
+	w.bool(saved.ToppedOut)
+
+	w.uint(saved.Score)
+	w.uint(saved.Level)
+	w.uint(saved.Gravity.Rows)
+	w.uint(saved.Gravity.Ticks)
+	w.uint(saved.FallProgress)
+	w.uint(saved.LockDelay)
+	w.uint(saved.LockTimer)
+	w.uint(saved.ARE)
+	w.uint(saved.ARETimer)
+	w.bool(saved.LastRotated)
+	w.bool(saved.Practice)
+	w.uint(len(saved.History))
+	for _, snap := range saved.History {
+		if len(snap.Board) != len(saved.Board) {
+			return nil, fmt.Errorf("saved snapshot has %d rows, expected %d", len(snap.Board), len(saved.Board))
+		}
+		for _, row := range snap.Board {
+			w.string(row)
+		}
+		w.string(snap.Piece)
+		w.string(snap.Queue)
+		w.stats(snap.Stats)
+		w.uint(snap.Score)
+		w.int(snap.Combo)
+		w.bool(snap.BackToBack)
+	}
+	w.bool(saved.Fade != nil)
+	if saved.Fade != nil {
+		w.uint(saved.Fade.After)
+		w.uint(saved.Fade.Over)
+	}
+
+	table := saved.Attack.Table
+	for _, entries := range [][]int{table.Lines, table.TSpin, table.Combo} {
+		w.uint(len(entries))
+		for _, n := range entries {
+			w.uint(n)
+		}
+	}
+	w.uint(table.BackToBack)
+	w.uint(table.PerfectClear)
+	var changeHole [8]byte
+	binary.LittleEndian.PutUint64(changeHole[:], math.Float64bits(saved.Attack.ChangeHole))
+	w.Write(changeHole[:])
+	w.int(saved.Combo)
+	w.bool(saved.BackToBack)
+	w.uint(len(saved.Incoming))
+	for _, rows := range saved.Incoming {
+		w.uint(rows)
+	}
+	w.uint(saved.Hole)
+
+	w.bool(saved.Recording)
+	w.uint(len(saved.Records))
+	frame := 0
+	for _, rec := range saved.Records {
+		w.uint(rec.Frame - frame)
+		frame = rec.Frame
+		w.uint(int(rec.Type))
+		switch rec.Type {
+		case RecordInput:
+			w.uint(rec.Input.Bits())
+		case RecordGarbage:
+			w.uint(rec.Rows)
+		case RecordPaint:
+			w.uint(rec.Col)
+			w.uint(rec.Row)
+			w.bool(rec.Fill)
+		}
+	}
+
+	names := make([]string, 0, len(saved.Mode))
+	for name := range saved.Mode {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	w.uint(len(names))
+	for _, name := range names {
+		w.string(name)
+		w.int64(saved.Mode[name])
+	}
+	return w.Bytes(), nil
+}
+
+// UnmarshalBinary decodes a saved game encoded by MarshalBinary, in any
+// version up to StateVersion.
+func (saved *Saved) UnmarshalBinary(data []byte) error {
+	if !bytes.HasPrefix(data, []byte(stateMagic)) {
+		return errors.New("not a saved game")
+	}
+	r := &reader{Reader: bytes.NewReader(data[len(stateMagic):])}
+	if v := r.uint(); r.err == nil && (v < 1 || v > StateVersion) {
+		return fmt.Errorf("unsupported saved game version %d, expected at most %d", v, StateVersion)
+	}
+
+	*saved = Saved{Version: StateVersion}
+	c := &saved.Config
+	c.Width = r.uint()
+	c.Height = r.uint()
+	c.BufferHeight = r.uint()
+	c.Big = r.bool()
+	c.Seed = r.int64()
+	// Rows are at least a byte each, so a bad height can't ask for more of
+	// them than there's data for.
+	rows := c.Height + c.BufferHeight
+	if rows > r.Len() {
+		return fmt.Errorf("bad saved game: %d rows is more than the %d bytes left", rows, r.Len())
+	}
+	saved.Board = r.board(rows)
+	if r.bool() {
+		if rows*c.Width > r.Len() {
+			return fmt.Errorf("bad saved game: %d lock times is more than the %d bytes left", rows*c.Width, r.Len())
+		}
+		saved.LockedAt = make([][]int, rows)
+		for row := range saved.LockedAt {
+			saved.LockedAt[row] = make([]int, c.Width)
+			for col := range saved.LockedAt[row] {
+				saved.LockedAt[row][col] = r.uint()
+			}
+		}
+	}
+	if r.bool() {
+		saved.Piece = &SavedPiece{Kind: r.string(), Rotation: r.uint(), X: r.int(), Y: r.int()}
+	}
+	saved.Queue = r.string()
+	saved.FixedQueue = r.bool()
+	saved.Rand = SavedRand{Seed: r.int64(), Drawn: r.uint()}
+	saved.GarbageRand = SavedRand{Seed: r.int64(), Drawn: r.uint()}
+
+	saved.Stats = r.stats()
+	saved.Frame = r.uint()
+	saved.Paused = r.bool()
+	saved.Countdown = r.uint()
+	saved.GameOver = r.bool()
+	saved.ToppedOut = r.bool()
+
+	saved.Score = r.uint()
+	saved.Level = r.uint()
+	saved.Gravity = Gravity{Rows: r.uint(), Ticks: r.uint()}
+	saved.FallProgress = r.uint()
+	saved.LockDelay = r.uint()
+	saved.LockTimer = r.uint()
+	saved.ARE = r.uint()
+	saved.ARETimer = r.uint()
+	saved.LastRotated = r.bool()
+	saved.Practice = r.bool()
+	for n := r.count(); n > 0 && r.err == nil; n-- {
+		saved.History = append(saved.History, SavedSnapshot{
+			Board:      r.board(rows),
+			Piece:      r.string(),
+			Queue:      r.string(),
+			Stats:      r.stats(),
+			Score:      r.uint(),
+			Combo:      r.int(),
+			BackToBack: r.bool(),
+		})
+	}
+	if r.bool() {
+		saved.Fade = &Fade{After: r.uint(), Over: r.uint()}
+	}
+
+	table := &saved.Attack.Table
+	for _, entries := range []*[]int{&table.Lines, &table.TSpin, &table.Combo} {
+		for n := r.count(); n > 0; n-- {
+			*entries = append(*entries, r.uint())
+		}
+	}
+	table.BackToBack = r.uint()
+	table.PerfectClear = r.uint()
+	var changeHole [8]byte
+	if _, err := io.ReadFull(r, changeHole[:]); err != nil && r.err == nil {
+		r.err = err
+	}
+	saved.Attack.ChangeHole = math.Float64frombits(binary.LittleEndian.Uint64(changeHole[:]))
+	saved.Combo = r.int()
+	saved.BackToBack = r.bool()
+	for n := r.count(); n > 0; n-- {
+		saved.Incoming = append(saved.Incoming, r.uint())
+	}
+	saved.Hole = r.uint()
+
+	saved.Recording = r.bool()
+	frame := 0
+	for n := r.count(); n > 0 && r.err == nil; n-- {
+		frame += r.uint()
+		rec := Record{Frame: frame, Type: RecordType(r.uint())}
+		switch rec.Type {
+		case RecordInput:
+			rec.Input = InputFromBits(r.uint())
+		case RecordGarbage:
+			rec.Rows = r.uint()
+		case RecordPaint:
+			rec.Col = r.uint()
+			rec.Row = r.uint()
+			rec.Fill = r.bool()
+		case RecordPause, RecordResume, RecordEnd:
+		default:
+			return fmt.Errorf("bad saved game: unknown record type %d", rec.Type)
+		}
+		saved.Records = append(saved.Records, rec)
+	}
+
+	if n := r.count(); n > 0 {
+		saved.Mode = make(ModeProgress, n)
+		for ; n > 0; n-- {
+			name := r.string()
+			saved.Mode[name] = r.int64()
+		}
+	}
+	if r.err != nil {
+		return fmt.Errorf("bad saved game: %v", r.err)
+	}
+	return nil
+}
+
+// MarshalJSON encodes the game as it would be saved, so it can be carried on
+// with DecodeState.
+func (s *State) MarshalJSON() ([]byte, error) {
+	return json.Marshal(s.Save())
+}
+
+// MarshalBinary encodes the game as it would be saved, in the compact binary
+// form of Saved, so it can be carried on with DecodeState.
+func (s *State) MarshalBinary() ([]byte, error) {
+	return s.Save().MarshalBinary()
+}
+
+// DecodeState carries on a game encoded by MarshalJSON or MarshalBinary, with
+// the mode it was played with, as Restore does.
+func DecodeState(data []byte, mode Mode) (*State, error) {
+	saved := &Saved{}
+	var err error
+	if bytes.HasPrefix(data, []byte(stateMagic)) {
+		err = saved.UnmarshalBinary(data)
+	} else {
+		err = json.Unmarshal(data, saved)
+	}
+	if err != nil {
+		return nil, err
+	}
+	return Restore(saved, mode)
+}
+
+type writer struct {
+	bytes.Buffer
+}
+
+func (w *writer) uint(n int) {
+	var buf [binary.MaxVarintLen64]byte
+	w.Write(buf[:binary.PutUvarint(buf[:], uint64(n))])
+}
+
+func (w *writer) int(n int) {
+	w.int64(int64(n))
+}
+
+func (w *writer) int64(n int64) {
+	var buf [binary.MaxVarintLen64]byte
+	w.Write(buf[:binary.PutVarint(buf[:], n)])
+}
+
+func (w *writer) bool(b bool) {
+	if b {
+		w.WriteByte(1)
+	} else {
+		w.WriteByte(0)
+	}
+}
+
+func (w *writer) string(s string) {
+	w.uint(len(s))
+	w.WriteString(s)
+}
+
+func (w *writer) stats(s Stats) {
+	w.uint(s.Ticks)
+	w.uint(s.Pieces)
+	w.uint(s.Lines)
+	w.uint(s.Keys)
+}
+
+// reader reads what writer writes. After the first error it reads only
+// zeros, and the error is kept in err.
+type reader struct {
+	*bytes.Reader
+	err error
+}
+
+func (r *reader) uint() int {
+	if r.err != nil {
+		return 0
+	}
+	n, err := binary.ReadUvarint(r)
+	if err == nil && n > math.MaxInt32 {
+		err = fmt.Errorf("number %d is too large", n)
+	}
+	if err != nil {
+		r.err = err
+		return 0
+	}
+	return int(n)
+}
+
+func (r *reader) int() int {
+	n := r.int64()
+	if n < math.MinInt32 || n > math.MaxInt32 {
+		if r.err == nil {
+			r.err = fmt.Errorf("number %d is too large", n)
+		}
+		return 0
+	}
+	return int(n)
+}
+
+func (r *reader) int64() int64 {
+	if r.err != nil {
+		return 0
+	}
+	n, err := binary.ReadVarint(r)
+	if err != nil {
+		r.err = err
+	}
+	return n
+}
+
+func (r *reader) bool() bool {
+	return r.uint() != 0
+}
+
+// count reads the number of things that follow, each of which takes at least
+// a byte.
+func (r *reader) count() int {
+	n := r.uint()
+	if n > r.Len() {
+		if r.err == nil {
+			r.err = fmt.Errorf("count %d is more than the %d bytes left", n, r.Len())
+		}
+		return 0
+	}
+	return n
+}
+
+func (r *reader) string() string {
+	n := r.count()
+	if n == 0 {
+		return ""
+	}
+	b := make([]byte, n)
+	if _, err := io.ReadFull(r, b); err != nil && r.err == nil {
+		r.err = err
+	}
+	return string(b)
+}
+
+func (r *reader) board(rows int) []string {
+	board := make([]string, rows)
+	for i := range board {
+		board[i] = r.string()
+	}
+	return board
+}
+
+func (r *reader) stats() Stats {
+	return Stats{Ticks: r.uint(), Pieces: r.uint(), Lines: r.uint(), Keys: r.uint()}
+}
@@ -0,0 +1,36 @@
+package gamestate
+
+// Fade makes blocks on the board disappear after they lock, so the player has
+// to remember where they are. Each block is shown for After ticks once it
+// locks and then fades out over Over ticks. A zero Fade hides blocks as soon
+// as they lock.
+type Fade struct {
+	After, Over int
+}
+
+// SetFade changes how blocks on the board disappear. nil keeps them visible,
+// which is the default. Everything is revealed again once the game is over.
+func (s *State) SetFade(f *Fade) {
+	s.fade = f
+}
+
+// Fade returns how blocks on the board disappear, or nil if they don't.
+func (s *State) Fade() *Fade {
+	return s.fade
+}
+
+// visibility returns how visible a block on the board is, from 0 for hidden
+// to 1 for fully shown.
+func (s *State) visibility(b *block) float32 {
+	if s.fade == nil || s.gameOver {
+		return 1
+	}
+	age := s.ticks - b.lockedAt
+	switch {
+	case age < s.fade.After:
+		return 1
+	case age >= s.fade.After+s.fade.Over:
+		return 0
+	}
+	return 1 - float32(age-s.fade.After)/float32(s.fade.Over)
+}
@@ -0,0 +1,29 @@
+package gamestate
+
+import "testing"
+
+func TestHardDropDuringARE(t *testing.T) {
+	const are = 10
+	s := NewState(DefaultConfig())
+	s.SetARE(are)
+	s.Tick()
+	s.Apply(Input{HardDrop: true})
+	if s.fallingPiece != nil || s.Stats().Pieces != 1 {
+		t.Fatal("hard drop didn't lock the falling piece")
+	}
+	// Holding the key while waiting for the next piece does nothing. The
+	// piece spawns on the tick after the wait.
+	for tick := 0; tick <= are; tick++ {
+		s.Apply(Input{HardDrop: true})
+		if s.fallingPiece != nil || s.Stats().Pieces != 1 {
+			t.Fatalf("hard drop %d ticks after locking spawned and dropped a piece", tick)
+		}
+		s.Tick()
+	}
+	if s.fallingPiece == nil {
+		t.Fatalf("no piece spawned %d ticks after locking", are+1)
+	}
+	if s.Stats().Pieces != 1 {
+		t.Errorf("%d pieces locked, want 1", s.Stats().Pieces)
+	}
+}
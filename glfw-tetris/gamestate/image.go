@@ -0,0 +1,200 @@
+package gamestate
+
+import (
+	"image"
+	"image/color"
+	"image/draw"
+	"math"
+	"strconv"
+
+	"github.com/omustardo/tetris/glfw-tetris/tetronimoes"
+)
+
+// The queue of upcoming pieces is drawn in images to the right of the board,
+// in a column this many blocks wide, with each piece at half size and taking
+// up queueSpacing blocks of the column's height. Both are multiplied by the
+// scale of big games.
+const (
+	queueColumns = 3
+	queueSpacing = 2.5
+)
+
+// Image draws the game state into a new image of the given size, on a black
+// background. See DrawImage.
+func (s *State) Image(width, height int) *image.RGBA {
+	img := image.NewRGBA(image.Rect(0, 0, width, height))
+	draw.Draw(img, img.Bounds(), image.NewUniform(color.Black), image.Point{}, draw.Src)
+	s.DrawImage(img, img.Bounds())
+	return img
+}
+
+// DrawImage draws the game state into the area r of img, in software, so
+// games can be drawn without a GPU or a window. It's laid out like Draw, with
+// the HUD above the board, and also shows where the falling piece would land
+// and the queue of upcoming pieces, to the right of the board. There's no hold
+// piece to show. Drawing doesn't change the game.
+func (s *State) DrawImage(img *image.RGBA, r image.Rectangle) {
+	// Everything is laid out as in Draw, with (0,0) in the bottom left of r
+	// and y going up, and flipped as it's filled in.
+	fill := func(x1, y1, x2, y2, red, green, blue, alpha float32) {
+		rect := image.Rect(
+			r.Min.X+round(x1), r.Max.Y-round(y2),
+			r.Min.X+round(x2), r.Max.Y-round(y1),
+		).Intersect(r)
+		c := color.NRGBA{R: channel(red), G: channel(green), B: channel(blue), A: channel(alpha)}
+		draw.Draw(img, rect, image.NewUniform(c), image.Point{}, draw.Over)
+	}
+	text := func(x, y, size float32, line string) {
+		left := x - float32(textWidth(line))*size/2
+		top := y + float32(glyphHeight)*size/2
+		textPixels(line, func(px, py int) {
+			x1 := left + float32(px)*size
+			y1 := top - float32(py+1)*size
+			fill(x1, y1, x1+size, y1+size, 1, 1, 1, 1)
+		})
+	}
+
+	hud := s.hud()
+	queueWidth := float32(queueColumns * s.scale())
+	columns := float32(s.config.Width) + queueWidth
+	areaWidth := float32(r.Dx()) * float32(s.config.Width) / columns
+	_, y, blockSize := s.boardArea(0, 0, areaWidth, float32(r.Dy()), len(hud))
+	width := blockSize * float32(s.config.Width)
+	height := blockSize * float32(s.config.Height)
+	// Center the board and queue together.
+	x := (float32(r.Dx()) - width - queueWidth*blockSize) / 2
+
+	for i, line := range hud {
+		lineY := y + height + blockSize*hudLineHeight*(float32(len(hud)-i)-0.5)
+		text(x+width/2, lineY, textSize(line, width, blockSize/5), line)
+	}
+
+	// Draw the bounding box, a pixel wide.
+	fill(x-1, y-1, x+width+1, y, 0.8, 0.8, 0.8, 1)
+	fill(x-1, y+height, x+width+1, y+height+1, 0.8, 0.8, 0.8, 1)
+	fill(x-1, y, x, y+height, 0.8, 0.8, 0.8, 1)
+	fill(x+width, y, x+width+1, y+height, 0.8, 0.8, 0.8, 1)
+
+	if s.paused {
+		text(x+width/2, y+height/2, textSize("PAUSED", width*0.8, blockSize/2), "PAUSED")
+		return
+	}
+
+	drawBlock := func(col, row int, red, green, blue, alpha float32) {
+		if row >= s.config.Height {
+			return
+		}
+		x1 := x + float32(col)*blockSize
+		y1 := y + float32(row)*blockSize
+		fill(x1, y1, x1+blockSize, y1+blockSize, red, green, blue, alpha)
+	}
+
+	for row := 0; row < s.config.Height; row++ {
+		for col := 0; col < s.config.Width; col++ {
+			cell := s.board[row][col]
+			if cell == nil {
+				continue
+			}
+			if v := s.visibility(cell); v > 0 {
+				drawBlock(col, row, cell.R, cell.G, cell.B, cell.A*v)
+			}
+		}
+	}
+
+	if s.fallingPiece != nil {
+		red, green, blue, alpha := s.fallingPiece.Color()
+		for _, c := range s.cells(s.ghost()) {
+			drawBlock(c.col, c.row, red, green, blue, alpha*0.3)
+		}
+		for _, c := range s.cells(s.fallingPiece) {
+			drawBlock(c.col, c.row, red, green, blue, alpha)
+		}
+	}
+
+	// Draw the queue from the top down, as many pieces as fit.
+	pieceSize := blockSize * float32(s.scale()) / 2
+	spacing := queueSpacing * blockSize * float32(s.scale())
+	centerX := x + width + queueWidth*blockSize/2
+	for i, kind := range s.queue {
+		if i >= previewLength || float32(i+1)*spacing > height {
+			break
+		}
+		shape := tetronimoes.NewShape(kind)
+		points := shape.Points()
+		bottom, top := filledRows(points)
+		left, right := filledColumns(points)
+		centerY := y + height - (float32(i)+0.5)*spacing
+		x0 := centerX - float32(left+right+1)*pieceSize/2
+		y0 := centerY - float32(bottom+top+1)*pieceSize/2
+		red, green, blue, alpha := shape.Color()
+		for row := range points {
+			for col, p := range points[row] {
+				if p {
+					x1 := x0 + float32(col)*pieceSize
+					y1 := y0 + float32(row)*pieceSize
+					fill(x1, y1, x1+pieceSize, y1+pieceSize, red, green, blue, alpha)
+				}
+			}
+		}
+	}
+
+	if s.countdown > 0 {
+		seconds := strconv.Itoa((s.countdown + TicksPerSecond - 1) / TicksPerSecond)
+		text(x+width/2, y+height/2, blockSize, seconds)
+	}
+
+	if s.gameOver {
+		fill(x, y, x+width, y+height, 0, 0, 0, 0.7)
+		results := s.results()
+		for i, line := range results {
+			lineY := y + height/2 + blockSize*2*(float32(len(results))/2-float32(i)-0.5)
+			text(x+width/2, lineY, textSize(line, width*0.9, blockSize/4), line)
+		}
+	}
+}
+
+// ghost returns a copy of the falling piece, dropped as far as it would go.
+func (s *State) ghost() *tetronimoes.Shape {
+	ghost := s.fallingPiece.Copy()
+	origin := ghost.Origin()
+	for !s.BoardIntersects(ghost) {
+		origin.Y -= float32(s.scale())
+	}
+	origin.Y += float32(s.scale())
+	return ghost
+}
+
+// filledColumns returns the leftmost and rightmost columns of points that
+// contain a block.
+func filledColumns(points [][]bool) (left, right int) {
+	left, right = len(points), -1
+	for row := range points {
+		for col, p := range points[row] {
+			if !p {
+				continue
+			}
+			if col < left {
+				left = col
+			}
+			if col > right {
+				right = col
+			}
+		}
+	}
+	return left, right
+}
+
+func round(f float32) int {
+	return int(math.Floor(float64(f) + 0.5))
+}
+
+// channel converts a color channel from 0 to 1 into a byte.
+func channel(f float32) uint8 {
+	switch {
+	case f <= 0:
+		return 0
+	case f >= 1:
+		return 255
+	}
+	return uint8(f*255 + 0.5)
+}
@@ -0,0 +1,143 @@
+package gamestate
+
+import "testing"
+
+// checkMoves plays each of the falling piece's moves on a copy of the game,
+// and checks that the piece locks where the move says, spun in if it says so.
+func checkMoves(t *testing.T, name string, s *State) []Move {
+	t.Helper()
+	moves := s.Moves()
+	if len(moves) == 0 {
+		t.Fatalf("%s: no moves on\n%s", name, s.Board())
+	}
+	for _, m := range moves {
+		c := s.Copy()
+		last := len(m.Inputs) - 1
+		if !m.Inputs[last].HardDrop {
+			t.Errorf("%s: move %+v doesn't end by dropping", name, m.Inputs)
+			continue
+		}
+		for _, in := range m.Inputs[:last] {
+			c.Apply(in)
+		}
+		for c.moveDown() {
+		}
+		tSpin := c.isTSpin()
+		c.Apply(m.Inputs[last])
+		var locked [][2]int
+		for _, cell := range c.placed {
+			locked = append(locked, [2]int{cell.col, cell.row})
+		}
+		if !sameCells(locked, m.Cells) {
+			t.Errorf("%s: %+v locked on %v, want %v, on\n%s", name, m.Inputs, locked, m.Cells, s.Board())
+		}
+		if tSpin != m.TSpin {
+			t.Errorf("%s: %+v spun in %v, want %v, on\n%s", name, m.Inputs, tSpin, m.TSpin, s.Board())
+		}
+	}
+	return moves
+}
+
+// findMove returns the move that locks on cells, if there is one.
+func findMove(moves []Move, cells [][2]int) (Move, bool) {
+	for _, m := range moves {
+		if sameCells(m.Cells, cells) {
+			return m, true
+		}
+	}
+	return Move{}, false
+}
+
+func TestMoves(t *testing.T) {
+	tests := []struct {
+		name  string
+		board string
+		// want are the cells of a move that must be found, and whether it's a
+		// T-spin.
+		want  [][2]int
+		tSpin bool
+	}{
+		{
+			name: "empty",
+			board: `
+				....oo....
+				....oo....
+				..........
+				..........`,
+			want: [][2]int{{0, 0}, {1, 0}, {0, 1}, {1, 1}},
+		},
+		{
+			// The O has to be dropped in the gap and slid under the
+			// overhang.
+			name: "tuck",
+			board: `
+				....oo....
+				....oo....
+				..........
+				..........
+				XXXXXX....
+				..........
+				..........
+				XXXXXXXX..`,
+			want: [][2]int{{0, 1}, {1, 1}, {0, 2}, {1, 2}},
+		},
+		{
+			// The T has to be turned under the overhang to fill the slot.
+			name: "t-spin slot",
+			board: `
+				....ttt...
+				.....t....
+				..........
+				..........
+				XXXX......
+				XXX...XXXX
+				XXXX.XXXXX`,
+			want:  [][2]int{{3, 1}, {4, 1}, {5, 1}, {4, 0}},
+			tSpin: true,
+		},
+	}
+	for _, test := range tests {
+		s := NewState(Config{Width: 10, Height: 20, BufferHeight: 4})
+		b, err := ParseBoard(test.board)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if err := s.SetBoard(b); err != nil {
+			t.Fatalf("%s: %v", test.name, err)
+		}
+		moves := checkMoves(t, test.name, s)
+		m, ok := findMove(moves, test.want)
+		if !ok {
+			t.Errorf("%s: no move locks on %v", test.name, test.want)
+		} else if m.TSpin != test.tSpin {
+			t.Errorf("%s: move to %v spins in %v, want %v", test.name, test.want, m.TSpin, test.tSpin)
+		}
+	}
+}
+
+func TestMovesInGames(t *testing.T) {
+	configs := []Config{
+		{Width: 10, Height: 20, BufferHeight: 4, Seed: 5},
+		{Width: 20, Height: 40, BufferHeight: 8, Seed: 5, Big: true},
+	}
+	for _, config := range configs {
+		s := NewState(config)
+		bot := NewBot(0)
+		name := "normal"
+		if config.Big {
+			name = "big"
+		}
+		last := s.fallingPiece
+		for tick := 0; tick < 1500 && !s.GameOver(); tick++ {
+			if tick%200 == 0 {
+				s.ReceiveGarbage(3)
+			}
+			if s.fallingPiece != nil && s.fallingPiece != last {
+				last = s.fallingPiece
+				checkMoves(t, name, s)
+			}
+			s.Apply(bot.Input(s))
+			s.Tick()
+		}
+	}
+}
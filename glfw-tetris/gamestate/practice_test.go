@@ -0,0 +1,43 @@
+package gamestate
+
+import (
+	"testing"
+
+	"github.com/omustardo/tetris/glfw-tetris/tetronimoes"
+)
+
+func TestCycleQueueAfterSetBoard(t *testing.T) {
+	b, err := ParseBoard(`
+		.t........
+		ttt.......
+		GGGGGGGGG.`)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, forget := range []bool{false, true} {
+		s := NewState(DefaultConfig())
+		s.SetPractice(true)
+		if err := s.SetBoard(b); err != nil {
+			t.Fatal(err)
+		}
+		if forget {
+			// Whatever set the piece, reordering mustn't rely on there being
+			// a snapshot of it.
+			s.history = nil
+		}
+		next := s.Queue()[0]
+		s.CycleQueue()
+		if kind, _ := s.PieceCells(); kind != next {
+			t.Errorf("cycled to %c, want %c", kind, next)
+		}
+		if queue := s.Queue(); queue[len(queue)-1] != tetronimoes.T {
+			t.Errorf("cycled the T to %c, want it last in %c", queue[len(queue)-1], queue)
+		}
+		// Undoing the cycled piece leaves it where it is, since there was
+		// nothing before it.
+		s.Undo()
+		if kind, _ := s.PieceCells(); kind != next {
+			t.Errorf("undid to %c, want %c", kind, next)
+		}
+	}
+}
@@ -0,0 +1,25 @@
+package gamestate
+
+import "github.com/omustardo/tetris/glfw-tetris/tetronimoes"
+
+// fillQueue tops up the queue of upcoming pieces with random ones, unless
+// it's fixed.
+func (s *State) fillQueue() {
+	for !s.fixedQueue && len(s.queue) < previewLength {
+		s.queue = append(s.queue, tetronimoes.RandomKind(s.rng.Rand))
+	}
+}
+
+// Queue returns the kinds of the upcoming pieces, next first.
+func (s *State) Queue() []tetronimoes.Kind {
+	s.fillQueue()
+	return append([]tetronimoes.Kind(nil), s.queue...)
+}
+
+// SetQueue replaces the upcoming pieces. If fixed is set then no more pieces
+// are added once they've been used, and the game ends instead. Otherwise
+// random pieces follow them.
+func (s *State) SetQueue(kinds []tetronimoes.Kind, fixed bool) {
+	s.queue = append([]tetronimoes.Kind(nil), kinds...)
+	s.fixedQueue = fixed
+}
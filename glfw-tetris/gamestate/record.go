@@ -0,0 +1,155 @@
+package gamestate
+
+// A game's randomness all comes from its seed, so the only things that make
+// two games with the same config and mode play out differently are the calls
+// made into them from outside: the player's input, garbage from opponents,
+// painting, and pausing or ending the game other than by input. A recording
+// logs each of those calls with the frame it was made on. Frames count every
+// call to Tick, paused or not, since the game clock itself stops while paused.
+// Making the same calls on the same frames of a new game plays it out the same
+// way again.
+
+// RecordType is the kind of call that a Record holds.
+type RecordType int
+
+const (
+	// Apply was called with Input.
+	RecordInput RecordType = iota
+	// ReceiveGarbage was called with Rows.
+	RecordGarbage
+	// Paint was called with Col, Row and Fill.
+	RecordPaint
+	// Pause was called.
+	RecordPause
+	// Resume was called.
+	RecordResume
+	// End was called.
+	RecordEnd
+)
+
+// Record is a call made into a game from outside.
+type Record struct {
+	Type  RecordType
+	Frame int // Number of calls to Tick before the call was made.
+	Input Input
+	Rows  int
+	// The cell painted, and whether it was filled or emptied.
+	Col, Row int
+	Fill     bool
+}
+
+// Recording is everything needed, along with the config and mode, to play a
+// game again.
+type Recording struct {
+	Records []Record
+	// Frames is the number of calls to Tick that the recording covers.
+	Frames int
+}
+
+// Record starts recording the game. It should be called before the game
+// starts. Recording carries on across restarts, with each restart starting a
+// new recording.
+func (s *State) Record() {
+	s.recording = true
+	s.records = nil
+}
+
+// Recording returns the game's recording so far, or nil if it isn't being
+// recorded.
+func (s *State) Recording() *Recording {
+	if !s.recording {
+		return nil
+	}
+	return &Recording{
+		Records: append([]Record(nil), s.records...),
+		Frames:  s.frame,
+	}
+}
+
+// record adds a call to the recording, if there is one.
+func (s *State) record(r Record) {
+	if !s.recording {
+		return
+	}
+	r.Frame = s.frame
+	s.records = append(s.records, r)
+}
+
+// Playback makes the calls in a recording into a game, one frame at a time.
+type Playback struct {
+	state     *State
+	recording Recording
+	next      int // Index of the next record to play.
+}
+
+// NewPlayback plays a recording into s, which should be a new game with the
+// same config and mode as the recorded one.
+func NewPlayback(s *State, recording Recording) *Playback {
+	s.playback = true
+	return &Playback{state: s, recording: recording}
+}
+
+// PlayingBack returns whether the game is being played back from a
+// recording, in which case it shouldn't count towards personal bests.
+func (s *State) PlayingBack() bool {
+	return s.playback
+}
+
+// Copy returns a copy of the playback, and of the game being played back,
+// that carries on separately from it.
+func (p *Playback) Copy() *Playback {
+	c := *p
+	c.state = p.state.Copy()
+	return &c
+}
+
+// State returns the game being played back.
+func (p *Playback) State() *State {
+	return p.state
+}
+
+// Frame returns the number of frames played so far.
+func (p *Playback) Frame() int {
+	return p.state.frame
+}
+
+// Frames returns the number of frames in the recording.
+func (p *Playback) Frames() int {
+	return p.recording.Frames
+}
+
+// Step plays the next frame: the calls made during it, followed by a tick.
+// Once the recording is over it does nothing.
+func (p *Playback) Step() {
+	records := p.recording.Records
+	for p.next < len(records) && records[p.next].Frame <= p.state.frame {
+		p.state.play(records[p.next])
+		p.next++
+	}
+	if p.state.frame < p.recording.Frames {
+		p.state.Tick()
+	}
+}
+
+// Done returns whether the whole recording has been played.
+func (p *Playback) Done() bool {
+	return p.next >= len(p.recording.Records) && p.state.frame >= p.recording.Frames
+}
+
+// play makes a recorded call.
+func (s *State) play(r Record) {
+	switch r.Type {
+	case RecordInput:
+		s.Apply(r.Input)
+	case RecordGarbage:
+		s.ReceiveGarbage(r.Rows)
+	case RecordPaint:
+		s.Paint(r.Col, r.Row, r.Fill)
+	case RecordPause:
+		s.Pause()
+	case RecordResume:
+		s.Resume()
+	case RecordEnd:
+		s.End()
+	}
+}
@@ -0,0 +1,135 @@
+package gamestate
+
+import (
+	"fmt"
+
+	"github.com/omustardo/tetris/glfw-tetris/tetronimoes"
+)
+
+// Characters for the cells of a board written out as text, as in puzzle
+// files. Blocks that were part of a piece use the letter of its kind.
+const (
+	emptyCell   = '.'
+	plainCell   = 'X'
+	garbageCell = 'G'
+)
+
+// Rows returns the visible rows of the board as text, top row first. Falling
+// pieces aren't included.
+func (s *State) Rows() []string {
+	rows := make([]string, s.config.Height)
+	for row := 0; row < s.config.Height; row++ {
+		rows[s.config.Height-1-row] = rowText(s.board[row])
+	}
+	return rows
+}
+
+// rowText returns a row of the board as text.
+func rowText(row []*block) string {
+	line := make([]byte, len(row))
+	for col, b := range row {
+		switch {
+		case b == nil:
+			line[col] = emptyCell
+		case b.kind != 0:
+			line[col] = byte(b.kind)
+		case b.garbage:
+			line[col] = garbageCell
+		default:
+			line[col] = plainCell
+		}
+	}
+	return string(line)
+}
+
+// newBlock returns the block written as c in rows of text, or nil if c is an
+// empty cell.
+func newBlock(c byte) (*block, error) {
+	b := &block{}
+	switch c {
+	case emptyCell:
+		return nil, nil
+	case garbageCell:
+		b.garbage = true
+		fallthrough
+	case plainCell:
+		b.R, b.G, b.B, b.A = tetronimoes.GarbageColor()
+		return b, nil
+	}
+	shape := tetronimoes.NewShape(tetronimoes.Kind(c))
+	if shape == nil {
+		return nil, fmt.Errorf("unknown cell %q", c)
+	}
+	b.kind = shape.Kind()
+	b.R, b.G, b.B, b.A = shape.Color()
+	return b, nil
+}
+
+// RowsWithPiece returns the visible rows of the board like Rows, but with the
+// falling piece drawn in using the letter of its kind.
+func (s *State) RowsWithPiece() []string {
+	rows := s.Rows()
+	if s.fallingPiece == nil {
+		return rows
+	}
+	for _, c := range s.cells(s.fallingPiece) {
+		if c.row < 0 || c.row >= s.config.Height || c.col < 0 || c.col >= s.config.Width {
+			continue
+		}
+		i := s.config.Height - 1 - c.row
+		line := []byte(rows[i])
+		line[c.col] = byte(s.fallingPiece.Kind())
+		rows[i] = string(line)
+	}
+	return rows
+}
+
+// PieceCells returns the kind of the falling piece and the cells of the board
+// that it covers, each as its column and row counted from the bottom left.
+// There are no cells if no piece is falling.
+func (s *State) PieceCells() (tetronimoes.Kind, [][2]int) {
+	if s.fallingPiece == nil {
+		return 0, nil
+	}
+	return s.fallingPiece.Kind(), cellPairs(s.cells(s.fallingPiece))
+}
+
+// PlacedCells is like PieceCells, but for the piece that locked most
+// recently, where it locked. There are no cells if none have locked since the
+// game started or was restored.
+func (s *State) PlacedCells() (tetronimoes.Kind, [][2]int) {
+	return s.placedKind, cellPairs(s.placed)
+}
+
+func cellPairs(cells []cell) [][2]int {
+	var pairs [][2]int
+	for _, c := range cells {
+		pairs = append(pairs, [2]int{c.col, c.row})
+	}
+	return pairs
+}
+
+// SetRows replaces the board with rows of text like those returned by Rows,
+// top row first. The rows fill the bottom of the board, and anything above
+// them is cleared. Characters that aren't recognized are taken to be plain
+// blocks.
+func (s *State) SetRows(rows []string) {
+	for row := range s.board {
+		s.board[row] = make([]*block, s.config.Width)
+	}
+	for i, line := range rows {
+		row := len(rows) - 1 - i
+		for col := 0; col < len(line); col++ {
+			switch line[col] {
+			case emptyCell:
+			case garbageCell:
+				s.SetCell(col, row, 0)
+				if row < len(s.board) && col < s.config.Width {
+					s.board[row][col].garbage = true
+				}
+			default:
+				s.SetCell(col, row, tetronimoes.Kind(line[col]))
+			}
+		}
+	}
+}
@@ -0,0 +1,387 @@
+package gamestate
+
+import (
+	"fmt"
+
+	"github.com/omustardo/tetris/glfw-tetris/tetronimoes"
+)
+
+// Saved is everything about a game in progress needed to carry it on later,
+// from where it was, in a form that can be written out.
+type Saved struct {
+	// Version is the StateVersion the game was saved in.
+	Version int
+	Config  Config
+	// Board holds every row of the board as text like Rows, but with the
+	// hidden rows included.
+	Board []string
+	// LockedAt holds the tick each block on the board locked on, in the same
+	// layout as Board, for fading blocks out. It's left out when the game has
+	// no fade.
+	LockedAt [][]int `json:",omitempty"`
+	// Piece is the falling piece, if there is one.
+	Piece *SavedPiece `json:",omitempty"`
+	// Queue holds the letters of the kinds of the upcoming pieces, next
+	// first.
+	Queue      string
+	FixedQueue bool
+	// Each random generator is saved as its seed and the number of numbers
+	// drawn from it. See Rand.Position.
+	Rand, GarbageRand SavedRand
+
+	Stats     Stats
+	Frame     int
+	Paused    bool
+	Countdown int
+	GameOver  bool
+	ToppedOut bool
+
+	Score        int
+	Level        int
+	Gravity      Gravity
+	FallProgress int
+	LockDelay    int
+	LockTimer    int
+	ARE          int
+	ARETimer     int
+	LastRotated  bool
+	Practice     bool
+	// History is what Undo goes back through, in practice.
+	History []SavedSnapshot `json:",omitempty"`
+	Fade    *Fade           `json:",omitempty"`
+
+	Attack     Attack
+	Combo      int
+	BackToBack bool
+	Incoming   []int `json:",omitempty"`
+	Hole       int
+
+	// Recording and Records carry on the game's recording, if it's being
+	// recorded, so a resumed game can still be played back from the start.
+	Recording bool
+	Records   []Record `json:",omitempty"`
+
+	// Mode holds the progress of the game's mode, if it's a ModeSaver.
+	Mode ModeProgress `json:",omitempty"`
+}
+
+// SavedPiece is a falling piece: the letter of its kind, the number of times
+// it's been rotated clockwise from how it spawned, from 0 to 3, and the
+// position of its origin on the board.
+type SavedPiece struct {
+	Kind     string
+	Rotation int
+	X, Y     int
+}
+
+// SavedSnapshot is a game as it was just after a piece spawned, for undo.
+// Board and Queue are like those of Saved, and Piece is the letter of the
+// kind of piece that had just spawned.
+type SavedSnapshot struct {
+	Board      []string
+	Piece      string
+	Queue      string
+	Stats      Stats
+	Score      int
+	Combo      int
+	BackToBack bool
+}
+
+// SavedRand is the position of a Rand.
+type SavedRand struct {
+	Seed  int64
+	Drawn int
+}
+
+// ModeProgress is a mode's progress through a game, as named numbers.
+type ModeProgress map[string]int64
+
+// ModeSaver is a mode that keeps track of its own progress through a game,
+// which has to be saved along with the game for it to be resumed.
+type ModeSaver interface {
+	Mode
+	// SaveProgress returns the mode's progress through the game.
+	SaveProgress() ModeProgress
+	// RestoreProgress carries on from progress returned by SaveProgress.
+	RestoreProgress(p ModeProgress) error
+}
+
+// Save returns everything needed to carry the game on later with Restore.
+func (s *State) Save() *Saved {
+	saved := &Saved{
+		Version:    StateVersion,
+		Config:     s.config,
+		FixedQueue: s.fixedQueue,
+		Stats:      s.Stats(),
+		Frame:      s.frame,
+		Paused:     s.paused,
+		Countdown:  s.countdown,
+		GameOver:   s.gameOver,
+		ToppedOut:  s.toppedOut,
+
+		Score:        s.score,
+		Level:        s.level,
+		Gravity:      s.gravity,
+		FallProgress: s.fallProgress,
+		LockDelay:    s.lockDelay,
+		LockTimer:    s.lockTimer,
+		ARE:          s.are,
+		ARETimer:     s.areTimer,
+		LastRotated:  s.lastRotated,
+		Practice:     s.practice,
+		Fade:         s.fade,
+
+		Attack:     s.attack,
+		Combo:      s.combo,
+		BackToBack: s.backToBack,
+		Incoming:   append([]int(nil), s.incoming...),
+		Hole:       s.hole,
+
+		Recording: s.recording,
+		Records:   append([]Record(nil), s.records...),
+	}
+	saved.Board = boardText(s.board)
+	for row := len(s.board) - 1; row >= 0 && s.fade != nil; row-- {
+		lockedAt := make([]int, s.config.Width)
+		for col, b := range s.board[row] {
+			if b != nil {
+				lockedAt[col] = b.lockedAt
+			}
+		}
+		saved.LockedAt = append(saved.LockedAt, lockedAt)
+	}
+	if s.fallingPiece != nil {
+		origin := s.fallingPiece.Origin()
+		saved.Piece = &SavedPiece{
+			Kind:     kindsText([]tetronimoes.Kind{s.fallingPiece.Kind()}),
+			Rotation: rotation(s.fallingPiece),
+			X:        int(origin.X),
+			Y:        int(origin.Y),
+		}
+	}
+	saved.Queue = kindsText(s.queue)
+	for _, snap := range s.history {
+		saved.History = append(saved.History, SavedSnapshot{
+			Board:      boardText(snap.board),
+			Piece:      kindsText([]tetronimoes.Kind{snap.piece}),
+			Queue:      kindsText(snap.queue),
+			Stats:      snap.stats,
+			Score:      snap.score,
+			Combo:      snap.combo,
+			BackToBack: snap.backToBack,
+		})
+	}
+	saved.Rand.Seed, saved.Rand.Drawn = s.rng.Position()
+	saved.GarbageRand.Seed, saved.GarbageRand.Drawn = s.garbageRng.Position()
+	if m, ok := s.mode.(ModeSaver); ok {
+		saved.Mode = m.SaveProgress()
+	}
+	return saved
+}
+
+// Restore carries on a saved game, with the same mode that it was played
+// with. The mode isn't started again, but if it's a ModeSaver then its
+// progress is restored. It's an error if the saved game doesn't make sense.
+func Restore(saved *Saved, mode Mode) (*State, error) {
+	c := saved.Config
+	if err := c.Validate(); err != nil {
+		return nil, err
+	}
+	s := NewState(c)
+	board, err := parseBoard(saved.Board, c)
+	if err != nil {
+		return nil, err
+	}
+	s.board = board
+	if saved.LockedAt != nil {
+		if len(saved.LockedAt) != len(s.board) {
+			return nil, fmt.Errorf("saved lock times have %d rows, expected %d", len(saved.LockedAt), len(s.board))
+		}
+		for i, times := range saved.LockedAt {
+			row := len(s.board) - 1 - i
+			if len(times) != c.Width {
+				return nil, fmt.Errorf("saved lock times for row %d have %d cells, expected %d", row, len(times), c.Width)
+			}
+			for col, b := range s.board[row] {
+				if b != nil {
+					b.lockedAt = times[col]
+				}
+			}
+		}
+	}
+	if s.queue, err = parseKinds(saved.Queue); err != nil {
+		return nil, err
+	}
+	for _, saved := range saved.History {
+		snap := snapshot{
+			stats:      saved.Stats,
+			score:      saved.Score,
+			combo:      saved.Combo,
+			backToBack: saved.BackToBack,
+		}
+		if snap.board, err = parseBoard(saved.Board, c); err != nil {
+			return nil, err
+		}
+		if snap.queue, err = parseKinds(saved.Queue); err != nil {
+			return nil, err
+		}
+		piece, err := parseKinds(saved.Piece)
+		if err != nil {
+			return nil, err
+		}
+		if len(piece) != 1 {
+			return nil, fmt.Errorf("saved snapshot has piece %q, expected one letter", saved.Piece)
+		}
+		snap.piece = piece[0]
+		s.history = append(s.history, snap)
+	}
+	if p := saved.Piece; p != nil {
+		kind, err := parseKinds(p.Kind)
+		if err != nil {
+			return nil, err
+		}
+		if len(kind) != 1 {
+			return nil, fmt.Errorf("saved piece is %q, expected one letter", p.Kind)
+		}
+		piece := tetronimoes.NewShape(kind[0])
+		if p.Rotation < 0 || p.Rotation > 3 {
+			return nil, fmt.Errorf("saved piece has rotation %d, expected 0 to 3", p.Rotation)
+		}
+		for i := 0; i < p.Rotation; i++ {
+			piece.RotateClockwise()
+		}
+		*piece.Origin() = tetronimoes.Point{X: float32(p.X), Y: float32(p.Y)}
+		if s.BoardIntersects(piece) {
+			return nil, fmt.Errorf("saved piece at %d,%d overlaps the board", p.X, p.Y)
+		}
+		s.fallingPiece = piece
+	}
+	if saved.Gravity.Rows < 0 || saved.Gravity.Ticks < 1 {
+		return nil, fmt.Errorf("bad saved gravity %d rows every %d ticks", saved.Gravity.Rows, saved.Gravity.Ticks)
+	}
+
+	s.fixedQueue = saved.FixedQueue
+	s.rng = NewRandAt(saved.Rand.Seed, saved.Rand.Drawn)
+	s.stats = saved.Stats
+	s.ticks = saved.Stats.Ticks
+	s.frame = saved.Frame
+	s.paused = saved.Paused
+	s.countdown = saved.Countdown
+	s.gameOver = saved.GameOver
+	s.toppedOut = saved.ToppedOut
+
+	s.score = saved.Score
+	s.level = saved.Level
+	s.gravity = saved.Gravity
+	s.fallProgress = saved.FallProgress
+	s.lockDelay = saved.LockDelay
+	s.lockTimer = saved.LockTimer
+	s.are = saved.ARE
+	s.areTimer = saved.ARETimer
+	s.lastRotated = saved.LastRotated
+	s.practice = saved.Practice
+	s.fade = saved.Fade
+
+	s.attack = saved.Attack
+	s.combo = saved.Combo
+	s.backToBack = saved.BackToBack
+	s.incoming = append([]int(nil), saved.Incoming...)
+	s.garbageRng = NewRandAt(saved.GarbageRand.Seed, saved.GarbageRand.Drawn)
+	s.hole = saved.Hole
+
+	s.recording = saved.Recording
+	s.records = append([]Record(nil), saved.Records...)
+
+	s.mode = mode
+	if m, ok := mode.(ModeSaver); ok {
+		if err := m.RestoreProgress(saved.Mode); err != nil {
+			return nil, err
+		}
+	}
+	return s, nil
+}
+
+// boardText returns every row of a board as text like Rows, hidden rows
+// included, top first.
+func boardText(board [][]*block) []string {
+	var rows []string
+	for row := len(board) - 1; row >= 0; row-- {
+		rows = append(rows, rowText(board[row]))
+	}
+	return rows
+}
+
+// parseBoard reads a board written by boardText, for a game with the given
+// config.
+func parseBoard(rows []string, c Config) ([][]*block, error) {
+	if len(rows) != c.Height+c.BufferHeight {
+		return nil, fmt.Errorf("saved board has %d rows, expected %d", len(rows), c.Height+c.BufferHeight)
+	}
+	board := make([][]*block, len(rows))
+	for i, line := range rows {
+		row := len(rows) - 1 - i
+		if len(line) != c.Width {
+			return nil, fmt.Errorf("saved board row %d has %d cells, expected %d", row, len(line), c.Width)
+		}
+		board[row] = make([]*block, c.Width)
+		for col := 0; col < c.Width; col++ {
+			b, err := newBlock(line[col])
+			if err != nil {
+				return nil, fmt.Errorf("%v in saved board", err)
+			}
+			board[row][col] = b
+		}
+	}
+	return board, nil
+}
+
+// kindsText returns the letters of kinds of pieces.
+func kindsText(kinds []tetronimoes.Kind) string {
+	text := make([]byte, len(kinds))
+	for i, kind := range kinds {
+		text[i] = byte(kind)
+	}
+	return string(text)
+}
+
+// parseKinds reads the letters of kinds of pieces.
+func parseKinds(text string) ([]tetronimoes.Kind, error) {
+	var kinds []tetronimoes.Kind
+	for _, c := range []byte(text) {
+		if tetronimoes.NewShape(tetronimoes.Kind(c)) == nil {
+			return nil, fmt.Errorf("unknown piece %q", c)
+		}
+		kinds = append(kinds, tetronimoes.Kind(c))
+	}
+	return kinds, nil
+}
+
+// rotation returns the number of times a shape has been rotated clockwise
+// from how it spawned.
+func rotation(shape *tetronimoes.Shape) int {
+	spawned := tetronimoes.NewShape(shape.Kind())
+	for r := 0; r < 4; r++ {
+		if samePoints(spawned.Points(), shape.Points()) {
+			return r
+		}
+		spawned.RotateClockwise()
+	}
+	return 0
+}
+
+func samePoints(a, b [][]bool) bool {
+	if len(a) != len(b) {
+		return false
+	}
+	for row := range a {
+		if len(a[row]) != len(b[row]) {
+			return false
+		}
+		for col := range a[row] {
+			if a[row][col] != b[row][col] {
+				return false
+			}
+		}
+	}
+	return true
+}
@@ -0,0 +1,75 @@
+package main
+
+import (
+	"github.com/omustardo/tetris/glfw-tetris/gamestate"
+	"github.com/omustardo/tetris/glfw-tetris/window/gamepad"
+	"github.com/omustardo/tetris/glfw-tetris/window/keyboard"
+	"github.com/omustardo/tetris/glfw-tetris/window/mouse"
+)
+
+// keyboardInput returns the keys that were just pressed by a player using the
+// given bindings to move their piece. The keys to pause, restart and practice
+// are shared by every player.
+func keyboardInput(h *keyboard.Handler, b keyboard.Bindings) gamestate.Input {
+	return gamestate.Input{
+		Left:                   h.IsKeyDown(b.Left) && !h.WasKeyDown(b.Left),
+		Right:                  h.IsKeyDown(b.Right) && !h.WasKeyDown(b.Right),
+		RotateClockwise:        h.IsKeyDown(b.RotateClockwise) && !h.WasKeyDown(b.RotateClockwise),
+		RotateCounterClockwise: h.IsKeyDown(b.RotateCounterClockwise) && !h.WasKeyDown(b.RotateCounterClockwise),
+		HardDrop:               h.IsKeyDown(b.HardDrop) && !h.WasKeyDown(b.HardDrop),
+		SoftDrop:               h.IsKeyDown(b.SoftDrop) && !h.WasKeyDown(b.SoftDrop),
+		Pause:                  h.PausePressed() && !h.WasPausePressed(),
+		Restart:                h.RestartPressed() && !h.WasRestartPressed(),
+		Undo:                   h.UndoPressed() && !h.WasUndoPressed(),
+		Cycle:                  h.CyclePressed() && !h.WasCyclePressed(),
+		Swap:                   h.SwapPressed() && !h.WasSwapPressed(),
+		Target:                 pressedTarget(h),
+	}
+}
+
+// pressedTarget returns which of keyboard.TargetKeys was just pressed,
+// counting from 1, or zero if none were.
+func pressedTarget(h *keyboard.Handler) int {
+	for i, key := range keyboard.TargetKeys {
+		if h.IsKeyDown(key) && !h.WasKeyDown(key) {
+			return i + 1
+		}
+	}
+	return 0
+}
+
+// gamepadInput returns the buttons that were just pressed on a gamepad. The
+// left stick moves the piece, pushing it up drops it and pushing it down soft
+// drops it, A and B rotate, and Start pauses.
+func gamepadInput(h *gamepad.Handler) gamestate.Input {
+	pressed := func(b gamepad.Button) bool {
+		return h.IsButtonDown(b) && !h.WasButtonDown(b)
+	}
+	return gamestate.Input{
+		Left:                   pressed(gamepad.Left),
+		Right:                  pressed(gamepad.Right),
+		RotateClockwise:        pressed(gamepad.A),
+		RotateCounterClockwise: pressed(gamepad.B),
+		HardDrop:               pressed(gamepad.Up),
+		SoftDrop:               pressed(gamepad.Down),
+		Pause:                  pressed(gamepad.Start),
+	}
+}
+
+// applyMouse paints blocks onto the board while practicing: the left button
+// fills the cell under the cursor and the right button empties it. The area
+// is the one that the game is drawn in, with its bottom left corner at (x,y).
+func applyMouse(s *gamestate.State, h *mouse.Handler, x, y, width, height float32) {
+	if !s.Practice() || s.Paused() {
+		return
+	}
+	left, right := h.LeftPressed(), h.RightPressed()
+	if !left && !right {
+		return
+	}
+	// The cursor has y=0 at the top of the window, but the area has it at the
+	// bottom.
+	if col, row, ok := s.CellAt(float32(h.X), y+height-float32(h.Y), x, y, width, height); ok {
+		s.Paint(col, row, left)
+	}
+}
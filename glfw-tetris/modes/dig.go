@@ -0,0 +1,176 @@
+package modes
+
+import (
+	"fmt"
+
+	"github.com/omustardo/tetris/glfw-tetris/gamestate"
+)
+
+// digRows is the number of garbage rows kept on the board in Dig, until there
+// are fewer than that left to clear.
+const digRows = 10
+
+// digSeed is mixed into the game's seed for the garbage's random numbers.
+const digSeed = 0xbb67ae85
+
+// DefaultMessiness moves the hole in every garbage row.
+const DefaultMessiness = 1.0
+
+// Dig is won by clearing a number of garbage lines as quickly as possible.
+// The board starts with rows of garbage, and more are pushed up from below as
+// they're cleared. The garbage comes from the game's seed, so two games with
+// the same seed dig through the same rows.
+type Dig struct {
+	name  string
+	lines int // Number of garbage lines to clear.
+	// messiness is the chance, from 0 to 1, that the hole in each garbage row
+	// is in a different column from the row above it.
+	messiness float64
+
+	rng     *gamestate.Rand
+	hole    int // Column of the hole in the last garbage row added.
+	added   int // Number of garbage rows added so far.
+	cleared int // Number of garbage rows cleared so far.
+
+	// Filled in once the game is over.
+	finished    bool // Whether the goal was reached, rather than topping out.
+	previous    int  // The personal best before this game, in ticks.
+	hadPrevious bool
+	newBest     bool
+}
+
+// NewDig creates a mode with the given number of garbage lines to clear. The
+// messiness is clamped to between 0, where the holes line up in a single
+// column, and 1, where every hole is in a different column from the last.
+func NewDig(lines int, messiness float64) *Dig {
+	if messiness < 0 {
+		messiness = 0
+	}
+	if messiness > 1 {
+		messiness = 1
+	}
+	return &Dig{name: fmt.Sprintf("dig%d", lines), lines: lines, messiness: messiness}
+}
+
+func (m *Dig) Start(s *gamestate.State) {
+	*m = Dig{name: m.name, lines: m.lines, messiness: m.messiness}
+	// The garbage gets its own generator so it doesn't depend on how many
+	// pieces have been drawn, seeded differently so its numbers aren't the
+	// same as the pieces'.
+	m.rng = gamestate.NewRand(s.Seed() ^ digSeed)
+	m.hole = m.rng.Intn(s.Width())
+	m.refill(s)
+}
+
+func (m *Dig) Copy() gamestate.Mode {
+	c := *m
+	if m.rng != nil {
+		c.rng = m.rng.Copy()
+	}
+	return &c
+}
+
+func (m *Dig) SaveProgress() gamestate.ModeProgress {
+	seed, drawn := m.rng.Position()
+	return gamestate.ModeProgress{
+		"seed":    seed,
+		"drawn":   int64(drawn),
+		"hole":    int64(m.hole),
+		"added":   int64(m.added),
+		"cleared": int64(m.cleared),
+	}
+}
+
+func (m *Dig) RestoreProgress(p gamestate.ModeProgress) error {
+	m.rng = gamestate.NewRandAt(p["seed"], int(p["drawn"]))
+	m.hole, m.added, m.cleared = int(p["hole"]), int(p["added"]), int(p["cleared"])
+	return nil
+}
+
+func (m *Dig) Tick(s *gamestate.State) {}
+
+func (m *Dig) HandleEvent(s *gamestate.State, e gamestate.Event) {
+	switch e.Type {
+	case gamestate.LinesCleared:
+		m.cleared += e.Garbage
+		if m.cleared >= m.lines {
+			s.End()
+			return
+		}
+		m.refill(s)
+	case gamestate.GameEnded:
+		if s.ToppedOut() {
+			m.previous, m.hadPrevious = best(m.name)
+			return
+		}
+		m.finished = true
+		m.previous, m.hadPrevious, m.newBest = submit(s, m.name, e.Tick, true)
+	}
+}
+
+// refill pushes up enough garbage to bring the board back to digRows rows of
+// it, without adding more than are left to clear.
+func (m *Dig) refill(s *gamestate.State) {
+	n := digRows - s.GarbageRows()
+	if left := m.lines - m.added; n > left {
+		n = left
+	}
+	if n <= 0 {
+		return
+	}
+	holes := make([]int, n)
+	for i := range holes {
+		if m.added+i > 0 {
+			m.moveHole(s.Width())
+		}
+		holes[i] = m.hole
+	}
+	m.added += n
+	s.AddGarbage(holes)
+}
+
+// moveHole picks the column of the hole in the next garbage row, which may
+// be the same as the last one.
+func (m *Dig) moveHole(width int) {
+	if m.rng.Float64() >= m.messiness {
+		return
+	}
+	// Move to any other column.
+	hole := m.rng.Intn(width - 1)
+	if hole >= m.hole {
+		hole++
+	}
+	m.hole = hole
+}
+
+func (m *Dig) HUD(s *gamestate.State) []string {
+	left := m.lines - m.cleared
+	if left < 0 {
+		left = 0
+	}
+	return []string{
+		gamestate.FormatTicks(s.Stats().Ticks),
+		fmt.Sprintf("%d GARBAGE LEFT", left),
+	}
+}
+
+func (m *Dig) Results(s *gamestate.State) []string {
+	stats := s.Stats()
+	results := []string{fmt.Sprintf("DIG %d", m.lines)}
+	if m.finished {
+		results = append(results, "TIME "+gamestate.FormatTicks(stats.Ticks))
+	} else {
+		results = append(results, "FAILED", fmt.Sprintf("%d GARBAGE LEFT", m.lines-m.cleared))
+	}
+	results = append(results,
+		fmt.Sprintf("PIECES %d", stats.Pieces),
+		fmt.Sprintf("PPS %.2f", stats.PiecesPerSecond()),
+	)
+	switch {
+	case m.newBest:
+		results = append(results, "NEW BEST!")
+	case m.hadPrevious:
+		results = append(results, "BEST "+gamestate.FormatTicks(m.previous))
+	}
+	return results
+}
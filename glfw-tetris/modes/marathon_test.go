@@ -0,0 +1,63 @@
+package modes
+
+import (
+	"testing"
+
+	"github.com/omustardo/tetris/glfw-tetris/gamestate"
+)
+
+func TestMarathonLockDelay(t *testing.T) {
+	s := gamestate.NewState(gamestate.DefaultConfig())
+	m := NewMarathon(150)
+	s.SetMode(m)
+	// The last level drops pieces to the floor on the tick that they spawn.
+	m.setLevel(s, len(marathonGravity))
+	s.Tick()
+	_, landed := s.PieceCells()
+	if len(landed) == 0 {
+		t.Fatal("no piece spawned")
+	}
+	if lowest(landed) != 0 {
+		t.Fatalf("piece spawned at %v, not dropped to the floor", landed)
+	}
+
+	// It can still be moved before it locks. The tick that it landed on
+	// counts towards the lock delay.
+	for tick := 1; tick < marathonLockDelay-1; tick++ {
+		s.Apply(gamestate.Input{Left: true})
+		s.Tick()
+		if s.Stats().Pieces != 0 {
+			t.Fatalf("piece locked %d ticks after landing", tick)
+		}
+	}
+	_, moved := s.PieceCells()
+	if leftmost(moved) != 0 || leftmost(landed) == 0 {
+		t.Errorf("piece moved from %v to %v, not to the left wall", landed, moved)
+	}
+	s.Tick()
+	if s.Stats().Pieces != 1 {
+		t.Errorf("piece didn't lock %d ticks after landing", marathonLockDelay-1)
+	}
+}
+
+// lowest returns the lowest row of the cells.
+func lowest(cells [][2]int) int {
+	row := cells[0][1]
+	for _, c := range cells {
+		if c[1] < row {
+			row = c[1]
+		}
+	}
+	return row
+}
+
+// leftmost returns the leftmost column of the cells.
+func leftmost(cells [][2]int) int {
+	col := cells[0][0]
+	for _, c := range cells {
+		if c[0] < col {
+			col = c[0]
+		}
+	}
+	return col
+}
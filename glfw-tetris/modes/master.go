@@ -0,0 +1,248 @@
+package modes
+
+import (
+	"fmt"
+
+	"github.com/omustardo/tetris/glfw-tetris/gamestate"
+)
+
+// Master follows the rules of The Tetris Grand Master. The level goes up by
+// one for each piece and for each line cleared, and the game ends at level
+// 999. Pieces speed up to 20G, falling to the bottom instantly, and later the
+// lock delay and the pause before each piece spawns get shorter. A grade is
+// awarded based on the score.
+type Master struct {
+	name    string
+	twentyG bool // Whether pieces drop at 20G from the start.
+
+	level  int
+	score  int
+	combo  int
+	grade  int  // Index into masterGrades.
+	gmTest bool // Whether the conditions for the GM grade have been met so far.
+	spawns int
+
+	// Filled in once the game is over.
+	previous    int // The personal best grade before this game.
+	hadPrevious bool
+	newBest     bool
+}
+
+const (
+	masterMaxLevel = 999
+	// Each section is this many levels. The level stops at the last level of a
+	// section until a line is cleared.
+	masterSection = 100
+)
+
+// masterGravity is the speed that pieces fall at from each level onwards, in
+// 256ths of a row per tick. It starts at 4/256 and tops out at 20 rows per
+// tick, which drops pieces to the bottom instantly.
+var masterGravity = []struct {
+	level, gravity int
+}{
+	{0, 4}, {30, 6}, {35, 8}, {40, 10}, {50, 12}, {60, 16}, {70, 32}, {80, 48},
+	{90, 64}, {100, 80}, {120, 96}, {140, 112}, {160, 128}, {170, 144},
+	{200, 4}, {220, 32}, {230, 64}, {233, 96}, {236, 128}, {239, 160},
+	{243, 192}, {247, 224}, {251, 256}, {300, 512}, {330, 768}, {360, 1024},
+	{400, 1280}, {420, 1024}, {450, 768}, {500, 20 * 256},
+}
+
+// masterTiming is the lock delay and ARE, in ticks, from each level onwards.
+var masterTiming = []struct {
+	level, lockDelay, are int
+}{
+	{0, 30, 25},
+	{600, 27, 18},
+	{700, 24, 14},
+	{800, 21, 12},
+	{900, 18, 10},
+}
+
+// masterGrades are awarded for reaching each score, from the lowest grade to
+// the highest.
+var masterGrades = []struct {
+	name  string
+	score int
+}{
+	{"9", 0}, {"8", 400}, {"7", 800}, {"6", 1400}, {"5", 2000}, {"4", 3500},
+	{"3", 5500}, {"2", 8000}, {"1", 12000}, {"S1", 16000}, {"S2", 22000},
+	{"S3", 30000}, {"S4", 40000}, {"S5", 52000}, {"S6", 66000},
+	{"S7", 82000}, {"S8", 100000}, {"S9", 120000},
+}
+
+// The GM grade is awarded for finishing with grade S9, having also reached
+// each of these grades by the given level within the time limit.
+var masterGMTests = []struct {
+	level, grade, ticks int
+}{
+	{300, 12, (4*60 + 15) * gamestate.TicksPerSecond},  // S4 in 4:15
+	{500, 15, (7*60 + 30) * gamestate.TicksPerSecond},  // S7 in 7:30
+	{999, 17, (13*60 + 30) * gamestate.TicksPerSecond}, // S9 in 13:30
+}
+
+// masterGM is the index of the GM grade, which comes after all of
+// masterGrades.
+var masterGM = len(masterGrades)
+
+func NewMaster(twentyG bool) *Master {
+	name := "master"
+	if twentyG {
+		name = "20g"
+	}
+	return &Master{name: name, twentyG: twentyG, combo: 1, gmTest: true}
+}
+
+func (m *Master) Start(s *gamestate.State) {
+	*m = *NewMaster(m.twentyG)
+	m.update(s)
+}
+
+func (m *Master) Copy() gamestate.Mode {
+	c := *m
+	return &c
+}
+
+func (m *Master) SaveProgress() gamestate.ModeProgress {
+	p := gamestate.ModeProgress{
+		"level":  int64(m.level),
+		"score":  int64(m.score),
+		"combo":  int64(m.combo),
+		"grade":  int64(m.grade),
+		"spawns": int64(m.spawns),
+	}
+	if m.gmTest {
+		p["gm_test"] = 1
+	}
+	return p
+}
+
+func (m *Master) RestoreProgress(p gamestate.ModeProgress) error {
+	if p["grade"] < 0 || p["grade"] >= int64(len(masterGrades)) {
+		return fmt.Errorf("saved grade %d is out of range", p["grade"])
+	}
+	m.level, m.score, m.combo, m.grade = int(p["level"]), int(p["score"]), int(p["combo"]), int(p["grade"])
+	m.spawns, m.gmTest = int(p["spawns"]), p["gm_test"] != 0
+	return nil
+}
+
+func (m *Master) Tick(s *gamestate.State) {}
+
+func (m *Master) HandleEvent(s *gamestate.State, e gamestate.Event) {
+	switch e.Type {
+	case gamestate.PieceSpawned:
+		// Each new piece raises the level, apart from the first one.
+		m.spawns++
+		if m.spawns > 1 && m.level%masterSection != masterSection-1 && m.level != masterMaxLevel-1 {
+			m.setLevel(s, m.level+1)
+		}
+	case gamestate.PieceLocked:
+		if e.Lines == 0 {
+			m.combo = 1
+			return
+		}
+		m.combo += 2*e.Lines - 2
+		points := ((m.level + e.Lines + 3) / 4) * e.Lines * m.combo
+		if s.BoardEmpty() {
+			points *= 4
+		}
+		m.score += points
+		for m.grade+1 < len(masterGrades) && m.score >= masterGrades[m.grade+1].score {
+			m.grade++
+		}
+		m.setLevel(s, m.level+e.Lines)
+	case gamestate.GameEnded:
+		m.previous, m.hadPrevious, m.newBest = submit(s, m.name, m.finalGrade(), false)
+	}
+}
+
+// setLevel moves to a new level, checking the conditions for the GM grade
+// along the way, and ends the game at the maximum level.
+func (m *Master) setLevel(s *gamestate.State, level int) {
+	if level > masterMaxLevel {
+		level = masterMaxLevel
+	}
+	for _, test := range masterGMTests {
+		if m.level < test.level && level >= test.level {
+			if m.grade < test.grade || s.Stats().Ticks > test.ticks {
+				m.gmTest = false
+			}
+		}
+	}
+	m.level = level
+	m.update(s)
+	if m.level >= masterMaxLevel {
+		s.End()
+	}
+}
+
+// update sets the speed of the game for the current level.
+func (m *Master) update(s *gamestate.State) {
+	gravity := 0
+	for _, g := range masterGravity {
+		if m.level >= g.level {
+			gravity = g.gravity
+		}
+	}
+	if m.twentyG {
+		gravity = 20 * 256
+	}
+	s.SetGravity(gamestate.Gravity{Rows: gravity, Ticks: 256})
+	for _, t := range masterTiming {
+		if m.level >= t.level {
+			s.SetLockDelay(t.lockDelay)
+			s.SetARE(t.are)
+		}
+	}
+}
+
+// finalGrade returns the grade earned by the game, which is GM if the game
+// was finished having passed every test along the way.
+func (m *Master) finalGrade() int {
+	if m.level >= masterMaxLevel && m.gmTest {
+		return masterGM
+	}
+	return m.grade
+}
+
+// gradeName returns the name of a grade, including GM.
+func gradeName(grade int) string {
+	if grade >= masterGM {
+		return "GM"
+	}
+	return masterGrades[grade].name
+}
+
+// nextLevelStop returns the level that the level will stop at until a line is
+// cleared.
+func (m *Master) nextLevelStop() int {
+	stop := (m.level/masterSection + 1) * masterSection
+	if stop > masterMaxLevel {
+		stop = masterMaxLevel
+	}
+	return stop
+}
+
+func (m *Master) HUD(s *gamestate.State) []string {
+	return []string{
+		gamestate.FormatTicks(s.Stats().Ticks),
+		fmt.Sprintf("LEVEL %d/%d", m.level, m.nextLevelStop()),
+		"GRADE " + gradeName(m.grade),
+	}
+}
+
+func (m *Master) Results(s *gamestate.State) []string {
+	results := []string{
+		"GRADE " + gradeName(m.finalGrade()),
+		fmt.Sprintf("LEVEL %d", m.level),
+		"TIME " + gamestate.FormatTicks(s.Stats().Ticks),
+		fmt.Sprintf("SCORE %d", m.score),
+	}
+	switch {
+	case m.newBest:
+		results = append(results, "NEW BEST!")
+	case m.hadPrevious:
+		results = append(results, "BEST "+gradeName(m.previous))
+	}
+	return results
+}
@@ -0,0 +1,146 @@
+package modes
+
+import (
+	"fmt"
+	"log"
+	"strings"
+
+	"github.com/omustardo/tetris/glfw-tetris/gamestate"
+)
+
+// Puzzles plays through a pack of puzzles, one per game. Restarting the game
+// retries the current puzzle, or moves on to the next one once it's solved.
+type Puzzles struct {
+	pack  []*Puzzle
+	index int // Index in pack of the current puzzle.
+
+	// Progress on the current puzzle.
+	lines  int // Number of lines cleared.
+	solved bool
+	err    error // Why the puzzle can't be played, if it can't.
+}
+
+// NewPuzzles creates a mode playing through the given puzzles in order. If
+// there are none then the pack that comes with the game is used.
+func NewPuzzles(pack []*Puzzle) *Puzzles {
+	if len(pack) == 0 {
+		pack = builtinPuzzles()
+	}
+	return &Puzzles{pack: pack}
+}
+
+func (m *Puzzles) puzzle() *Puzzle {
+	return m.pack[m.index]
+}
+
+// Puzzle returns the puzzle being played.
+func (m *Puzzles) Puzzle() *Puzzle {
+	return m.puzzle()
+}
+
+func (m *Puzzles) Start(s *gamestate.State) {
+	if m.solved {
+		m.index = (m.index + 1) % len(m.pack)
+	}
+	m.lines, m.solved, m.err = 0, false, nil
+
+	p := m.puzzle()
+	if m.err = setBoard(s, p.Board); m.err != nil {
+		log.Printf("Puzzle %q: %v", p.Name, m.err)
+		s.End()
+		return
+	}
+	s.SetQueue(p.Pieces, true)
+}
+
+// setBoard fills in the bottom of the board with rows written as in puzzle
+// files, top first. It's an error if they don't fit.
+func setBoard(s *gamestate.State, board []string) error {
+	if len(board) > 0 && len(board[0]) != s.Width() {
+		return fmt.Errorf("board must be %d wide, got %d", s.Width(), len(board[0]))
+	}
+	if len(board) > s.Height() {
+		return fmt.Errorf("board must be at most %d high, got %d", s.Height(), len(board))
+	}
+	for i, line := range board {
+		row := len(board) - 1 - i
+		for col, c := range line {
+			if kind, filled, _ := boardKind(c); filled {
+				s.SetCell(col, row, kind)
+			}
+		}
+	}
+	return nil
+}
+
+func (m *Puzzles) Copy() gamestate.Mode {
+	c := *m
+	return &c
+}
+
+func (m *Puzzles) SaveProgress() gamestate.ModeProgress {
+	p := gamestate.ModeProgress{"index": int64(m.index), "lines": int64(m.lines)}
+	if m.solved {
+		p["solved"] = 1
+	}
+	return p
+}
+
+func (m *Puzzles) RestoreProgress(p gamestate.ModeProgress) error {
+	if p["index"] < 0 || p["index"] >= int64(len(m.pack)) {
+		return fmt.Errorf("saved puzzle %d is past the end of the %d puzzles", p["index"], len(m.pack))
+	}
+	m.index, m.lines, m.solved = int(p["index"]), int(p["lines"]), p["solved"] != 0
+	return nil
+}
+
+func (m *Puzzles) Tick(s *gamestate.State) {}
+
+func (m *Puzzles) HandleEvent(s *gamestate.State, e gamestate.Event) {
+	if e.Type != gamestate.PieceLocked {
+		return
+	}
+	goal := m.puzzle().Goal
+	m.lines += e.Lines
+	switch goal.Type {
+	case GoalLines:
+		m.solved = m.lines >= goal.N
+	case GoalPerfectClear:
+		m.solved = e.Lines > 0 && s.BoardEmpty()
+	case GoalTSpin:
+		m.solved = e.TSpin && e.Lines == goal.N
+	}
+	if m.solved {
+		s.End()
+	}
+}
+
+func (m *Puzzles) HUD(s *gamestate.State) []string {
+	return []string{
+		strings.ToUpper(fmt.Sprintf("%d/%d %s", m.index+1, len(m.pack), m.puzzle().Name)),
+		strings.ToUpper(m.puzzle().Goal.String()),
+		nextPieces(s),
+	}
+}
+
+// nextPieces returns a HUD line listing the upcoming pieces.
+func nextPieces(s *gamestate.State) string {
+	var next []string
+	for _, kind := range s.Queue() {
+		next = append(next, string(kind))
+	}
+	return "NEXT " + strings.Join(next, " ")
+}
+
+func (m *Puzzles) Results(s *gamestate.State) []string {
+	results := []string{strings.ToUpper(m.puzzle().Name)}
+	switch {
+	case m.err != nil:
+		return append(results, strings.ToUpper(m.err.Error()))
+	case !m.solved:
+		return append(results, "FAILED", "R TO RETRY")
+	case m.index == len(m.pack)-1:
+		return append(results, "SOLVED!", "PACK COMPLETE", "R TO START OVER")
+	}
+	return append(results, "SOLVED!", "R FOR NEXT PUZZLE")
+}
@@ -0,0 +1,83 @@
+package modes
+
+import (
+	"fmt"
+
+	"github.com/omustardo/tetris/glfw-tetris/gamestate"
+)
+
+// Sprint is won by clearing a number of lines as quickly as possible.
+type Sprint struct {
+	name  string
+	lines int // Number of lines to clear.
+
+	// Filled in once the game is over.
+	finished    bool // Whether the goal was reached, rather than topping out.
+	previous    int  // The personal best before this game, in ticks.
+	hadPrevious bool
+	newBest     bool
+}
+
+func NewSprint(lines int) *Sprint {
+	return &Sprint{name: fmt.Sprintf("sprint%d", lines), lines: lines}
+}
+
+func (m *Sprint) Start(s *gamestate.State) {
+	*m = Sprint{name: m.name, lines: m.lines}
+}
+
+func (m *Sprint) Copy() gamestate.Mode {
+	c := *m
+	return &c
+}
+
+func (m *Sprint) Tick(s *gamestate.State) {}
+
+func (m *Sprint) HandleEvent(s *gamestate.State, e gamestate.Event) {
+	switch e.Type {
+	case gamestate.LinesCleared:
+		if s.Stats().Lines >= m.lines {
+			s.End()
+		}
+	case gamestate.GameEnded:
+		if s.ToppedOut() {
+			m.previous, m.hadPrevious = best(m.name)
+			return
+		}
+		m.finished = true
+		m.previous, m.hadPrevious, m.newBest = submit(s, m.name, e.Tick, true)
+	}
+}
+
+func (m *Sprint) HUD(s *gamestate.State) []string {
+	stats := s.Stats()
+	lines := m.lines - stats.Lines
+	if lines < 0 {
+		lines = 0
+	}
+	return []string{
+		gamestate.FormatTicks(stats.Ticks),
+		fmt.Sprintf("%d LINES LEFT", lines),
+	}
+}
+
+func (m *Sprint) Results(s *gamestate.State) []string {
+	stats := s.Stats()
+	results := []string{fmt.Sprintf("SPRINT %d", m.lines)}
+	if m.finished {
+		results = append(results, "TIME "+gamestate.FormatTicks(stats.Ticks))
+	} else {
+		results = append(results, "FAILED", fmt.Sprintf("%d LINES LEFT", m.lines-stats.Lines))
+	}
+	results = append(results,
+		fmt.Sprintf("PPS %.2f", stats.PiecesPerSecond()),
+		fmt.Sprintf("KPP %.2f", stats.KeysPerPiece()),
+	)
+	switch {
+	case m.newBest:
+		results = append(results, "NEW BEST!")
+	case m.hadPrevious:
+		results = append(results, "BEST "+gamestate.FormatTicks(m.previous))
+	}
+	return results
+}
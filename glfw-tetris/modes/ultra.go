@@ -0,0 +1,80 @@
+package modes
+
+import (
+	"fmt"
+
+	"github.com/omustardo/tetris/glfw-tetris/gamestate"
+)
+
+// Ultra is won by scoring as many points as possible before time runs out.
+type Ultra struct {
+	name  string
+	limit int // Length of the game, in ticks.
+
+	// Filled in once the game is over.
+	previous    int // The personal best score before this game.
+	hadPrevious bool
+	newBest     bool
+}
+
+func NewUltra(minutes int) *Ultra {
+	return &Ultra{
+		name:  fmt.Sprintf("ultra%d", minutes),
+		limit: minutes * 60 * gamestate.TicksPerSecond,
+	}
+}
+
+func (m *Ultra) Start(s *gamestate.State) {
+	*m = Ultra{name: m.name, limit: m.limit}
+}
+
+func (m *Ultra) Copy() gamestate.Mode {
+	c := *m
+	return &c
+}
+
+func (m *Ultra) Tick(s *gamestate.State) {
+	if s.Stats().Ticks >= m.limit {
+		s.End()
+	}
+}
+
+func (m *Ultra) HandleEvent(s *gamestate.State, e gamestate.Event) {
+	if e.Type != gamestate.GameEnded {
+		return
+	}
+	// Topping out still counts: the score so far is kept.
+	m.previous, m.hadPrevious, m.newBest = submit(s, m.name, s.Score(), false)
+}
+
+func (m *Ultra) HUD(s *gamestate.State) []string {
+	left := m.limit - s.Stats().Ticks
+	if left < 0 {
+		left = 0
+	}
+	return []string{
+		gamestate.FormatTicks(left),
+		fmt.Sprintf("SCORE %d", s.Score()),
+	}
+}
+
+func (m *Ultra) Results(s *gamestate.State) []string {
+	stats := s.Stats()
+	results := []string{fmt.Sprintf("ULTRA %d MIN", m.limit/(60*gamestate.TicksPerSecond))}
+	if s.ToppedOut() {
+		results = append(results, "TOPPED OUT AT "+gamestate.FormatTicks(stats.Ticks))
+	}
+	results = append(results,
+		fmt.Sprintf("SCORE %d", s.Score()),
+		fmt.Sprintf("LINES %d", stats.Lines),
+		fmt.Sprintf("PPS %.2f", stats.PiecesPerSecond()),
+		fmt.Sprintf("KPP %.2f", stats.KeysPerPiece()),
+	)
+	switch {
+	case m.newBest:
+		results = append(results, "NEW BEST!")
+	case m.hadPrevious:
+		results = append(results, fmt.Sprintf("BEST %d", m.previous))
+	}
+	return results
+}
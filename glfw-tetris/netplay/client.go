@@ -0,0 +1,372 @@
+// Package netplay plays versus games and battles against others connected to
+// the same tetris-server. Each player's game runs locally, and the server
+// passes garbage, boards and results between them.
+package netplay
+
+import (
+	"fmt"
+	"log"
+	"strings"
+
+	"github.com/omustardo/tetris/glfw-tetris/gamestate"
+	"github.com/omustardo/tetris/tetris-server/protocol"
+)
+
+// conn is a connection to the server, over any transport.
+type conn interface {
+	Send(protocol.Message) error
+	// Receive blocks until a message arrives, or the connection is closed.
+	Receive() (protocol.Message, error)
+	Close() error
+}
+
+// Client is a connection to the server along with the game being played on
+// it.
+type Client struct {
+	conn     conn
+	received chan protocol.Message
+	lost     chan error // Gets an error once the connection is lost.
+	closed   bool
+
+	name string
+	// names lists everyone in the game, and seat is the player's own index
+	// in it.
+	names []string
+	seat  int
+	// Local is the player's own game, and Remote shows their target's board
+	// as of the last piece they placed. Remote is only ever drawn.
+	Local, Remote *gamestate.State
+	// status is shown over the boards while a game isn't being played.
+	status []string
+	// The player's HUD, as last sent by the server.
+	target    int
+	targeting protocol.Targeting
+	attackers int
+	alive     int
+	kos       int
+	badges    int
+	lastKO    string // Who knocked out who most recently.
+	// Set once the current game is over.
+	finished bool
+	won      bool
+	place    int
+	// canRematch is whether the player can ask for another game.
+	canRematch bool
+	sent       int  // Rows of garbage sent this game.
+	record     bool // Whether to record each of the player's games.
+}
+
+// Connect starts connecting to a server, playing under the given name. The
+// address's format depends on how the game connects: see dial.
+func Connect(addr, name string) (*Client, error) {
+	c, err := dial(addr)
+	if err != nil {
+		return nil, err
+	}
+	client := &Client{
+		conn:     c,
+		received: make(chan protocol.Message, 64),
+		lost:     make(chan error, 1),
+		name:     strings.ToUpper(name),
+		target:   -1,
+	}
+	client.idle("CONNECTING")
+	if err := c.Send(protocol.Message{Type: protocol.Hello, Version: protocol.Version, Name: name}); err != nil {
+		c.Close()
+		return nil, err
+	}
+	go client.receive()
+	return client, nil
+}
+
+// receive passes on messages from the server until the connection is lost.
+func (c *Client) receive() {
+	for {
+		m, err := c.conn.Receive()
+		if err != nil {
+			c.lost <- err
+			return
+		}
+		c.received <- m
+	}
+}
+
+// Players returns the local and remote games, in that order.
+func (c *Client) Players() [2]*gamestate.State {
+	return [2]*gamestate.State{c.Local, c.Remote}
+}
+
+// idle stops any game being played and shows a status over the boards
+// instead.
+func (c *Client) idle(status ...string) {
+	c.status = status
+	if c.Local == nil {
+		config := gamestate.DefaultConfig()
+		c.Local, c.Remote = gamestate.NewState(config), gamestate.NewState(config)
+		c.Local.SetMode(&local{c})
+		c.Remote.SetMode(&remote{c})
+	}
+	c.Local.End()
+	c.Remote.End()
+}
+
+func (c *Client) send(m protocol.Message) {
+	if c.closed {
+		return
+	}
+	if err := c.conn.Send(m); err != nil {
+		c.disconnect(err)
+	}
+}
+
+func (c *Client) disconnect(err error) {
+	if c.closed {
+		return
+	}
+	log.Println("Disconnected from server:", err)
+	c.closed = true
+	c.canRematch = false
+	c.conn.Close()
+	c.idle("DISCONNECTED")
+}
+
+// Update handles any messages that have arrived from the server. It's
+// expected to be called once per frame, before Apply.
+func (c *Client) Update() {
+	for {
+		select {
+		case m := <-c.received:
+			c.handle(m)
+		case err := <-c.lost:
+			c.disconnect(err)
+		default:
+			return
+		}
+	}
+}
+
+func (c *Client) handle(m protocol.Message) {
+	switch m.Type {
+	case protocol.Welcome:
+		c.idle("CONNECTED")
+	case protocol.Waiting:
+		if m.Size > 2 {
+			c.idle("WAITING FOR", "PLAYERS", fmt.Sprintf("%d/%d", m.Players, m.Size))
+		} else {
+			c.idle("WAITING FOR", "OPPONENT")
+		}
+	case protocol.Start:
+		config := gamestate.Config{Width: m.Width, Height: m.Height, BufferHeight: m.BufferHeight, Seed: m.Seed}
+		if err := config.Validate(); err != nil {
+			c.disconnect(fmt.Errorf("server started a bad game: %v", err))
+			return
+		}
+		c.names, c.seat = m.Names, m.Player
+		c.status = nil
+		c.target, c.attackers, c.alive, c.kos, c.badges, c.lastKO = -1, 0, len(m.Names), 0, 0, ""
+		c.finished, c.won, c.place, c.canRematch, c.sent = false, false, 0, false, 0
+		c.Local, c.Remote = gamestate.NewState(config), gamestate.NewState(config)
+		c.Local.SetMode(&local{c})
+		c.Remote.SetMode(&remote{c})
+		if c.record {
+			c.Local.Record()
+		}
+	case protocol.Placed:
+		if m.Player == c.target {
+			c.Remote.SetRows(m.Board)
+		}
+	case protocol.Garbage:
+		c.Local.ReceiveGarbage(m.Rows)
+	case protocol.Status:
+		c.target, c.targeting, c.attackers = m.Player, m.Targeting, m.Attackers
+		c.alive, c.kos, c.badges = m.Alive, m.KOs, m.Badges
+	case protocol.KO:
+		if m.By >= 0 {
+			c.lastKO = fmt.Sprintf("%s OUT BY %s", c.nameOf(m.Player), c.nameOf(m.By))
+		} else {
+			c.lastKO = c.nameOf(m.Player) + " IS OUT"
+		}
+	case protocol.Result:
+		c.finished, c.won, c.place, c.canRematch = true, m.Won, m.Place, true
+		c.kos = m.KOs
+		c.Local.End()
+		c.Remote.End()
+	case protocol.Error:
+		c.disconnect(fmt.Errorf("server error: %s", m.Error))
+	default:
+		log.Printf("Ignoring unexpected message %q from server", m.Type)
+	}
+}
+
+// nameOf returns the name of the player in a seat.
+func (c *Client) nameOf(seat int) string {
+	if seat < 0 || seat >= len(c.names) {
+		return ""
+	}
+	return c.names[seat]
+}
+
+// battle returns whether the game has more than two players.
+func (c *Client) battle() bool {
+	return len(c.names) > 2
+}
+
+// playing returns whether there's a game in progress.
+func (c *Client) playing() bool {
+	return c.status == nil && !c.finished
+}
+
+// Apply the player's input for this tick. Online games can't be paused or
+// restarted, but once one is over restarting asks for a rematch. Targeting
+// modes can be picked at any time, and are kept for later games.
+func (c *Client) Apply(in gamestate.Input) {
+	if in.Target > 0 && in.Target <= len(protocol.Targetings) {
+		c.send(protocol.Message{Type: protocol.Target, Targeting: protocol.Targetings[in.Target-1]})
+	}
+	if in.Restart && c.canRematch {
+		c.canRematch = false
+		c.send(protocol.Message{Type: protocol.Ready})
+		if c.battle() {
+			c.idle("WAITING FOR", "PLAYERS")
+		} else {
+			c.idle("WAITING FOR", "OPPONENT")
+		}
+		return
+	}
+	if !c.playing() {
+		return
+	}
+	c.Local.Apply(gamestate.Input{
+		Left:                   in.Left,
+		Right:                  in.Right,
+		RotateClockwise:        in.RotateClockwise,
+		RotateCounterClockwise: in.RotateCounterClockwise,
+		HardDrop:               in.HardDrop,
+		SoftDrop:               in.SoftDrop,
+	})
+}
+
+// Tick advances the local game.
+func (c *Client) Tick() {
+	if c.playing() {
+		c.Local.Tick()
+	}
+}
+
+// Record records each of the player's games from the next one on, along
+// with the garbage they receive. See State.Record.
+func (c *Client) Record() {
+	c.record = true
+}
+
+// Closed returns whether the connection to the server is gone.
+func (c *Client) Closed() bool {
+	return c.closed
+}
+
+// Close disconnects from the server.
+func (c *Client) Close() {
+	c.closed = true
+	c.conn.Close()
+}
+
+// local is the mode of the player's own game, which reports what happens in
+// it to the server.
+type local struct {
+	c *Client
+}
+
+func (m *local) Start(s *gamestate.State) {}
+
+func (m *local) Tick(s *gamestate.State) {}
+
+func (m *local) HandleEvent(s *gamestate.State, e gamestate.Event) {
+	c := m.c
+	if !c.playing() {
+		return
+	}
+	switch e.Type {
+	case gamestate.PieceLocked:
+		c.send(protocol.Message{Type: protocol.Placed, Piece: string(e.Piece), Board: s.Rows()})
+		if e.Attack > 0 {
+			c.sent += e.Attack
+			c.send(protocol.Message{Type: protocol.Attack, Rows: e.Attack})
+		}
+	case gamestate.GameEnded:
+		if s.ToppedOut() {
+			c.send(protocol.Message{Type: protocol.Lost})
+		}
+	}
+}
+
+func (m *local) HUD(s *gamestate.State) []string {
+	c := m.c
+	hud := []string{c.name}
+	if c.battle() {
+		hud = append(hud, fmt.Sprintf("ALIVE %d/%d KOS %d BADGES %d", c.alive, len(c.names), c.kos, c.badges))
+	}
+	return append(hud, fmt.Sprintf("SENT %d INCOMING %d", c.sent, s.IncomingGarbage()))
+}
+
+func (m *local) Results(s *gamestate.State) []string {
+	c := m.c
+	if c.status != nil {
+		return c.status
+	}
+	if !c.finished {
+		// Topped out, waiting to hear back from the server.
+		return []string{"GAME OVER"}
+	}
+	results := []string{"YOU LOSE"}
+	switch {
+	case c.won:
+		results = []string{"YOU WIN!"}
+	case c.battle():
+		results = []string{fmt.Sprintf("PLACE %d/%d", c.place, len(c.names))}
+	}
+	if c.battle() {
+		results = append(results, fmt.Sprintf("KOS %d", c.kos))
+	}
+	results = append(results, fmt.Sprintf("SENT %d", c.sent))
+	switch {
+	case c.canRematch && c.battle():
+		results = append(results, "R TO PLAY AGAIN")
+	case c.canRematch:
+		results = append(results, "R FOR REMATCH")
+	}
+	return results
+}
+
+// remote is the mode of the game showing the target's board.
+type remote struct {
+	c *Client
+}
+
+func (m *remote) Start(s *gamestate.State) {}
+
+func (m *remote) Tick(s *gamestate.State) {}
+
+func (m *remote) HandleEvent(s *gamestate.State, e gamestate.Event) {}
+
+func (m *remote) HUD(s *gamestate.State) []string {
+	c := m.c
+	if !c.battle() {
+		return []string{c.nameOf(c.target), ""}
+	}
+	return []string{
+		c.nameOf(c.target),
+		fmt.Sprintf("TARGETING %s ATTACKERS %d", strings.ToUpper(string(c.targeting)), c.attackers),
+		c.lastKO,
+	}
+}
+
+func (m *remote) Results(s *gamestate.State) []string {
+	c := m.c
+	if c.status != nil || !c.finished || c.battle() {
+		return nil
+	}
+	if c.won {
+		return []string{c.nameOf(c.target), "LOSES"}
+	}
+	return []string{c.nameOf(c.target), "WINS"}
+}
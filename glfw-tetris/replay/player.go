@@ -0,0 +1,160 @@
+package replay
+
+import (
+	"errors"
+	"fmt"
+	"strconv"
+
+	"github.com/omustardo/tetris/glfw-tetris/gamestate"
+)
+
+// Speeds lists the speeds that a replay can be played back at, as multiples
+// of the speed it was played at.
+var Speeds = []float64{0.25, 0.5, 1, 2, 4, 8}
+
+// normalSpeed is the index in Speeds of playing back at the recorded speed.
+const normalSpeed = 2
+
+const (
+	// Number of frames between the copies of the game that seeking starts
+	// from.
+	snapshotInterval = 5 * gamestate.TicksPerSecond
+	// Number of frames skipped by seeking forwards or back.
+	seekFrames = 5 * gamestate.TicksPerSecond
+)
+
+// Player plays a replay back like a video, with controls to pause, change the
+// speed, step through it a frame at a time and seek.
+type Player struct {
+	playback *gamestate.Playback
+	// snapshots[i] is a copy of the playback from before frame
+	// i*snapshotInterval was played. They're taken on the way through the
+	// replay, so seeking back only has to play forward from the one before.
+	snapshots []*gamestate.Playback
+	paused    bool
+	speed     int     // Index in Speeds.
+	progress  float64 // Frames owed to the playback, for speeds below 1.
+}
+
+// NewPlayer starts playing the replay back, at the recorded speed.
+func (r *Replay) NewPlayer() (*Player, error) {
+	playback, err := r.Play()
+	if err != nil {
+		return nil, err
+	}
+	p := &Player{playback: playback, speed: normalSpeed}
+	p.showStatus()
+	return p, nil
+}
+
+// State returns the game being played back. It changes when seeking back.
+func (p *Player) State() *gamestate.State {
+	return p.playback.State()
+}
+
+// Apply the viewer's input for this frame. Pause pauses, up and down change
+// the speed (rotating counter-clockwise and clockwise), and left and right
+// seek back and forward, or step a frame at a time while paused. Restart goes
+// back to the start.
+func (p *Player) Apply(in gamestate.Input) {
+	if in.Pause {
+		p.paused = !p.paused
+		p.progress = 0
+	}
+	if in.RotateCounterClockwise && p.speed < len(Speeds)-1 {
+		p.speed++
+	}
+	if in.RotateClockwise && p.speed > 0 {
+		p.speed--
+	}
+	step := seekFrames
+	if p.paused {
+		step = 1
+	}
+	if in.Left {
+		p.Seek(p.playback.Frame() - step)
+	}
+	if in.Right {
+		p.Seek(p.playback.Frame() + step)
+	}
+	if in.Restart {
+		p.Seek(0)
+	}
+	p.showStatus()
+}
+
+// Tick plays as many frames as one frame's worth of time takes at the
+// current speed, unless paused.
+func (p *Player) Tick() {
+	if p.paused {
+		return
+	}
+	p.progress += Speeds[p.speed]
+	for ; p.progress >= 1; p.progress-- {
+		p.step()
+	}
+	p.showStatus()
+}
+
+// Seek jumps to a frame of the replay, from the start of it.
+func (p *Player) Seek(frame int) {
+	if frame < 0 {
+		frame = 0
+	}
+	if frame > p.playback.Frames() {
+		frame = p.playback.Frames()
+	}
+	if frame < p.playback.Frame() {
+		p.playback = p.snapshots[frame/snapshotInterval].Copy()
+	}
+	for p.playback.Frame() < frame {
+		p.step()
+	}
+	p.showStatus()
+}
+
+// step plays the next frame, taking a snapshot first if it's time for one.
+func (p *Player) step() {
+	if p.playback.Done() {
+		return
+	}
+	if frame := p.playback.Frame(); frame%snapshotInterval == 0 && frame/snapshotInterval == len(p.snapshots) {
+		p.snapshots = append(p.snapshots, p.playback.Copy())
+	}
+	p.playback.Step()
+}
+
+// showStatus captions the game with where the replay is up to.
+func (p *Player) showStatus() {
+	status := "REPLAY"
+	if p.paused {
+		status = "PAUSED"
+	}
+	p.State().SetCaption(fmt.Sprintf("%s %sX %s / %s", status,
+		strconv.FormatFloat(Speeds[p.speed], 'f', -1, 64),
+		gamestate.FormatTicks(p.playback.Frame()),
+		gamestate.FormatTicks(p.playback.Frames())))
+}
+
+// Verify plays the replay through to the end, as fast as possible, and
+// returns how the game went. It's an error if that isn't what the replay
+// claims.
+func (r *Replay) Verify() (Result, error) {
+	playback, err := r.Play()
+	if err != nil {
+		return Result{}, err
+	}
+	for !playback.Done() {
+		playback.Step()
+	}
+	got := ResultOf(playback.State())
+	if got != r.Result {
+		return got, fmt.Errorf("replay claims score %d, %d lines, %d pieces in %s, but plays out to score %d, %d lines, %d pieces in %s",
+			r.Result.Score, r.Result.Lines, r.Result.Pieces, gamestate.FormatTicks(r.Result.Ticks),
+			got.Score, got.Lines, got.Pieces, gamestate.FormatTicks(got.Ticks))
+	}
+	if !playback.State().GameOver() {
+		return got, errors.New("replay ends before the game is over")
+	}
+	return got, nil
+}
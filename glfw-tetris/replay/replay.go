@@ -0,0 +1,136 @@
+// Package replay saves recorded games to files so that they can be played
+// back. A replay holds a game's seed and rules along with every input made
+// during it, which is all it takes to play the game out again exactly.
+package replay
+
+import (
+	"log"
+
+	"github.com/omustardo/tetris/glfw-tetris/gamestate"
+	"github.com/omustardo/tetris/glfw-tetris/modes"
+)
+
+// Replay is a recorded game.
+type Replay struct {
+	// Name of the player, like "PLAYER 1".
+	Name string
+	// Mode is the name of the game's mode, as given to modes.New. It's empty
+	// for endless games, and for versus and online games, which are played
+	// back as endless games since garbage from opponents is recorded.
+	Mode string
+	// Messiness of the garbage, for dig modes.
+	Messiness float64
+	// Puzzle is the puzzle that was played, for the puzzle mode, or the setup
+	// that zen mode started from.
+	Puzzle *modes.Puzzle
+	Config gamestate.Config
+	Fade   *gamestate.Fade
+	// Result is how the game went, according to whoever recorded it.
+	Result    Result
+	Recording gamestate.Recording
+}
+
+// Result is the outcome of a game.
+type Result struct {
+	Score  int
+	Lines  int
+	Pieces int
+	Ticks  int
+}
+
+// ResultOf returns the outcome of a game so far.
+func ResultOf(s *gamestate.State) Result {
+	stats := s.Stats()
+	return Result{Score: s.Score(), Lines: stats.Lines, Pieces: stats.Pieces, Ticks: stats.Ticks}
+}
+
+// New creates a replay of a game that's being recorded, under the name of
+// its mode and the options it was created with. It returns nil if the game
+// isn't being recorded.
+func New(name, mode string, options modes.Options, s *gamestate.State) *Replay {
+	recording := s.Recording()
+	if recording == nil {
+		return nil
+	}
+	r := &Replay{
+		Name:      name,
+		Mode:      mode,
+		Messiness: options.Messiness,
+		Config:    s.Config(),
+		Fade:      s.Fade(),
+		Result:    ResultOf(s),
+		Recording: *recording,
+	}
+	switch m := s.Mode().(type) {
+	case *modes.Puzzles:
+		r.Puzzle = m.Puzzle()
+	case *modes.Zen:
+		r.Puzzle = m.Setup()
+	}
+	return r
+}
+
+// Play creates a new game like the recorded one and starts playing the
+// recording back into it.
+func (r *Replay) Play() (*gamestate.Playback, error) {
+	if err := r.Config.Validate(); err != nil {
+		return nil, err
+	}
+	options := modes.Options{Messiness: r.Messiness}
+	if r.Puzzle != nil {
+		options.Puzzles = []*modes.Puzzle{r.Puzzle}
+		options.Setup = r.Puzzle
+	}
+	mode, err := modes.New(r.Mode, options)
+	if err != nil {
+		return nil, err
+	}
+	s := gamestate.NewState(r.Config)
+	s.SetMode(mode)
+	s.SetFade(r.Fade)
+	return gamestate.NewPlayback(s, r.Recording), nil
+}
+
+// Recorder saves a replay of each recorded game into a directory once it's
+// over.
+type Recorder struct {
+	dir     string
+	mode    string
+	options modes.Options
+	saved   map[*gamestate.State]bool // Games that are over and already saved.
+}
+
+// NewRecorder creates a recorder for games of the named mode, created with
+// the given options, which saves replays into dir.
+func NewRecorder(dir, mode string, options modes.Options) *Recorder {
+	return &Recorder{
+		dir:     dir,
+		mode:    mode,
+		options: options,
+		saved:   make(map[*gamestate.State]bool),
+	}
+}
+
+// Update saves a replay of a game under the player's name if the game has
+// just ended and is being recorded. It's expected to be called once per
+// frame, after the game's tick.
+func (r *Recorder) Update(name string, s *gamestate.State) {
+	if !s.GameOver() {
+		delete(r.saved, s)
+		return
+	}
+	if r.saved[s] {
+		return
+	}
+	r.saved[s] = true
+	replay := New(name, r.mode, r.options, s)
+	if replay == nil {
+		return
+	}
+	path, err := replay.Save(r.dir)
+	if err != nil {
+		log.Println("Error saving replay:", err)
+		return
+	}
+	log.Println("Saved replay to", path)
+}
@@ -0,0 +1,31 @@
+package spectator
+
+import (
+	"github.com/omustardo/tetris/tetris-server/protocol"
+	"golang.org/x/net/websocket"
+)
+
+// dial connects to a feed over WebSocket, at an address like
+// "ws://localhost:7780/watch".
+func dial(addr string) (conn, error) {
+	ws, err := websocket.Dial(addr, "", "http://localhost/")
+	if err != nil {
+		return nil, err
+	}
+	return wsConn{ws}, nil
+}
+
+// wsConn receives each update in its own text frame.
+type wsConn struct {
+	ws *websocket.Conn
+}
+
+func (c wsConn) Receive() (protocol.Update, error) {
+	var u protocol.Update
+	err := websocket.JSON.Receive(c.ws, &u)
+	return u, err
+}
+
+func (c wsConn) Close() error {
+	return c.ws.Close()
+}
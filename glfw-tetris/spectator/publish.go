@@ -0,0 +1,35 @@
+package spectator
+
+import (
+	"log"
+	"net"
+
+	"github.com/omustardo/tetris/glfw-tetris/gamestate"
+	"github.com/omustardo/tetris/tetris-server/spectate"
+)
+
+// Publisher shows games to spectators connecting over WebSocket.
+type Publisher struct {
+	hub *spectate.Hub
+}
+
+// Publish starts serving a feed of games to spectators on addr, at
+// spectate.Path.
+func Publish(addr string) (*Publisher, error) {
+	listener, err := net.Listen("tcp", addr)
+	if err != nil {
+		return nil, err
+	}
+	p := &Publisher{hub: spectate.NewHub()}
+	log.Printf("Serving to spectators on %s%s", listener.Addr(), spectate.Path)
+	go func() {
+		log.Println("Stopped serving to spectators:", p.hub.Serve(listener))
+	}()
+	return p, nil
+}
+
+// Show publishes the latest of a game, telling it apart from the others by
+// number. It's expected to be called once per frame.
+func (p *Publisher) Show(game int, name string, s *gamestate.State) {
+	p.hub.Publish(game, Capture(name, s))
+}
@@ -0,0 +1,310 @@
+package tbp
+
+import (
+	"errors"
+	"fmt"
+	"log"
+	"os"
+	"os/exec"
+	"reflect"
+	"time"
+
+	"github.com/omustardo/tetris/glfw-tetris/gamestate"
+	"github.com/omustardo/tetris/glfw-tetris/tetronimoes"
+)
+
+// quitTimeout is how long a bot has to exit once it's asked to quit, before
+// it's killed.
+const quitTimeout = 2 * time.Second
+
+// Bot is a bot running in another process. It's a gamestate.Engine, so that
+// it can pick where a gamestate.Bot puts each piece, which then presses the
+// keys to get it there.
+//
+// Moves that the game can't make, like holds or kicks that its rotation system
+// doesn't have, are passed over for the bot's next best suggestion. If it has
+// none that the game can make then the piece is dropped where it is. Either
+// way the bot is told what was really played.
+type Bot struct {
+	// Info is what the bot said about itself when it started.
+	Info Message
+	// Wait makes Pick wait for the bot to suggest a move, rather than letting
+	// the game go on in the meantime. Benchmarks use it, so that how long the
+	// bot takes to think doesn't change how it plays.
+	Wait bool
+
+	cmd      *exec.Cmd
+	stream   *Stream
+	received chan Message
+	lost     chan error // Gets an error once the bot exits.
+
+	started bool               // Whether the bot has been sent a game since it was last stopped.
+	field   Field              // The board as the bot has it.
+	queue   []tetronimoes.Kind // The pieces that the bot knows of, falling one first.
+	pending bool               // Whether a suggestion has been asked for and not received.
+}
+
+// Launch starts a bot by running a command, and waits until it's ready to
+// play.
+func Launch(command ...string) (*Bot, error) {
+	if len(command) == 0 {
+		return nil, errors.New("no command to start the bot with")
+	}
+	cmd := exec.Command(command[0], command[1:]...)
+	// Bots log to stderr, since stdout is for messages.
+	cmd.Stderr = os.Stderr
+	stdin, err := cmd.StdinPipe()
+	if err != nil {
+		return nil, err
+	}
+	stdout, err := cmd.StdoutPipe()
+	if err != nil {
+		return nil, err
+	}
+	if err := cmd.Start(); err != nil {
+		return nil, err
+	}
+	b := &Bot{
+		cmd:      cmd,
+		stream:   NewStream(stdout, stdin),
+		received: make(chan Message, 16),
+		lost:     make(chan error, 1),
+	}
+	if err := b.handshake(); err != nil {
+		b.Close()
+		return nil, fmt.Errorf("bot %s didn't start: %v", command[0], err)
+	}
+	go b.receive()
+	return b, nil
+}
+
+// handshake waits for the bot's Info, and then tells it the rules.
+func (b *Bot) handshake() error {
+	info, err := b.stream.Receive()
+	if err != nil {
+		return err
+	}
+	if info.Type != Info {
+		return fmt.Errorf("expected %q, got %q", Info, info.Type)
+	}
+	b.Info = info
+	if err := b.stream.Send(Message{Type: Rules}); err != nil {
+		return err
+	}
+	reply, err := b.stream.Receive()
+	switch {
+	case err != nil:
+		return err
+	case reply.Type == Error:
+		return fmt.Errorf("it can't play by the rules: %s", reply.Reason)
+	case reply.Type != Ready:
+		return fmt.Errorf("expected %q, got %q", Ready, reply.Type)
+	}
+	return nil
+}
+
+// receive passes on messages from the bot until it exits.
+func (b *Bot) receive() {
+	for {
+		m, err := b.stream.Receive()
+		if err != nil {
+			b.lost <- err
+			return
+		}
+		b.received <- m
+	}
+}
+
+// Close asks the bot to quit, and waits for it to exit.
+func (b *Bot) Close() error {
+	b.stream.Send(Message{Type: Quit})
+	b.stream.Close()
+	done := make(chan error, 1)
+	go func() { done <- b.cmd.Wait() }()
+	select {
+	case err := <-done:
+		return err
+	case <-time.After(quitTimeout):
+		b.cmd.Process.Kill()
+		return <-done
+	}
+}
+
+// Pick asks the bot where to put the falling piece, and returns the keys to
+// press to put it there once the bot replies.
+func (b *Bot) Pick(s *gamestate.State, fresh bool) ([]gamestate.Input, bool, error) {
+	if fresh {
+		if b.pending {
+			// The piece changed before the bot replied, as when a move is
+			// undone, so the reply is no use.
+			if _, err := b.suggestion(true); err != nil {
+				return nil, false, err
+			}
+		}
+		if err := b.sync(s); err != nil {
+			return nil, false, err
+		}
+		if err := b.stream.Send(Message{Type: Suggest}); err != nil {
+			return nil, false, err
+		}
+		b.pending = true
+	}
+	suggested, err := b.suggestion(b.Wait)
+	if err != nil || suggested == nil {
+		return nil, false, err
+	}
+
+	kind, _ := s.PieceCells()
+	moves := s.Moves()
+	if len(moves) == 0 {
+		return nil, false, errors.New("the falling piece can't move")
+	}
+	move, ok := choose(kind, moves, suggested)
+	if !ok {
+		log.Println("None of the bot's moves can be made, so the piece is dropped where it is")
+		move = moves[0]
+	}
+	played := Move{Spin: NoSpin}
+	played.Location, _ = Locate(kind, move.Cells)
+	if move.TSpin {
+		played.Spin = FullSpin
+	}
+	if err := b.stream.Send(Message{Type: Play, Move: played}); err != nil {
+		return nil, false, err
+	}
+	b.field = b.field.Place(played.Location.Type, move.Cells)
+	b.queue = b.queue[1:]
+	return move.Inputs, true, nil
+}
+
+// suggestion returns the moves that the bot suggested, or nil if it hasn't
+// replied yet. If wait is set then it waits for the reply instead.
+func (b *Bot) suggestion(wait bool) ([]Move, error) {
+	var m Message
+	if wait {
+		select {
+		case m = <-b.received:
+		case err := <-b.lost:
+			return nil, err
+		}
+	} else {
+		select {
+		case m = <-b.received:
+		case err := <-b.lost:
+			return nil, err
+		default:
+			return nil, nil
+		}
+	}
+	if m.Type != Suggestion {
+		return nil, fmt.Errorf("expected %q, got %q", Suggestion, m.Type)
+	}
+	b.pending = false
+	if m.Moves == nil {
+		m.Moves = []Move{}
+	}
+	return m.Moves, nil
+}
+
+// sync brings the bot up to date with the game. If the game has only moved
+// on by the moves that the bot was told about, it's told about the new pieces
+// in the queue. Otherwise, as when garbage comes in, it's told to start over.
+func (b *Bot) sync(s *gamestate.State) error {
+	field, err := FieldOf(s.Board())
+	if err != nil {
+		return err
+	}
+	kind, _ := s.PieceCells()
+	queue := append([]tetronimoes.Kind{kind}, s.Queue()...)
+	if b.started && reflect.DeepEqual(field, b.field) && startsWith(queue, b.queue) {
+		for _, kind := range queue[len(b.queue):] {
+			if err := b.stream.Send(Message{Type: NewPiece, Piece: PieceName(kind)}); err != nil {
+				return err
+			}
+		}
+		b.queue = queue
+		return nil
+	}
+	if b.started {
+		if err := b.stream.Send(Message{Type: Stop}); err != nil {
+			return err
+		}
+	}
+	start := Message{
+		Type: Start,
+		// The combo counts the clears in a row, including the first.
+		Combo:      s.Combo() + 1,
+		BackToBack: s.BackToBack(),
+		Board:      field,
+	}
+	for _, kind := range queue {
+		start.Queue = append(start.Queue, PieceName(kind))
+	}
+	if err := b.stream.Send(start); err != nil {
+		return err
+	}
+	b.started, b.field, b.queue = true, field, queue
+	return nil
+}
+
+// choose returns the first of the suggested moves that the falling piece can
+// make.
+func choose(kind tetronimoes.Kind, moves []gamestate.Move, suggested []Move) (gamestate.Move, bool) {
+	for _, m := range suggested {
+		if m.Location.Type != PieceName(kind) {
+			// The bot wants to hold, which there's no key for.
+			continue
+		}
+		cells, err := m.Location.Cells()
+		if err != nil {
+			continue
+		}
+		var found []gamestate.Move
+		for _, move := range moves {
+			if sameCells(move.Cells, cells) {
+				found = append(found, move)
+			}
+		}
+		// Spin it in if the bot wants to, and not if it doesn't, where it can
+		// be done either way.
+		for _, move := range found {
+			if move.TSpin == (m.Spin != NoSpin) {
+				return move, true
+			}
+		}
+		if len(found) > 0 {
+			return found[0], true
+		}
+	}
+	return gamestate.Move{}, false
+}
+
+// sameCells returns whether a and b hold the same cells, in any order.
+func sameCells(a, b [][2]int) bool {
+	if len(a) != len(b) {
+		return false
+	}
+	in := make(map[[2]int]bool)
+	for _, c := range a {
+		in[c] = true
+	}
+	for _, c := range b {
+		if !in[c] {
+			return false
+		}
+	}
+	return true
+}
+
+// startsWith returns whether a starts with all of b.
+func startsWith(a, b []tetronimoes.Kind) bool {
+	if len(b) > len(a) {
+		return false
+	}
+	for i := range b {
+		if a[i] != b[i] {
+			return false
+		}
+	}
+	return true
+}
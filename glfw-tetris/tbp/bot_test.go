@@ -0,0 +1,122 @@
+package tbp
+
+import (
+	"fmt"
+	"os"
+	"reflect"
+	"testing"
+
+	"github.com/omustardo/tetris/glfw-tetris/gamestate"
+	"github.com/omustardo/tetris/glfw-tetris/tetronimoes"
+)
+
+// stubBotEnv makes the test binary run as stubBot, so that tests have a bot
+// process to launch.
+const stubBotEnv = "TBP_STUB_BOT"
+
+func TestMain(m *testing.M) {
+	if os.Getenv(stubBotEnv) != "" {
+		if err := stubBot(NewStream(os.Stdin, os.Stdout)); err != nil {
+			fmt.Fprintln(os.Stderr, "stub bot:", err)
+			os.Exit(1)
+		}
+		os.Exit(0)
+	}
+	os.Exit(m.Run())
+}
+
+// stubBot plays one piece of one game. It suggests holding, which the game
+// can't do, and then putting the falling piece turned North at the bottom
+// left. It returns an error if the game doesn't go as expected.
+func stubBot(stream *Stream) error {
+	expect := func(t Type) (Message, error) {
+		m, err := stream.Receive()
+		if err == nil && m.Type != t {
+			err = fmt.Errorf("expected %q, got %q", t, m.Type)
+		}
+		return m, err
+	}
+	if err := stream.Send(Message{Type: Info, Name: "stub", Version: "1", Author: "test"}); err != nil {
+		return err
+	}
+	if _, err := expect(Rules); err != nil {
+		return err
+	}
+	if err := stream.Send(Message{Type: Ready}); err != nil {
+		return err
+	}
+	start, err := expect(Start)
+	if err != nil {
+		return err
+	}
+	if len(start.Board) != FieldHeight || len(start.Queue) == 0 {
+		return fmt.Errorf("started with a board of %d rows and a queue of %v", len(start.Board), start.Queue)
+	}
+	if _, err := expect(Suggest); err != nil {
+		return err
+	}
+	hold := Move{Location: Location{Type: "I", Orientation: North, X: 1, Y: 0}, Spin: NoSpin}
+	if start.Queue[0] == "I" {
+		hold.Location.Type = "O"
+	}
+	move := Move{Location: Location{Type: start.Queue[0], Orientation: North, X: 1, Y: 0}, Spin: NoSpin}
+	if err := stream.Send(Message{Type: Suggestion, Moves: []Move{hold, move}}); err != nil {
+		return err
+	}
+	play, err := expect(Play)
+	if err != nil {
+		return err
+	}
+	if play.Move != move {
+		return fmt.Errorf("suggested %+v, but %+v was played", move, play.Move)
+	}
+	_, err = expect(Quit)
+	return err
+}
+
+func TestBot(t *testing.T) {
+	t.Setenv(stubBotEnv, "1")
+	b, err := Launch(os.Args[0])
+	if err != nil {
+		t.Fatal(err)
+	}
+	if b.Info.Name != "stub" {
+		t.Errorf("got info %+v", b.Info)
+	}
+	b.Wait = true
+
+	// The game's S is shaped like the protocol's Z, so the stub's Z is the
+	// move that it can make.
+	s := gamestate.NewState(gamestate.Config{Width: FieldWidth, Height: 20, BufferHeight: 20})
+	s.SetQueue([]tetronimoes.Kind{tetronimoes.S, tetronimoes.T}, true)
+	s.Tick()
+	inputs, ready, err := b.Pick(s, true)
+	if err != nil || !ready {
+		t.Fatalf("picked %v, ready %v, error %v", inputs, ready, err)
+	}
+	for _, in := range inputs {
+		s.Apply(in)
+		s.Tick()
+	}
+	rows := s.Board().Rows
+	if got, want := rows[len(rows)-2:], []string{"SS........", ".SS......."}; !reflect.DeepEqual(got, want) {
+		t.Errorf("bottom of the board is %q, want %q", got, want)
+	}
+	if err := b.Close(); err != nil {
+		t.Errorf("stub bot failed: %v", err)
+	}
+}
+
+func TestLaunchErrors(t *testing.T) {
+	for _, command := range [][]string{
+		nil,
+		{"/nonexistent/bot"},
+		{"/bin/sh", "-c", `echo '{"type":"info","name":"x"}'; read l; echo '{"type":"error","reason":"unsupported_rules"}'`},
+		{"/bin/sh", "-c", `echo '{"type":"ready"}'`},
+	} {
+		if b, err := Launch(command...); err == nil {
+			b.Close()
+			t.Errorf("launched %q", command)
+		}
+	}
+}
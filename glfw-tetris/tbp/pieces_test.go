@@ -0,0 +1,125 @@
+package tbp
+
+import (
+	"reflect"
+	"testing"
+
+	"github.com/omustardo/tetris/glfw-tetris/gamestate"
+	"github.com/omustardo/tetris/glfw-tetris/tetronimoes"
+)
+
+func TestPieceNames(t *testing.T) {
+	// The game's S and Z are shaped like everyone else's Z and S.
+	if PieceName(tetronimoes.S) != "Z" || PieceName(tetronimoes.Z) != "S" {
+		t.Errorf("S is named %q and Z %q", PieceName(tetronimoes.S), PieceName(tetronimoes.Z))
+	}
+	for _, kind := range []tetronimoes.Kind{tetronimoes.I, tetronimoes.O, tetronimoes.T, tetronimoes.L, tetronimoes.J, tetronimoes.S, tetronimoes.Z} {
+		if got, err := PieceKind(PieceName(kind)); err != nil || got != kind {
+			t.Errorf("%c is named %q, which is %c, %v", kind, PieceName(kind), got, err)
+		}
+	}
+	if _, err := PieceKind("Q"); err == nil {
+		t.Error("found a kind of piece named Q")
+	}
+}
+
+func TestLocate(t *testing.T) {
+	// The cells of a piece shaped like everyone's Z, which is the game's S.
+	z := [][2]int{{0, 1}, {1, 1}, {1, 0}, {2, 0}}
+	want := Location{Type: "Z", Orientation: North, X: 1, Y: 0}
+	if l, ok := Locate(tetronimoes.S, z); !ok || l != want {
+		t.Errorf("located S at %+v, %v, want %+v", l, ok, want)
+	}
+	if l, ok := Locate(tetronimoes.Z, z); ok {
+		t.Errorf("located Z at %+v, but it's the wrong shape", l)
+	}
+
+	for name := range northCells {
+		kind, _ := PieceKind(name)
+		for _, o := range Orientations {
+			l := Location{Type: name, Orientation: o, X: 4, Y: 5}
+			cells, err := l.Cells()
+			if err != nil {
+				t.Fatal(err)
+			}
+			found, ok := Locate(kind, cells)
+			if !ok {
+				t.Errorf("couldn't locate %+v", l)
+				continue
+			}
+			// Some pieces cover the same cells from more than one location.
+			if again, _ := found.Cells(); !sameCells(again, cells) {
+				t.Errorf("%+v located at %+v, which covers %v", l, found, again)
+			}
+		}
+	}
+}
+
+func TestFieldOf(t *testing.T) {
+	b, err := gamestate.ParseBoard(`
+		..t.......
+		.ttt......
+		S.........
+		SS.....OOX
+		GSGGGGGGG.
+	`)
+	if err != nil {
+		t.Fatal(err)
+	}
+	f, err := FieldOf(b)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(f) != FieldHeight || len(f[0]) != FieldWidth {
+		t.Fatalf("field is %dx%d", len(f[0]), len(f))
+	}
+	// The falling T is left out, and the game's S is the protocol's Z.
+	bottom := [][]Cell{
+		{"G", "Z", "G", "G", "G", "G", "G", "G", "G", ""},
+		{"Z", "Z", "", "", "", "", "", "O", "O", "G"},
+		{"Z", "", "", "", "", "", "", "", "", ""},
+		make([]Cell, FieldWidth),
+	}
+	for row, want := range bottom {
+		if !reflect.DeepEqual(f[row], want) {
+			t.Errorf("row %d is %q, want %q", row, f[row], want)
+		}
+	}
+	// Other blocks are all garbage to the protocol, so they come back as such.
+	rows := f.Rows()
+	if got, want := rows[len(rows)-3:], []string{"S.........", "SS.....OOG", "GSGGGGGGG."}; !reflect.DeepEqual(got, want) {
+		t.Errorf("rows are %q, want %q", got, want)
+	}
+
+	if _, err := FieldOf(&gamestate.Board{Rows: []string{"........"}}); err == nil {
+		t.Error("sent a board 8 wide")
+	}
+}
+
+func TestPlace(t *testing.T) {
+	b, err := gamestate.ParseBoard(`
+		S.........
+		SS........
+		GSGGGGGGG.
+	`)
+	if err != nil {
+		t.Fatal(err)
+	}
+	f, err := FieldOf(b)
+	if err != nil {
+		t.Fatal(err)
+	}
+	before := f.Rows()
+	// An I down the right side clears the bottom row.
+	placed := f.Place("I", [][2]int{{9, 0}, {9, 1}, {9, 2}, {9, 3}})
+	if len(placed) != FieldHeight {
+		t.Fatalf("placing left %d rows", len(placed))
+	}
+	rows := placed.Rows()
+	if got, want := rows[len(rows)-4:], []string{"..........", ".........I", "S........I", "SS.......I"}; !reflect.DeepEqual(got, want) {
+		t.Errorf("rows are %q, want %q", got, want)
+	}
+	if !reflect.DeepEqual(f.Rows(), before) {
+		t.Error("placing changed the field that it was placed on")
+	}
+}
@@ -0,0 +1,55 @@
+package tbp
+
+import (
+	"bufio"
+	"io"
+)
+
+// maxLine is the longest message accepted over a Stream. A board is the
+// biggest part of a message, so this leaves plenty of room.
+const maxLine = 1 << 16
+
+// Stream sends and receives messages one per line, like over the standard
+// input and output of a bot.
+type Stream struct {
+	w       io.WriteCloser
+	scanner *bufio.Scanner
+}
+
+// NewStream creates a stream that receives messages from r and sends them to
+// w.
+func NewStream(r io.Reader, w io.WriteCloser) *Stream {
+	scanner := bufio.NewScanner(r)
+	scanner.Buffer(make([]byte, 4096), maxLine)
+	return &Stream{w: w, scanner: scanner}
+}
+
+// Send writes a message. It isn't safe to call from more than one goroutine
+// at once.
+func (s *Stream) Send(m Message) error {
+	data, err := Encode(m)
+	if err != nil {
+		return err
+	}
+	_, err = s.w.Write(append(data, '\n'))
+	return err
+}
+
+// Receive blocks until a message arrives. It returns io.EOF once the other
+// end has closed the stream. Blank lines are skipped.
+func (s *Stream) Receive() (Message, error) {
+	for s.scanner.Scan() {
+		if len(s.scanner.Bytes()) > 0 {
+			return Decode(s.scanner.Bytes())
+		}
+	}
+	if err := s.scanner.Err(); err != nil {
+		return Message{}, err
+	}
+	return Message{}, io.EOF
+}
+
+// Close closes the sending side of the stream, so the other end sees it end.
+func (s *Stream) Close() error {
+	return s.w.Close()
+}
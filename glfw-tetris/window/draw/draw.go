@@ -94,3 +94,18 @@ func BeginDraw() {
 func SetBackground(r, g, b, a float32) {
 	gl.ClearColor(r, g, b, a)
 }
+
+// Screen draws on the window with the functions above, for code that's given
+// something to draw on rather than calling them itself, like
+// gamestate.State.Draw.
+var Screen screen
+
+type screen struct{}
+
+func (screen) RectFilled(x1, y1, x2, y2, r, g, b, a float32) {
+	RectFilled(x1, y1, x2, y2, r, g, b, a)
+}
+
+func (screen) RectColored(x1, y1, x2, y2, r, g, b, a float32) {
+	RectColored(x1, y1, x2, y2, r, g, b, a)
+}
@@ -0,0 +1,81 @@
+// Wrapper class to handle a gamepad through glfw's joystick API.
+// Keeps track of the buttons between frames, like the keyboard handler does
+// for keys.
+
+package gamepad
+
+import "github.com/go-gl/glfw/v3.1/glfw"
+
+// Button is a control on the gamepad. The left stick counts as four buttons,
+// one for each direction.
+type Button int
+
+const (
+	Left Button = iota
+	Right
+	Up
+	Down
+	A
+	B
+	Start
+)
+
+// buttonIndices maps buttons to their index in glfw.GetJoystickButtons, as laid
+// out on an Xbox controller.
+var buttonIndices = map[Button]int{
+	A:     0,
+	B:     1,
+	Start: 7,
+}
+
+// How far the stick has to be pushed in a direction to count as pressed.
+const stickThreshold = 0.5
+
+type Handler struct {
+	joystick glfw.Joystick
+	// State maps from buttons to whether they are pressed.
+	State         map[Button]bool
+	PreviousState map[Button]bool
+}
+
+// NewHandler returns a handler for the given joystick. It doesn't have to be
+// connected yet.
+func NewHandler(joystick glfw.Joystick) *Handler {
+	return &Handler{
+		joystick:      joystick,
+		State:         make(map[Button]bool),
+		PreviousState: make(map[Button]bool),
+	}
+}
+
+// Update is expected to be called once per frame, along with the keyboard
+// handler's Update. Nothing is pressed while the gamepad isn't connected.
+func (h *Handler) Update() {
+	h.PreviousState, h.State = h.State, make(map[Button]bool)
+	if !glfw.JoystickPresent(h.joystick) {
+		return
+	}
+	if axes := glfw.GetJoystickAxes(h.joystick); len(axes) >= 2 {
+		h.State[Left] = axes[0] < -stickThreshold
+		h.State[Right] = axes[0] > stickThreshold
+		h.State[Up] = axes[1] < -stickThreshold
+		h.State[Down] = axes[1] > stickThreshold
+	}
+	buttons := glfw.GetJoystickButtons(h.joystick)
+	for button, i := range buttonIndices {
+		if i < len(buttons) {
+			h.State[button] = glfw.Action(buttons[i]) == glfw.Press
+		}
+	}
+}
+
+// IsButtonDown returns whether the provided button is currently pressed.
+func (h *Handler) IsButtonDown(button Button) bool {
+	return h.State[button]
+}
+
+// WasButtonDown returns whether the provided button was pressed in the
+// previous frame.
+func (h *Handler) WasButtonDown(button Button) bool {
+	return h.PreviousState[button]
+}
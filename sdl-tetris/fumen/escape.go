@@ -0,0 +1,57 @@
+package fumen
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+	"unicode/utf16"
+)
+
+// Comments are escaped as by JavaScript's escape function, which fumen
+// editors use: each UTF-16 code unit outside of the letters, digits and
+// "@*_+-./" is written as %XX if it fits in a byte, and %uXXXX if not.
+
+func escape(s string) string {
+	var b strings.Builder
+	for _, unit := range utf16.Encode([]rune(s)) {
+		switch {
+		case unit < 0x80 && (isAlphanumeric(byte(unit)) || strings.IndexByte("@*_+-./", byte(unit)) >= 0):
+			b.WriteByte(byte(unit))
+		case unit < 0x100:
+			fmt.Fprintf(&b, "%%%02X", unit)
+		default:
+			fmt.Fprintf(&b, "%%u%04X", unit)
+		}
+	}
+	return b.String()
+}
+
+func isAlphanumeric(c byte) bool {
+	return 'a' <= c && c <= 'z' || 'A' <= c && c <= 'Z' || '0' <= c && c <= '9'
+}
+
+// unescape undoes escape. Anything that isn't a valid escape is left alone.
+func unescape(s string) string {
+	var units []uint16
+	for i := 0; i < len(s); {
+		if s[i] == '%' {
+			if i+6 <= len(s) && s[i+1] == 'u' {
+				if n, err := strconv.ParseUint(s[i+2:i+6], 16, 16); err == nil {
+					units = append(units, uint16(n))
+					i += 6
+					continue
+				}
+			}
+			if i+3 <= len(s) {
+				if n, err := strconv.ParseUint(s[i+1:i+3], 16, 8); err == nil {
+					units = append(units, uint16(n))
+					i += 3
+					continue
+				}
+			}
+		}
+		units = append(units, uint16(s[i]))
+		i++
+	}
+	return string(utf16.Decode(units))
+}
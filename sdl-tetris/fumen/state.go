@@ -0,0 +1,50 @@
+package fumen
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+
+	"github.com/omustardo/tetris/sdl-tetris/gamestate"
+	"github.com/omustardo/tetris/sdl-tetris/tetronimoes"
+)
+
+// NewPage returns a page showing a board, as rows like those of
+// gamestate.State.Rows, with a piece of the given kind placed on the given
+// cells, or no piece if there are no cells. The page locks the piece. Empty
+// rows at the top of the board are left out, so that boards taller than
+// fumen's fit as long as their blocks do.
+func NewPage(rows []string, kind tetronimoes.Kind, cells [][2]int) (*Page, error) {
+	empty := strings.Repeat(string(emptyCell), Width)
+	for len(rows) > 0 && rows[0] == empty {
+		rows = rows[1:]
+	}
+	for _, row := range rows {
+		if len(row) != Width {
+			return nil, fmt.Errorf("fumen boards are %d wide, not %d", Width, len(row))
+		}
+	}
+	if len(rows) > Height {
+		return nil, fmt.Errorf("board is stacked %d rows high, but fumen only has room for %d", len(rows), Height)
+	}
+	p := &Page{Board: rows, Lock: true}
+	if len(cells) > 0 {
+		placement, ok := PlacementOf(kind, cells)
+		if !ok {
+			return nil, fmt.Errorf("%c piece at %v isn't one that fumen can show", kind, cells)
+		}
+		p.Piece = &placement
+	}
+	return p, nil
+}
+
+// FromState returns a page showing a game's board, with its falling piece
+// where it is. Big games can't be shown, since their pieces are too big for
+// fumen.
+func FromState(s *gamestate.State) (*Page, error) {
+	if s.Config().Big {
+		return nil, errors.New("big games can't be written as fumen")
+	}
+	kind, cells := s.PieceCells()
+	return NewPage(s.Rows(), kind, cells)
+}
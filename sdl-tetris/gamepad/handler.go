@@ -0,0 +1,93 @@
+package gamepad
+
+import (
+	"log"
+
+	"github.com/veandco/go-sdl2/sdl"
+)
+
+// Button is a control on the gamepad.
+type Button int
+
+const (
+	Left Button = iota
+	Right
+	Up
+	Down
+	A
+	B
+	Start
+)
+
+// controllerButtons maps buttons to SDL's game controller buttons.
+var controllerButtons = map[Button]sdl.GameControllerButton{
+	Left:  sdl.CONTROLLER_BUTTON_DPAD_LEFT,
+	Right: sdl.CONTROLLER_BUTTON_DPAD_RIGHT,
+	Up:    sdl.CONTROLLER_BUTTON_DPAD_UP,
+	Down:  sdl.CONTROLLER_BUTTON_DPAD_DOWN,
+	A:     sdl.CONTROLLER_BUTTON_A,
+	B:     sdl.CONTROLLER_BUTTON_B,
+	Start: sdl.CONTROLLER_BUTTON_START,
+}
+
+type Handler struct {
+	controller *sdl.GameController // nil until a game controller is found.
+	// State maps from buttons to whether they are pressed.
+	State         map[Button]bool
+	PreviousState map[Button]bool
+}
+
+func NewHandler() *Handler {
+	return &Handler{
+		State:         make(map[Button]bool),
+		PreviousState: make(map[Button]bool),
+	}
+}
+
+// open starts using the first connected game controller, if there is one.
+func (h *Handler) open() {
+	for i := 0; i < sdl.NumJoysticks(); i++ {
+		if sdl.IsGameController(i) {
+			h.controller = sdl.GameControllerOpen(i)
+			if h.controller != nil {
+				log.Println("Using game controller", i)
+				return
+			}
+		}
+	}
+}
+
+// Update is expected to be called roughly once per frame, after events have
+// been processed by calling sdl.PollEvent, like the keyboard handler. Nothing
+// is pressed until a game controller is connected.
+func (h *Handler) Update() {
+	h.PreviousState, h.State = h.State, make(map[Button]bool)
+	if h.controller == nil {
+		h.open()
+		if h.controller == nil {
+			return
+		}
+	}
+	for button, b := range controllerButtons {
+		h.State[button] = h.controller.GetButton(b) == 1
+	}
+}
+
+// Close stops using the game controller.
+func (h *Handler) Close() {
+	if h.controller != nil {
+		h.controller.Close()
+		h.controller = nil
+	}
+}
+
+// IsButtonDown returns whether the provided button is currently pressed.
+func (h *Handler) IsButtonDown(button Button) bool {
+	return h.State[button]
+}
+
+// WasButtonDown returns whether the provided button was pressed in the
+// previous frame.
+func (h *Handler) WasButtonDown(button Button) bool {
+	return h.PreviousState[button]
+}
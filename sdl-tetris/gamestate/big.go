@@ -0,0 +1,46 @@
+package gamestate
+
+import "github.com/omustardo/tetris/sdl-tetris/tetronimoes"
+
+// In big games every point of a piece covers a square of bigScale x bigScale
+// cells of the board, so a 10x20 board plays like a 5x10 one.
+const bigScale = 2
+
+// scale returns the number of board cells along each side of a piece's
+// points.
+func (s *State) scale() int {
+	if s.config.Big {
+		return bigScale
+	}
+	return 1
+}
+
+// cell is a position on the board.
+type cell struct {
+	col, row int
+}
+
+// cells returns the positions on the board covered by a shape at its current
+// origin, scaled up in big games. The origin is in board cells.
+func (s *State) cells(shape *tetronimoes.Shape) []cell {
+	scale := s.scale()
+	origin := shape.Origin()
+	points := shape.Points()
+	var cells []cell
+	for row := range points {
+		for col, p := range points[row] {
+			if !p {
+				continue
+			}
+			for dy := 0; dy < scale; dy++ {
+				for dx := 0; dx < scale; dx++ {
+					cells = append(cells, cell{
+						col: int(origin.X) + col*scale + dx,
+						row: int(origin.Y) + row*scale + dy,
+					})
+				}
+			}
+		}
+	}
+	return cells
+}
@@ -0,0 +1,127 @@
+package gamestate
+
+import (
+	"strconv"
+
+	"github.com/veandco/go-sdl2/sdl"
+)
+
+// Each line of the HUD above the board takes up this many rows of blocks.
+const hudLineHeight = 1.5
+
+// Draw the game state within the area with its upper left corner at (x,y).
+// Blocks are kept square, so the board is centered along whichever axis has
+// space left over. The hidden rows above the board aren't drawn.
+func (s *State) Draw(renderer *sdl.Renderer, x, y, width, height int) {
+	hud := s.hud()
+	x, y, blockSize := s.boardArea(x, y, width, height, len(hud))
+	width = blockSize * s.config.Width
+	height = blockSize * s.config.Height
+
+	// Draw the HUD above the board, first line at the top.
+	for i, line := range hud {
+		lineY := y - int(float32(blockSize)*hudLineHeight*(float32(len(hud)-i)-0.5))
+		size := int(textSize(line, float32(width), float32(blockSize)/5))
+		drawTextCentered(renderer, x+width/2, lineY, size, line, 255, 255, 255, 255)
+	}
+
+	// Draw bounding box
+	renderer.SetDrawColor(200, 200, 200, 255)
+	renderer.DrawRect(&sdl.Rect{X: int32(x), Y: int32(y), W: int32(width), H: int32(height)})
+
+	if s.paused {
+		// The board is hidden so pausing can't be used to plan ahead.
+		size := int(textSize("PAUSED", float32(width)*0.8, float32(blockSize)/2))
+		drawTextCentered(renderer, x+width/2, y+height/2, size, "PAUSED", 255, 255, 255, 255)
+		return
+	}
+
+	// The board has row 0 at the bottom, but SDL has y=0 at the top.
+	drawBlock := func(col, row int, r, g, b, a uint8) {
+		if row >= s.config.Height {
+			return
+		}
+		rect := &sdl.Rect{
+			X: int32(x + col*blockSize),
+			Y: int32(y + height - (row+1)*blockSize),
+			W: int32(blockSize),
+			H: int32(blockSize),
+		}
+		renderer.SetDrawColor(r, g, b, a)
+		renderer.FillRect(rect)
+	}
+
+	// Draw all of the stable blocks. Fading ones are blended with the
+	// background.
+	renderer.SetDrawBlendMode(sdl.BLENDMODE_BLEND)
+	for row := 0; row < s.config.Height; row++ {
+		for col := 0; col < s.config.Width; col++ {
+			cell := s.board[row][col]
+			if cell == nil {
+				continue
+			}
+			if v := s.visibility(cell); v > 0 {
+				drawBlock(col, row, cell.R, cell.G, cell.B, uint8(float32(cell.A)*v))
+			}
+		}
+	}
+
+	// Draw the falling piece.
+	if s.fallingPiece != nil {
+		r, g, b, a := s.fallingPiece.Color()
+		for _, c := range s.cells(s.fallingPiece) {
+			drawBlock(c.col, c.row, r, g, b, a)
+		}
+	}
+
+	if s.countdown > 0 {
+		seconds := strconv.Itoa((s.countdown + TicksPerSecond - 1) / TicksPerSecond)
+		drawTextCentered(renderer, x+width/2, y+height/2, blockSize, seconds, 255, 255, 255, 255)
+	}
+
+	if s.gameOver {
+		// Darken the board and list the results over it, centered vertically.
+		renderer.SetDrawColor(0, 0, 0, 180)
+		renderer.FillRect(&sdl.Rect{X: int32(x), Y: int32(y), W: int32(width), H: int32(height)})
+		results := s.results()
+		for i, line := range results {
+			lineY := y + height/2 + blockSize*(2*i+1-len(results))
+			size := int(textSize(line, float32(width)*0.9, float32(blockSize)/4))
+			drawTextCentered(renderer, x+width/2, lineY, size, line, 255, 255, 255, 255)
+		}
+	}
+}
+
+// boardArea returns where Draw puts the board within the area with its upper
+// left corner at (x,y), when there are hudLines lines of HUD above it: the
+// upper left corner of the board, and the size of each block.
+func (s *State) boardArea(x, y, width, height, hudLines int) (boardX, boardY, blockSize int) {
+	rows := float32(s.config.Height) + hudLineHeight*float32(hudLines)
+	blockSize = width / s.config.Width
+	if size := int(float32(height) / rows); size < blockSize {
+		blockSize = size
+	}
+	hudHeight := int(float32(blockSize) * hudLineHeight * float32(hudLines))
+	boardX = x + (width-blockSize*s.config.Width)/2
+	boardY = y + (height-blockSize*s.config.Height-hudHeight)/2 + hudHeight
+	return boardX, boardY, blockSize
+}
+
+// drawTextCentered draws text centered on (x,y), with each pixel of the font
+// taking up a size x size square.
+func drawTextCentered(renderer *sdl.Renderer, x, y, size int, text string, r, g, b, a uint8) {
+	if size < 1 {
+		size = 1
+	}
+	left := x - textWidth(text)*size/2
+	top := y - glyphHeight*size/2
+	renderer.SetDrawColor(r, g, b, a)
+	textPixels(text, func(px, py int) {
+		renderer.FillRect(&sdl.Rect{
+			X: int32(left + px*size),
+			Y: int32(top + py*size),
+			W: int32(size),
+			H: int32(size),
+		})
+	})
+}
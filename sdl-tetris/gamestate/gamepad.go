@@ -0,0 +1,21 @@
+package gamestate
+
+import "github.com/omustardo/tetris/sdl-tetris/gamepad"
+
+// GamepadInput returns the buttons that were just pressed on a gamepad. The
+// d-pad moves the piece, up drops it and down soft drops it, A and B rotate,
+// and Start pauses.
+func GamepadInput(h *gamepad.Handler) Input {
+	pressed := func(b gamepad.Button) bool {
+		return h.IsButtonDown(b) && !h.WasButtonDown(b)
+	}
+	return Input{
+		Left:                   pressed(gamepad.Left),
+		Right:                  pressed(gamepad.Right),
+		RotateClockwise:        pressed(gamepad.A),
+		RotateCounterClockwise: pressed(gamepad.B),
+		HardDrop:               pressed(gamepad.Up),
+		SoftDrop:               pressed(gamepad.Down),
+		Pause:                  pressed(gamepad.Start),
+	}
+}
@@ -3,89 +3,559 @@
 // The game state is represented as a 2d array of blocks, where a block is
 // just a struct containing RGBA values. The game state also holds a reference
 // to the piece which is currently falling.
-// Every game tick the player's input is applied and the block moves down one.
+// The game runs on its own clock, which advances by one tick each time Tick is
+// called. Every frame the player's input is applied, and every few ticks the
+// block moves down one.
 // If it would intersect with existing blocks, it instead doesn't move down but
 // becomes part of the 2d array of blocks. If there's no falling piece then
-// a new one is randomly chosen and placed at the top.
-//
-// Note that ALL coordinates use the top left corner as (0,0) since that's
-// how the rendering calls work.
+// a new one is randomly chosen and placed in the hidden rows above the
+// visible board.
 package gamestate
 
 import (
 	"fmt"
 	"log"
-	"math/rand"
 	"time"
 
 	"github.com/omustardo/tetris/sdl-tetris/keyboard"
 	"github.com/omustardo/tetris/sdl-tetris/tetronimoes"
-	"github.com/veandco/go-sdl2/sdl"
 )
 
-func init() {
-	rand.Seed(time.Now().UTC().UnixNano())
-}
-
 const (
-	// Number of blocks in the game board.
-	Width  int = 10
-	Height int = 20
+	// Default number of blocks in the game board.
+	DefaultWidth  int = 10
+	DefaultHeight int = 20
+	// Default number of hidden rows above the visible board.
+	DefaultBufferHeight int = 20
+
+	// TicksPerSecond is how many times a second Tick is expected to be called.
+	TicksPerSecond = 60
+	// Number of ticks between unpausing and the game resuming.
+	resumeCountdown = 3 * TicksPerSecond
+	// Number of upcoming pieces that are known in advance.
+	previewLength = 5
 )
 
+// Config holds the dimensions of the board. It's fixed for the lifetime of a
+// State.
+type Config struct {
+	Width  int // Number of columns.
+	Height int // Number of visible rows.
+	// Number of hidden rows above the visible ones. New pieces spawn here, so
+	// it's best to keep at least a few of them.
+	BufferHeight int
+	// Big makes each point of a piece cover a 2x2 square of the board, with
+	// pieces moving two cells at a time and every two rows cleared counting as
+	// one line.
+	Big bool
+	// Seed picks the sequence of pieces, and anything else random in the game,
+	// so games with the same seed play out the same way. Zero means a seed is
+	// chosen from the current time.
+	Seed int64
+}
+
+func DefaultConfig() Config {
+	return Config{
+		Width:        DefaultWidth,
+		Height:       DefaultHeight,
+		BufferHeight: DefaultBufferHeight,
+	}
+}
+
+// Validate returns an error if a board with the config's dimensions couldn't
+// hold every type of piece.
+func (c Config) Validate() error {
+	if c.Width < 4 {
+		return fmt.Errorf("board width must be at least 4, got %d", c.Width)
+	}
+	if c.Height < 4 {
+		return fmt.Errorf("board height must be at least 4, got %d", c.Height)
+	}
+	if c.Big && (c.Width < 4*bigScale || c.Height < 4*bigScale) {
+		return fmt.Errorf("big games need a board at least %d blocks wide and high, got %dx%d", 4*bigScale, c.Width, c.Height)
+	}
+	// Big pieces move a whole point at a time, so they'd leave gaps along the
+	// walls, floor or top of a board that isn't a whole number of points.
+	if c.Big && (c.Width%bigScale != 0 || c.Height%bigScale != 0 || c.BufferHeight%bigScale != 0) {
+		return fmt.Errorf("big games need a board and buffer that are multiples of %d blocks, got %dx%d with a buffer of %d", bigScale, c.Width, c.Height, c.BufferHeight)
+	}
+	if c.BufferHeight < 0 {
+		return fmt.Errorf("buffer height can't be negative, got %d", c.BufferHeight)
+	}
+	return nil
+}
+
 type block struct {
 	R, G, B, A uint8
+	garbage    bool // Whether the block was pushed up from below by AddGarbage.
+	lockedAt   int  // Tick that the block became part of the board.
+	// kind is the shape the block was part of, or zero if it wasn't part of
+	// one.
+	kind tetronimoes.Kind
 }
 
 type State struct {
+	config       Config
 	fallingPiece *tetronimoes.Shape
-	board        [][]*block // board is drawn with [0,0] in the bottom left of the screen
+	// board has [0,0] in the bottom left. It has config.Height visible rows
+	// followed by config.BufferHeight hidden rows.
+	board     [][]*block
+	gameOver  bool
+	toppedOut bool // Whether the game ended because a piece didn't fit.
+	mode      Mode
+	stats     Stats
+	rng       *Rand // Source of the game's pieces. Seeded by config.Seed.
+	// queue holds the kinds of the upcoming pieces, next first. It's topped up
+	// with random pieces, unless fixedQueue is set, in which case the game
+	// ends once it runs out.
+	queue      []tetronimoes.Kind
+	fixedQueue bool
+	// lastRotated is whether the last thing the falling piece did was rotate,
+	// for spotting T-spins.
+	lastRotated bool
+	// placed holds the cells covered by the piece that locked most recently,
+	// which was a placedKind.
+	placed     []cell
+	placedKind tetronimoes.Kind
+
+	// practice turns on undo, reordering the queue and painting the board.
+	// history holds a snapshot from each time a piece spawned, for undo.
+	practice bool
+	history  []snapshot
+
+	ticks     int  // Number of ticks the game has been running for, not counting pauses.
+	frame     int  // Number of calls to Tick, including while paused.
+	paused    bool // Whether the player has paused the game.
+	countdown int  // Number of ticks left before the game resumes after being unpaused.
+
+	score int
+	level int
+	// gravity is how fast the falling piece drops. Every tick gravity.Rows is
+	// added to fallProgress, and the piece drops a row for each gravity.Ticks
+	// in it.
+	gravity      Gravity
+	fallProgress int
+
+	// lockDelay is the number of ticks a piece can rest on the stack before
+	// it locks. Zero means it locks as soon as gravity tries to move it down
+	// and it can't. lockTimer counts up while the piece is resting, and is
+	// reset whenever it moves down a row.
+	lockDelay int
+	lockTimer int
+	// are is the number of ticks between a piece locking and the next one
+	// spawning. areTimer counts down to the spawn.
+	are      int
+	areTimer int
+
+	// fade, if set, makes blocks on the board disappear some time after they
+	// lock.
+	fade *Fade
+
+	// attack is how garbage is sent and received. combo counts the pieces in
+	// a row after the first that cleared lines, or is -1, and backToBack is
+	// whether the last clear was a tetris or T-spin.
+	attack     Attack
+	combo      int
+	backToBack bool
+	// incoming holds batches of garbage rows received from opponents, oldest
+	// first. They're pushed onto the board with holes chosen by garbageRng,
+	// starting from hole.
+	incoming   []int
+	garbageRng *Rand
+	hole       int
+
+	// recording is whether calls into the game are being logged in records.
+	// See record.go.
+	recording bool
+	records   []Record
+	// playback is whether the game is being played back from a recording,
+	// rather than played.
+	playback bool
+	// botPlayed is whether a Bot has pressed keys in the game.
+	botPlayed bool
+	// caption holds lines of text shown above the HUD.
+	caption []string
+}
+
+// Gravity is the speed that pieces fall at: Rows rows every Ticks ticks.
+type Gravity struct {
+	Rows, Ticks int
+}
+
+// DefaultGravity drops pieces three rows a second.
+var DefaultGravity = Gravity{Rows: 1, Ticks: TicksPerSecond / 3}
+
+// Points for clearing 1, 2, 3 or 4 rows at once, which is multiplied by the
+// level.
+var lineScores = []int{0, 100, 300, 500, 800}
+
+// Points for each row that a piece is hard dropped, and soft dropped.
+const (
+	hardDropScore = 2
+	softDropScore = 1
+)
+
+func lineScore(lines int) int {
+	if lines >= len(lineScores) {
+		lines = len(lineScores) - 1
+	}
+	return lineScores[lines]
+}
+
+// NewState creates an empty board. The config is expected to be valid.
+func NewState(config Config) *State {
+	if config.Seed == 0 {
+		config.Seed = time.Now().UnixNano()
+	}
+	b := make([][]*block, config.Height+config.BufferHeight)
+	for row := range b {
+		b[row] = make([]*block, config.Width)
+	}
+	garbageRng := newGarbageRng(config.Seed)
+	return &State{
+		config:     config,
+		board:      b,
+		rng:        NewRand(config.Seed),
+		level:      1,
+		gravity:    DefaultGravity,
+		attack:     DefaultAttack(),
+		combo:      -1,
+		garbageRng: garbageRng,
+		hole:       garbageRng.Intn(config.Width),
+	}
+}
+
+// Width returns the number of columns in the board.
+func (s *State) Width() int {
+	return s.config.Width
+}
+
+// Height returns the number of visible rows in the board.
+func (s *State) Height() int {
+	return s.config.Height
+}
+
+// Seed returns the seed of the game's random numbers. Two games with the same
+// seed get the same pieces.
+func (s *State) Seed() int64 {
+	return s.config.Seed
+}
+
+// Config returns the game's config, with the seed it was given if it was
+// created without one.
+func (s *State) Config() Config {
+	return s.config
+}
+
+// SetMode sets the goals of the game and lets the mode set the game up. It
+// should be called before the game starts. A nil mode means the game carries
+// on until a piece tops out.
+func (s *State) SetMode(mode Mode) {
+	s.mode = mode
+	if mode != nil {
+		mode.Start(s)
+	}
+}
+
+// Mode returns the game's mode, or nil if it doesn't have one.
+func (s *State) Mode() Mode {
+	return s.mode
+}
+
+// GameOver returns whether the game has ended, either because a piece topped
+// out or because End was called. Once true, Tick, Step and ApplyInputs do
+// nothing.
+func (s *State) GameOver() bool {
+	return s.gameOver
+}
+
+// ToppedOut returns whether the game ended because a piece didn't fit on the
+// board.
+func (s *State) ToppedOut() bool {
+	return s.toppedOut
+}
+
+// End finishes the game, typically because the goal of its mode was reached.
+func (s *State) End() {
+	if s.gameOver {
+		return
+	}
+	s.record(Record{Type: RecordEnd})
+	s.end()
+}
+
+func (s *State) end() {
+	s.gameOver = true
+	s.emit(Event{Type: GameEnded})
+}
+
+// Restart starts the game again from scratch with the same config, and the
+// same seed, and restarts its mode.
+func (s *State) Restart() {
+	mode, fade, attack, recording := s.mode, s.fade, s.attack, s.recording
+	*s = *NewState(s.config)
+	s.fade, s.attack, s.recording = fade, attack, recording
+	s.SetMode(mode)
+}
+
+// Copy returns a copy of the game that carries on separately from it. The
+// mode is copied too if it's a ModeCopier, and is otherwise shared with the
+// copy.
+func (s *State) Copy() *State {
+	c := *s
+	// Blocks and snapshots are never changed once made, so they can be
+	// shared.
+	c.board = copyBoard(s.board)
+	if s.fallingPiece != nil {
+		c.fallingPiece = s.fallingPiece.Copy()
+	}
+	c.queue = append([]tetronimoes.Kind(nil), s.queue...)
+	c.history = append([]snapshot(nil), s.history...)
+	c.incoming = append([]int(nil), s.incoming...)
+	c.records = append([]Record(nil), s.records...)
+	c.caption = append([]string(nil), s.caption...)
+	c.rng, c.garbageRng = s.rng.Copy(), s.garbageRng.Copy()
+	if m, ok := s.mode.(ModeCopier); ok {
+		c.mode = m.Copy()
+	}
+	return &c
+}
+
+// topOut ends the game because a piece didn't fit on the board.
+func (s *State) topOut() {
+	if s.gameOver {
+		return
+	}
+	s.toppedOut = true
+	s.end()
+}
+
+// Score returns the number of points earned so far.
+func (s *State) Score() int {
+	return s.score
+}
+
+// Level returns the current level, which multiplies the points earned for
+// clearing rows. It starts at 1.
+func (s *State) Level() int {
+	return s.level
+}
+
+// SetLevel changes the current level. It doesn't change the gravity.
+func (s *State) SetLevel(level int) {
+	s.level = level
 }
 
-func NewState() *State {
-	b := make([][]*block, Height)
-	for row := 0; row < Height; row++ {
-		b[row] = make([]*block, Width)
+// SetGravity changes the speed that pieces fall at. g.Ticks must be
+// positive. If g.Rows is zero then pieces don't fall on their own.
+func (s *State) SetGravity(g Gravity) {
+	s.gravity = g
+}
+
+// SetLockDelay changes how many ticks a piece can rest on the stack before it
+// locks. Zero means it locks the next time gravity moves it.
+func (s *State) SetLockDelay(ticks int) {
+	s.lockDelay = ticks
+}
+
+// SetARE changes how many ticks there are between a piece locking and the
+// next one spawning.
+func (s *State) SetARE(ticks int) {
+	s.are = ticks
+}
+
+// Stats returns counters describing the game so far.
+func (s *State) Stats() Stats {
+	stats := s.stats
+	stats.Ticks = s.ticks
+	return stats
+}
+
+// Tick advances the game clock by one tick. It spawns a new piece if there
+// isn't one, and drops the falling piece as far as gravity says it should
+// fall. The clock doesn't advance while the game is paused, or while it's
+// counting down to resume.
+func (s *State) Tick() {
+	s.frame++
+	if s.gameOver || s.paused {
+		return
+	}
+	if s.countdown > 0 {
+		s.countdown--
+		return
+	}
+	s.ticks++
+	if s.mode != nil {
+		s.mode.Tick(s)
+		if s.gameOver {
+			return
+		}
+	}
+
+	if s.fallingPiece == nil {
+		if s.areTimer > 0 {
+			s.areTimer--
+			return
+		}
+		s.spawn()
+		if s.gameOver {
+			return
+		}
+	}
+	s.fallProgress += s.gravity.Rows
+	for s.fallProgress >= s.gravity.Ticks && !s.gameOver {
+		s.fallProgress -= s.gravity.Ticks
+		if s.lockDelay == 0 {
+			s.Step()
+		} else if !s.moveDown() {
+			// Resting pieces lock below, once the lock delay is up.
+			s.fallProgress = 0
+			break
+		}
+		if s.fallingPiece == nil {
+			// The piece locked. The next one starts falling from scratch.
+			s.fallProgress = 0
+			return
+		}
+	}
+	if s.lockDelay > 0 && s.resting() {
+		s.lockTimer++
+		if s.lockTimer >= s.lockDelay {
+			s.lock()
+		}
 	}
-	return &State{board: b}
+}
+
+// Pause stops the game clock until Resume is called. The board isn't drawn
+// while paused, so it can't be studied in the meantime.
+func (s *State) Pause() {
+	if s.gameOver {
+		return
+	}
+	s.record(Record{Type: RecordPause})
+	s.pause()
+}
+
+func (s *State) pause() {
+	s.paused = true
+	s.countdown = 0
+}
+
+// Resume unpauses the game. The board is shown immediately but the clock
+// only continues after a short countdown.
+func (s *State) Resume() {
+	if !s.paused {
+		return
+	}
+	s.record(Record{Type: RecordResume})
+	s.resume()
+}
+
+func (s *State) resume() {
+	s.paused = false
+	s.countdown = resumeCountdown
+}
+
+// Paused returns whether the game clock is stopped, either because the game
+// is paused or because it's counting down to resume.
+func (s *State) Paused() bool {
+	return s.paused || s.countdown > 0
 }
 
 // Apply inputs to the controlled player, if one exists.
 func (s *State) ApplyInputs(keyboardHandler *keyboard.Handler) {
-	// Make shape drop all the way down
-	if keyboardHandler.SpacePressed() && !keyboardHandler.WasSpacePressed() {
-		for s.fallingPiece != nil {
+	s.Apply(KeyboardInput(keyboardHandler, keyboard.SinglePlayer))
+}
+
+// Apply a player's input for this tick.
+func (s *State) Apply(in Input) {
+	if in != (Input{}) {
+		s.record(Record{Type: RecordInput, Input: in})
+	}
+	if in.Restart {
+		s.Restart()
+		return
+	}
+	// Practice controls. They do nothing outside of practice.
+	if in.Undo {
+		s.Undo()
+	}
+	if s.gameOver {
+		return
+	}
+	if in.Pause {
+		if s.paused {
+			s.resume()
+		} else {
+			s.pause()
+		}
+	}
+	if s.Paused() {
+		return
+	}
+	if in.Cycle {
+		s.CycleQueue()
+	}
+	if in.Swap {
+		s.SwapNext()
+	}
+	// Make shape drop all the way down. There's nothing to drop while waiting
+	// for the next piece to spawn, so holding the key doesn't skip the wait.
+	if in.HardDrop && s.fallingPiece != nil {
+		s.stats.Keys++
+		for s.fallingPiece != nil && !s.gameOver {
 			s.Step()
+			if s.fallingPiece != nil {
+				s.score += hardDropScore
+			}
 		}
 	}
 
 	if s.fallingPiece == nil {
 		return
 	}
-	if keyboardHandler.UpPressed() && !keyboardHandler.WasUpPressed() {
+	if in.RotateCounterClockwise {
+		s.stats.Keys++
 		s.fallingPiece.RotateCounterClockwise()
 		if s.BoardIntersects(s.fallingPiece) {
 			s.fallingPiece.RotateClockwise()
+		} else {
+			s.lastRotated = true
 		}
 	}
-	if keyboardHandler.DownPressed() && !keyboardHandler.WasDownPressed() {
+	if in.RotateClockwise {
+		s.stats.Keys++
 		s.fallingPiece.RotateClockwise()
 		if s.BoardIntersects(s.fallingPiece) {
 			s.fallingPiece.RotateCounterClockwise()
+		} else {
+			s.lastRotated = true
 		}
 	}
-	if keyboardHandler.LeftPressed() && !keyboardHandler.WasLeftPressed() {
+	if in.Left {
+		s.stats.Keys++
 		origin := s.fallingPiece.Origin()
-		origin.X--
+		origin.X -= float32(s.scale())
 		if s.BoardIntersects(s.fallingPiece) {
-			origin.X++
+			origin.X += float32(s.scale())
+		} else {
+			s.lastRotated = false
 		}
 	}
-	if keyboardHandler.RightPressed() && !keyboardHandler.WasRightPressed() {
+	if in.Right {
+		s.stats.Keys++
 		origin := s.fallingPiece.Origin()
-		origin.X++
+		origin.X += float32(s.scale())
 		if s.BoardIntersects(s.fallingPiece) {
-			origin.X--
+			origin.X -= float32(s.scale())
+		} else {
+			s.lastRotated = false
+		}
+	}
+	if in.SoftDrop {
+		s.stats.Keys++
+		for s.moveDown() {
+			s.score += softDropScore
 		}
 	}
 }
@@ -107,86 +577,191 @@ func empty(row []*block) bool {
 	return true
 }
 
-func (s *State) Step() {
-	// Check for full rows. Remove them.
-	for i := Height - 1; i >= 0; i-- {
-		if filled(s.board[i]) {
-			log.Println("Row", i, "filled -> removed it")
-			s.board[i] = make([]*block, Width)
-
-			// and shift everything down
-			for j := i; j > 0; j-- {
-				copy(s.board[j], s.board[j-1])
+// filledRows returns the lowest and highest rows of points that contain a
+// block.
+func filledRows(points [][]bool) (bottom, top int) {
+	bottom, top = len(points), -1
+	for row := range points {
+		for _, p := range points[row] {
+			if p {
+				if row < bottom {
+					bottom = row
+				}
+				top = row
+				break
 			}
-			s.board[0] = make([]*block, Width)
 		}
 	}
+	return bottom, top
+}
 
-	// s.Print() // Print the board - for debugging
+func (s *State) Step() {
+	if s.gameOver {
+		return
+	}
+
+	// log.Printf("Board:\n%v", s.Board()) // Print the board - for debugging
 
 	// Add a new falling piece if there isn't an existing one
 	if s.fallingPiece == nil {
-		s.fallingPiece = tetronimoes.NewRandomShape()
-		origin := s.fallingPiece.Origin()
-		origin.X = float32((Width / 2) - len(s.fallingPiece.Points())/2)
-		origin.Y = 0
+		s.spawn()
+		if s.gameOver {
+			return
+		}
 	}
 
 	// Try to make the falling piece go down by 1. If it can't do it, remove its
 	// falling status and make it part of the board.
+	if !s.moveDown() {
+		s.lock()
+	}
+}
+
+// moveDown moves the falling piece down a row, if there's space for it, and
+// returns whether it moved.
+func (s *State) moveDown() bool {
 	origin := s.fallingPiece.Origin()
-	origin.Y++
+	origin.Y -= float32(s.scale())
 	if s.BoardIntersects(s.fallingPiece) {
-		origin.Y--
-		s.AddToBoard(s.fallingPiece)
-		s.fallingPiece = nil
-	}
-}
-
-// Draw the game state assuming the origin is at (x,y) and has (width,height).
-// Assumes the overall board aspect ratio is 1:2
-func (s *State) Draw(renderer *sdl.Renderer, x, y, width, height int) {
-	blockWidth := int(float32(width) / float32(Width))
-	blockHeight := int(float32(height) / float32(Height))
-
-	// Draw all of the stable blocks.
-	for row := 0; row < Height; row++ {
-		for col := 0; col < Width; col++ {
-			if cell := s.board[row][col]; cell != nil {
-				rect := &sdl.Rect{
-					X: int32((x + col) * blockWidth),
-					Y: int32((y + row) * blockHeight),
-					W: int32(blockWidth),
-					H: int32(blockHeight),
-				}
-				renderer.SetDrawColor(s.board[row][col].R, s.board[row][col].G, s.board[row][col].B, s.board[row][col].A)
-				renderer.FillRect(rect)
-			}
+		origin.Y += float32(s.scale())
+		return false
+	}
+	s.lockTimer = 0
+	s.lastRotated = false
+	return true
+}
+
+// resting returns whether the falling piece is sitting on the stack or the
+// floor, so it can't move down.
+func (s *State) resting() bool {
+	if s.fallingPiece == nil {
+		return false
+	}
+	origin := s.fallingPiece.Origin()
+	origin.Y -= float32(s.scale())
+	defer func() { origin.Y += float32(s.scale()) }()
+	return s.BoardIntersects(s.fallingPiece)
+}
+
+// spawn creates a new falling piece centered in the hidden rows, just above
+// the visible board. If it doesn't fit there, or the queue of pieces has run
+// out, then the game is over.
+func (s *State) spawn() {
+	s.fillQueue()
+	if len(s.queue) == 0 {
+		log.Println("Out of pieces -> game over")
+		s.end()
+		return
+	}
+	s.fallingPiece = s.newPiece(s.queue[0])
+	s.queue = s.queue[1:]
+	s.fillQueue()
+	s.lockTimer = 0
+	s.lastRotated = false
+
+	if s.BoardIntersects(s.fallingPiece) {
+		log.Println("New piece is blocked -> game over")
+		s.topOut()
+		return
+	}
+	if s.practice {
+		s.remember()
+	}
+	s.emit(Event{Type: PieceSpawned, Piece: s.fallingPiece.Kind()})
+}
+
+// newPiece returns a piece of the given kind where new pieces spawn.
+func (s *State) newPiece(kind tetronimoes.Kind) *tetronimoes.Shape {
+	piece := tetronimoes.NewShape(kind)
+	points := piece.Points()
+	bottom, top := filledRows(points)
+	scale := s.scale()
+
+	origin := piece.Origin()
+	// Round down to a whole point, so big pieces line up with the walls.
+	origin.X = float32((s.config.Width/2 - len(points)*scale/2) / scale * scale)
+	// Push the piece down if the buffer is too short to hold all of it.
+	y := s.config.Height - bottom*scale
+	if overflow := y + (top+1)*scale - len(s.board); overflow > 0 {
+		y -= overflow
+	}
+	origin.Y = float32(y)
+	return piece
+}
+
+// lock makes the falling piece part of the board and clears any rows that
+// it completes. If the piece is entirely in the hidden rows then the game is
+// over.
+func (s *State) lock() {
+	bottom, _ := filledRows(s.fallingPiece.Points())
+	lowest := int(s.fallingPiece.Origin().Y) + bottom*s.scale()
+	tSpin := s.isTSpin()
+	kind := s.fallingPiece.Kind()
+	s.placed, s.placedKind = s.cells(s.fallingPiece), kind
+	s.AddToBoard(s.fallingPiece)
+	s.fallingPiece = nil
+	s.areTimer = s.are
+	lines, garbage := s.clearRows()
+	// In big games rows are cleared in pairs, which count as one line.
+	lines = (lines + s.scale() - 1) / s.scale()
+	garbage = (garbage + s.scale() - 1) / s.scale()
+
+	attack := s.cancel(s.attackFor(lines, tSpin))
+
+	s.stats.Pieces++
+	s.stats.Lines += lines
+	s.score += lineScore(lines) * s.level
+	s.emit(Event{Type: PieceLocked, Piece: kind, Lines: lines, Garbage: garbage, TSpin: tSpin, Attack: attack})
+	if lines > 0 {
+		s.emit(Event{Type: LinesCleared, Lines: lines, Garbage: garbage, TSpin: tSpin, Attack: attack})
+	}
+	if lowest >= s.config.Height {
+		log.Println("Piece locked above the visible board -> game over")
+		s.topOut()
+		return
+	}
+	if lines == 0 && len(s.incoming) > 0 {
+		s.pushIncoming()
+	}
+}
+
+// isTSpin returns whether the falling piece is a T which rotated into place,
+// with at least three of the four corners around its center blocked.
+func (s *State) isTSpin() bool {
+	if s.fallingPiece.Kind() != tetronimoes.T || !s.lastRotated {
+		return false
+	}
+	// The T's center is in the middle of its 3x3 points, whichever way it's
+	// rotated.
+	origin := s.fallingPiece.Origin()
+	blocked := 0
+	for _, corner := range []cell{{0, 0}, {2, 0}, {0, 2}, {2, 2}} {
+		col := int(origin.X) + corner.col*s.scale()
+		row := int(origin.Y) + corner.row*s.scale()
+		if col < 0 || col >= s.config.Width || row < 0 || row >= len(s.board) || s.board[row][col] != nil {
+			blocked++
 		}
 	}
+	return blocked >= 3
+}
 
-	// Draw the falling piece.
-	if s.fallingPiece != nil {
-		fallingOrigin := s.fallingPiece.Origin()
-		x, y := int(fallingOrigin.X), int(fallingOrigin.Y)
-		r, g, b, a := s.fallingPiece.Color()
-		points := s.fallingPiece.Points()
-		//fmt.Println(points)
-		for col := 0; col < len(points); col++ {
-			for row := 0; row < len(points); row++ {
-				if points[row][col] {
-					rect := &sdl.Rect{
-						X: int32((x + col) * blockWidth),
-						Y: int32((y + row) * blockHeight),
-						W: int32(blockWidth),
-						H: int32(blockHeight),
-					}
-					renderer.SetDrawColor(r, g, b, a)
-					renderer.FillRect(rect)
-				}
-			}
+// clearRows removes all full rows and shifts everything above them down. It
+// returns the number of rows removed, and how many of those were garbage.
+func (s *State) clearRows() (cleared, garbage int) {
+	for row := 0; row < len(s.board); {
+		if !filled(s.board[row]) {
+			row++
+			continue
 		}
+		log.Println("Row", row, "filled -> removed it")
+		if hasGarbage(s.board[row]) {
+			garbage++
+		}
+		copy(s.board[row:], s.board[row+1:])
+		s.board[len(s.board)-1] = make([]*block, s.config.Width)
+		cleared++
 	}
+	return cleared, garbage
 }
 
 func (s *State) BoardIntersects(shape *tetronimoes.Shape) bool {
@@ -195,27 +770,20 @@ func (s *State) BoardIntersects(shape *tetronimoes.Shape) bool {
 		return false
 	}
 
-	origin := shape.Origin()
-	points := shape.Points()
-	for col := int(origin.X); col < len(points)+int(origin.X); col++ {
-		for row := int(origin.Y); row < len(points)+int(origin.Y); row++ {
-			// The tetromino shape is represented as a bool array with true being
-			// a block in that space. Ignore any non-block spaces:
-			if !points[row-int(origin.Y)][col-int(origin.X)] {
-				continue
-			}
-			// Can't go lower than the bottom.
-			if row >= Height {
-				return true
-			}
-			// Protect left and right edges.
-			if col < 0 || col >= Width {
-				return true
-			}
-			// Standard intersection inside the board with an existing block.
-			if s.board[row][col] != nil {
-				return true
-			}
+	// The tetromino shape is represented as a bool array with true being
+	// a block in that space. Only the cells covered by blocks are checked.
+	for _, c := range s.cells(shape) {
+		// Can't go lower than the bottom or higher than the hidden rows.
+		if c.row < 0 || c.row >= len(s.board) {
+			return true
+		}
+		// Protect left and right edges.
+		if c.col < 0 || c.col >= s.config.Width {
+			return true
+		}
+		// Standard intersection inside the board with an existing block.
+		if s.board[c.row][c.col] != nil {
+			return true
 		}
 	}
 	return false
@@ -227,31 +795,48 @@ func (s *State) AddToBoard(shape *tetronimoes.Shape) {
 		return
 	}
 
-	origin := shape.Origin()
-	points := shape.Points()
 	r, g, b, a := shape.Color()
-	for col := int(origin.X); col < len(points)+int(origin.X); col++ {
-		for row := int(origin.Y); row < len(points)+int(origin.Y); row++ {
-			if points[row-int(origin.Y)][col-int(origin.X)] {
-				if s.board[row][col] != nil {
-					fmt.Println("Error adding shape to board. Overlapping blocks at ", row, col)
-					return
-				}
-				s.board[row][col] = &block{r, g, b, a}
-			}
+	for _, c := range s.cells(shape) {
+		if s.board[c.row][c.col] != nil {
+			fmt.Println("Error adding shape to board. Overlapping blocks at ", c.row, c.col)
+			return
 		}
+		s.board[c.row][c.col] = &block{R: r, G: g, B: b, A: a, lockedAt: s.ticks, kind: shape.Kind()}
 	}
 }
 
-func (s *State) Print() {
-	for row := Height - 1; row >= 0; row-- {
-		for col := 0; col < Width; col++ {
-			if s.board[row][col] != nil {
-				fmt.Printf("1")
-			} else {
-				fmt.Printf("0")
-			}
+// SetCell fills a cell of the board with a block colored like the given kind
+// of shape, or with a plain block if kind is zero. Cells outside the board are
+// ignored.
+func (s *State) SetCell(col, row int, kind tetronimoes.Kind) {
+	if col < 0 || col >= s.config.Width || row < 0 || row >= len(s.board) {
+		return
+	}
+	b := &block{lockedAt: s.ticks, kind: kind}
+	if shape := tetronimoes.NewShape(kind); shape != nil {
+		b.R, b.G, b.B, b.A = shape.Color()
+	} else {
+		b.kind = 0
+		b.R, b.G, b.B, b.A = tetronimoes.GarbageColor()
+	}
+	s.board[row][col] = b
+}
+
+// ClearCell empties a cell of the board. Cells outside the board are ignored.
+func (s *State) ClearCell(col, row int) {
+	if col < 0 || col >= s.config.Width || row < 0 || row >= len(s.board) {
+		return
+	}
+	s.board[row][col] = nil
+}
+
+// BoardEmpty returns whether there are no blocks on the board, not counting
+// the falling piece.
+func (s *State) BoardEmpty() bool {
+	for _, row := range s.board {
+		if !empty(row) {
+			return false
 		}
-		fmt.Println()
 	}
+	return true
 }
@@ -0,0 +1,144 @@
+package gamestate
+
+import (
+	"fmt"
+
+	"github.com/omustardo/tetris/sdl-tetris/tetronimoes"
+)
+
+// Mode is a set of goals layered on top of a game, like clearing 40 lines as
+// fast as possible. It's told about everything that happens in the game and
+// decides when the game is finished by calling State.End.
+type Mode interface {
+	// Start is called when the mode is given to a game, so it can set the
+	// game up. E.g. by setting the level or gravity. It's called again each
+	// time the game is restarted, so it should also reset any progress.
+	Start(s *State)
+	// Tick is called on every tick of the game clock, before pieces move.
+	Tick(s *State)
+	// HandleEvent is called for every event in the game, as it happens.
+	HandleEvent(s *State, e Event)
+	// HUD returns lines of text to show above the board while playing.
+	HUD(s *State) []string
+	// Results returns lines of text summarizing the game once it's over.
+	Results(s *State) []string
+}
+
+// ModeCopier is a mode that keeps track of its own progress through a game,
+// and so has to be copied along with the game by State.Copy.
+type ModeCopier interface {
+	Mode
+	// Copy returns a copy of the mode that carries on separately from it.
+	Copy() Mode
+}
+
+type EventType int
+
+const (
+	// A piece became part of the board. Lines is the number of rows it cleared,
+	// which may be zero.
+	PieceLocked EventType = iota
+	// One or more rows were cleared by a single piece.
+	LinesCleared
+	// The game is over, either because a piece topped out or because End was
+	// called.
+	GameEnded
+	// A new piece appeared at the top of the board.
+	PieceSpawned
+)
+
+// Event is something that happened in a game.
+type Event struct {
+	Type  EventType
+	Tick  int // Game clock tick that the event happened on.
+	Lines int // Number of rows cleared, for PieceLocked and LinesCleared.
+	// Number of the cleared rows that were garbage, for PieceLocked and
+	// LinesCleared.
+	Garbage int
+	// Whether the piece was a T that rotated into a spot with at least three
+	// of its corners blocked, for PieceLocked and LinesCleared.
+	TSpin bool
+	// Rows of garbage to send to opponents, for PieceLocked and LinesCleared.
+	// Any garbage waiting to be received has already been cancelled out.
+	Attack int
+	// Kind of the piece, for PieceLocked and PieceSpawned.
+	Piece tetronimoes.Kind
+}
+
+// Stats are counters describing a game so far.
+type Stats struct {
+	Ticks  int // Game clock ticks, not counting pauses.
+	Pieces int // Number of pieces locked onto the board.
+	Lines  int // Number of rows cleared.
+	Keys   int // Number of presses of the movement, rotation and drop keys.
+}
+
+// Seconds returns how long the game has been running, not counting pauses.
+func (s Stats) Seconds() float64 {
+	return float64(s.Ticks) / TicksPerSecond
+}
+
+// PiecesPerSecond returns the average number of pieces placed each second.
+func (s Stats) PiecesPerSecond() float64 {
+	if s.Ticks == 0 {
+		return 0
+	}
+	return float64(s.Pieces) / s.Seconds()
+}
+
+// KeysPerPiece returns the average number of key presses used to place each
+// piece.
+func (s Stats) KeysPerPiece() float64 {
+	if s.Pieces == 0 {
+		return 0
+	}
+	return float64(s.Keys) / float64(s.Pieces)
+}
+
+// FormatTicks formats a number of game clock ticks as minutes, seconds and
+// hundredths of a second, like 1:05.25
+func FormatTicks(ticks int) string {
+	hundredths := ticks * 100 / TicksPerSecond
+	return fmt.Sprintf("%d:%02d.%02d", hundredths/6000, hundredths/100%60, hundredths%100)
+}
+
+// emit tells the mode, if there is one, about an event.
+func (s *State) emit(e Event) {
+	e.Tick = s.ticks
+	if s.mode != nil {
+		s.mode.HandleEvent(s, e)
+	}
+}
+
+// hud returns the lines of text to show above the board.
+func (s *State) hud() []string {
+	if s.mode == nil {
+		return s.caption
+	}
+	return append(append([]string(nil), s.caption...), s.mode.HUD(s)...)
+}
+
+// SetCaption sets lines of text to show above the HUD, for things outside of
+// the game itself, like the controls of a replay.
+func (s *State) SetCaption(lines ...string) {
+	s.caption = lines
+}
+
+// results returns the lines of text to show over the board once the game is
+// over.
+func (s *State) results() []string {
+	if s.mode == nil {
+		return []string{"GAME OVER"}
+	}
+	return s.mode.Results(s)
+}
+
+// HUD returns the lines shown above the board.
+func (s *State) HUD() []string {
+	return s.hud()
+}
+
+// Results returns the lines shown over the board once the game is over.
+func (s *State) Results() []string {
+	return s.results()
+}
@@ -0,0 +1,27 @@
+package gamestate
+
+import "github.com/omustardo/tetris/sdl-tetris/mouse"
+
+// ApplyMouse paints blocks onto the board while practicing: the left button
+// fills the cell under the cursor and the right button empties it. The area
+// is the one passed to Draw, with its upper left corner at (x,y).
+func (s *State) ApplyMouse(mouseHandler *mouse.Handler, x, y, width, height int) {
+	if !s.practice || s.Paused() {
+		return
+	}
+	left, right := mouseHandler.LeftPressed(), mouseHandler.RightPressed()
+	if !left && !right {
+		return
+	}
+	boardX, boardY, blockSize := s.boardArea(x, y, width, height, len(s.hud()))
+	if mouseHandler.X < boardX || mouseHandler.Y < boardY || blockSize == 0 {
+		return
+	}
+	// The board has row 0 at the bottom.
+	col := (mouseHandler.X - boardX) / blockSize
+	row := s.config.Height - 1 - (mouseHandler.Y-boardY)/blockSize
+	if col >= s.config.Width || row < 0 {
+		return
+	}
+	s.Paint(col, row, left)
+}
@@ -13,6 +13,28 @@ const (
 	sdlKeyboardStateSize = 512
 )
 
+// Bindings are the keys that move a player's piece, so that more than one
+// player can share a keyboard.
+type Bindings struct {
+	Left, Right                             sdl.Keycode
+	RotateClockwise, RotateCounterClockwise sdl.Keycode
+	HardDrop, SoftDrop                      sdl.Keycode
+}
+
+var (
+	// SinglePlayer uses the arrow keys, with space to drop and right shift to
+	// soft drop.
+	SinglePlayer = Bindings{Left: sdl.SCANCODE_LEFT, Right: sdl.SCANCODE_RIGHT, RotateClockwise: sdl.SCANCODE_DOWN, RotateCounterClockwise: sdl.SCANCODE_UP, HardDrop: sdl.SCANCODE_SPACE, SoftDrop: sdl.SCANCODE_RSHIFT}
+	// Player1 and Player2 split the keyboard in two for versus games: WASD
+	// with space to drop and left shift to soft drop, and the arrow keys with
+	// enter to drop and right shift to soft drop.
+	Player1 = Bindings{Left: sdl.SCANCODE_A, Right: sdl.SCANCODE_D, RotateClockwise: sdl.SCANCODE_S, RotateCounterClockwise: sdl.SCANCODE_W, HardDrop: sdl.SCANCODE_SPACE, SoftDrop: sdl.SCANCODE_LSHIFT}
+	Player2 = Bindings{Left: sdl.SCANCODE_LEFT, Right: sdl.SCANCODE_RIGHT, RotateClockwise: sdl.SCANCODE_DOWN, RotateCounterClockwise: sdl.SCANCODE_UP, HardDrop: sdl.SCANCODE_RETURN, SoftDrop: sdl.SCANCODE_RSHIFT}
+	// TargetKeys pick a targeting mode in online battles, in the order of
+	// protocol.Targetings.
+	TargetKeys = []sdl.Keycode{sdl.SCANCODE_1, sdl.SCANCODE_2, sdl.SCANCODE_3, sdl.SCANCODE_4}
+)
+
 type Handler struct {
 	// Keyboard states from sdl.GetKeyboardState.
 	// Essentially array of bools indexed by sdl.Keycode
@@ -75,6 +97,21 @@ func (h *Handler) DownPressed() bool {
 func (h *Handler) SpacePressed() bool {
 	return h.IsKeyDown(sdl.SCANCODE_SPACE)
 }
+func (h *Handler) PausePressed() bool {
+	return h.IsKeyDown(sdl.SCANCODE_P) || h.IsKeyDown(sdl.SCANCODE_ESCAPE)
+}
+func (h *Handler) RestartPressed() bool {
+	return h.IsKeyDown(sdl.SCANCODE_R)
+}
+func (h *Handler) UndoPressed() bool {
+	return h.IsKeyDown(sdl.SCANCODE_Z) || h.IsKeyDown(sdl.SCANCODE_BACKSPACE)
+}
+func (h *Handler) CyclePressed() bool {
+	return h.IsKeyDown(sdl.SCANCODE_C)
+}
+func (h *Handler) SwapPressed() bool {
+	return h.IsKeyDown(sdl.SCANCODE_X)
+}
 
 // WasKeyDown returns whether the provided key was pressed in the previous frame.
 // Useful for calling functions at the start of a keypress by using:
@@ -102,6 +139,21 @@ func (h *Handler) WasDownPressed() bool {
 func (h *Handler) WasSpacePressed() bool {
 	return h.WasKeyDown(sdl.SCANCODE_SPACE)
 }
+func (h *Handler) WasPausePressed() bool {
+	return h.WasKeyDown(sdl.SCANCODE_P) || h.WasKeyDown(sdl.SCANCODE_ESCAPE)
+}
+func (h *Handler) WasRestartPressed() bool {
+	return h.WasKeyDown(sdl.SCANCODE_R)
+}
+func (h *Handler) WasUndoPressed() bool {
+	return h.WasKeyDown(sdl.SCANCODE_Z) || h.WasKeyDown(sdl.SCANCODE_BACKSPACE)
+}
+func (h *Handler) WasCyclePressed() bool {
+	return h.WasKeyDown(sdl.SCANCODE_C)
+}
+func (h *Handler) WasSwapPressed() bool {
+	return h.WasKeyDown(sdl.SCANCODE_X)
+}
 
 // String prints out all of the keys pressed in the previous frame, and all of
 // the keys pressed in the current frame.
@@ -3,19 +3,57 @@ package main
 // TODO: Improve logging: https://www.goinggo.net/2013/11/using-log-package-in-go.html
 
 import (
+	"flag"
 	"fmt"
 	"log"
+	"math"
 	"runtime"
+	"strings"
 	"time"
 
+	"github.com/omustardo/tetris/sdl-tetris/fumen"
+	"github.com/omustardo/tetris/sdl-tetris/gamepad"
 	"github.com/omustardo/tetris/sdl-tetris/gamestate"
 	"github.com/omustardo/tetris/sdl-tetris/keyboard"
+	"github.com/omustardo/tetris/sdl-tetris/modes"
+	"github.com/omustardo/tetris/sdl-tetris/mouse"
+	"github.com/omustardo/tetris/sdl-tetris/netplay"
+	"github.com/omustardo/tetris/sdl-tetris/replay"
+	"github.com/omustardo/tetris/sdl-tetris/savegame"
+	"github.com/omustardo/tetris/sdl-tetris/spectator"
+	"github.com/omustardo/tetris/sdl-tetris/tbp"
+	"github.com/omustardo/tetris/sdl-tetris/versus"
 	"github.com/veandco/go-sdl2/sdl"
 )
 
+var (
+	boardWidth   = flag.Int("board_width", gamestate.DefaultWidth, "number of columns in the board")
+	boardHeight  = flag.Int("board_height", gamestate.DefaultHeight, "number of visible rows in the board")
+	bufferHeight = flag.Int("buffer_height", gamestate.DefaultBufferHeight, "number of hidden rows above the board where pieces spawn")
+	mode         = flag.String("mode", "", "game mode, one of: "+strings.Join(modes.Names(), ", ")+". Leave empty for an endless game.")
+	seed         = flag.Int64("seed", 0, "seed for the pieces and garbage, so games can be replayed or raced. 0 picks one at random.")
+	messiness    = flag.Float64("messiness", modes.DefaultMessiness, "for dig modes, the chance from 0 to 1 that each garbage row's hole moves")
+	fumenSetup   = flag.String("fumen", "", "for zen mode, a setup to practice from as shared by fumen editors, like v115@vhAAgH. Its first page's board is used, followed by the pieces placed in it.")
+	puzzlePath   = flag.String("puzzle", "", "for the puzzle mode, a puzzle file or a directory holding a pack of them. Leave empty for the puzzles that come with the game.")
+	big          = flag.Bool("big", false, "make every piece twice the size, like playing on a board half as wide and high")
+	invisible    = flag.Bool("invisible", false, "hide blocks as soon as they lock, until the game is over")
+	fadeSeconds  = flag.Float64("fade_seconds", 0, "if positive, blocks fade out this many seconds after they lock, until the game is over")
+	versusGame   = flag.Bool("versus", false, "play a two player game on split boards, where clearing lines sends garbage to the other player")
+	useGamepad   = flag.Bool("gamepad", false, "in versus games, player two uses the first game controller instead of the arrow keys")
+	serverAddr   = flag.String("server", "", "address of a tetris-server to play online against someone else, like localhost:7777")
+	playerName   = flag.String("name", "PLAYER", "name to show to your opponent when playing online")
+	spectateAddr = flag.String("spectate", "", "address to serve this game to spectators on, like localhost:7780. They watch at ws://localhost:7780/watch.")
+	watchAddr    = flag.String("watch", "", "WebSocket URL of games to watch instead of playing, from -spectate or a tetris-server, like ws://localhost:7780/watch")
+	replayPath   = flag.String("replay", "", "replay file to play back instead of playing, from -record")
+	resume       = flag.Bool("resume", true, "offer to carry on the single player game that was quit partway through last time, if it's the same mode on the same size of board")
+	watchBot     = flag.Bool("bot", false, "let the built-in bot play the single player game, to watch it play")
+	botDelay     = flag.Int("bot_delay", 6, "with -bot, ticks that the bot waits between key presses. Higher is slower.")
+	tbpBot       = flag.String("tbp_bot", "", "command that starts a bot speaking the Tetris Bot Protocol, like \"cold-clear\", to play the single player game in place of the built-in bot")
+	recordDir    = flag.String("record", "", "directory to save a replay of each game into once it's over. Leave empty to not record games.")
+)
+
 const (
-	gametick     = time.Second / 3
-	framerate    = 60
+	framerate    = gamestate.TicksPerSecond
 	vsync        = true
 	windowWidth  = 500
 	windowHeight = 1000
@@ -28,11 +66,17 @@ func init() {
 }
 
 func main() {
+	flag.Parse()
 	if err := sdl.Init(sdl.INIT_EVERYTHING); err != nil {
 		log.Fatalln("Error with SDL Init:", err)
 	}
 
-	window, err := sdl.CreateWindow("Tetris", sdl.WINDOWPOS_UNDEFINED, sdl.WINDOWPOS_UNDEFINED, windowWidth, windowHeight, sdl.WINDOW_OPENGL)
+	width := windowWidth
+	if *versusGame || *serverAddr != "" || *watchAddr != "" {
+		// Room for two boards side by side.
+		width *= 2
+	}
+	window, err := sdl.CreateWindow("Tetris", sdl.WINDOWPOS_UNDEFINED, sdl.WINDOWPOS_UNDEFINED, width, windowHeight, sdl.WINDOW_OPENGL)
 	if err != nil {
 		log.Fatalln(err)
 	}
@@ -60,43 +104,320 @@ func main() {
 	}
 	defer renderer.Destroy()
 
-	state := gamestate.NewState()
+	config := gamestate.Config{Width: *boardWidth, Height: *boardHeight, BufferHeight: *bufferHeight, Big: *big, Seed: *seed}
+	if err := config.Validate(); err != nil {
+		log.Fatalln(err)
+	}
+	// Exactly one of state, match, client, watcher and player is set,
+	// depending on whether it's a single player, versus or online game, or
+	// other games are being watched, or a replay is being played back.
+	var state *gamestate.State
+	var match *versus.Match
+	var client *netplay.Client
+	var watcher *spectator.Client
+	var player *replay.Player
+	// options are those of the single player game's mode, and resumed is
+	// whether that game was saved last time and is being carried on.
+	options := modes.Options{Messiness: *messiness}
+	resumed := false
+	// paused is whether the single player game was paused on the last frame,
+	// so that practice positions can be logged as they're paused on.
+	paused := false
+	// bot plays the single player game in place of the keyboard, with -bot or
+	// -tbp_bot.
+	var bot *gamestate.Bot
+	if *watchBot || *tbpBot != "" {
+		if *botDelay < 0 {
+			log.Fatalln("-bot_delay can't be negative")
+		}
+		bot = gamestate.NewBot(*botDelay)
+	}
+	if *tbpBot != "" {
+		engine, err := tbp.Launch(strings.Fields(*tbpBot)...)
+		if err != nil {
+			log.Fatalln(err)
+		}
+		defer engine.Close()
+		log.Printf("Playing with %s %s by %s", engine.Info.Name, engine.Info.Version, engine.Info.Author)
+		bot.Engine = engine
+	}
+	switch {
+	case *watchAddr != "":
+		if *mode != "" || *versusGame || *serverAddr != "" || *spectateAddr != "" || *recordDir != "" || *replayPath != "" {
+			log.Fatalln("-mode, -versus, -server, -spectate, -record and -replay can't be used with -watch")
+		}
+		w, err := spectator.Watch(*watchAddr)
+		if err != nil {
+			log.Fatalln(err)
+		}
+		defer w.Close()
+		watcher = w
+	case *replayPath != "":
+		if *mode != "" || *versusGame || *serverAddr != "" || *spectateAddr != "" || *recordDir != "" {
+			log.Fatalln("-mode, -versus, -server, -spectate and -record can't be used with -replay")
+		}
+		r, err := replay.Load(*replayPath)
+		if err != nil {
+			log.Fatalln(err)
+		}
+		p, err := r.NewPlayer()
+		if err != nil {
+			log.Fatalln(err)
+		}
+		player = p
+	case *serverAddr != "":
+		if *mode != "" || *versusGame {
+			log.Fatalln("-mode and -versus can't be used with -server")
+		}
+		c, err := netplay.Connect(*serverAddr, *playerName)
+		if err != nil {
+			log.Fatalln(err)
+		}
+		defer c.Close()
+		client = c
+	case *versusGame:
+		if *mode != "" {
+			log.Fatalln("-mode can't be used with -versus")
+		}
+		match = versus.New(config)
+		log.Println("Seed:", match.Seed())
+		for _, p := range match.Players {
+			setFade(p)
+		}
+	default:
+		state = gamestate.NewState(config)
+		log.Println("Seed:", state.Seed())
+		if *puzzlePath != "" {
+			puzzles, err := modes.LoadPuzzles(*puzzlePath)
+			if err != nil {
+				log.Fatalln(err)
+			}
+			options.Puzzles = puzzles
+		}
+		if *fumenSetup != "" {
+			if *mode != "zen" {
+				log.Fatalln("-fumen only works with -mode=zen")
+			}
+			setup, err := modes.FumenSetup(*fumenSetup)
+			if err != nil {
+				log.Fatalln(err)
+			}
+			options.Setup = setup
+		}
+		gameMode, err := modes.New(*mode, options)
+		if err != nil {
+			log.Fatalln(err)
+		}
+		state.SetMode(gameMode)
+		setFade(state)
+		if *resume {
+			if s, o, ok := savegame.Offer(*mode, options.Setup, config); ok {
+				state, options, resumed = s, o, true
+			}
+		}
+	}
+	var publisher *spectator.Publisher
+	if *spectateAddr != "" {
+		p, err := spectator.Publish(*spectateAddr)
+		if err != nil {
+			log.Fatalln(err)
+		}
+		publisher = p
+	}
+	var recorder *replay.Recorder
+	if *recordDir != "" {
+		recorder = replay.NewRecorder(*recordDir, *mode, options)
+		switch {
+		case client != nil:
+			client.Record()
+		case match != nil:
+			match.Record()
+		case !resumed:
+			// Resumed games can only be recorded if they were from the
+			// start, in which case they still are.
+			state.Record()
+		}
+	}
 	keyboardHandler := keyboard.NewHandler()
+	mouseHandler := mouse.NewHandler()
+	gamepadHandler := gamepad.NewHandler()
+	defer gamepadHandler.Close()
 
 	running := true
 	ticker := time.NewTicker(time.Second / framerate)
-	blockFallTicker := time.NewTicker(gametick)
 	fmt.Println("Framerate Capped at:", time.Duration(time.Second/framerate), " per frame")
-	fmt.Println("Game tick rate:", gametick)
 	for running {
 		for event := sdl.PollEvent(); event != nil; event = sdl.PollEvent() {
-			switch event.(type) {
+			switch e := event.(type) {
 			case *sdl.QuitEvent:
 				log.Println("Got a QuitEvent.")
 				running = false
 				break
+			case *sdl.WindowEvent:
+				// Pause when the window loses focus so the game doesn't carry on unattended.
+				switch {
+				case e.Event != sdl.WINDOWEVENT_FOCUS_LOST || client != nil || watcher != nil || player != nil:
+					// Online games can't be paused, and watched games and
+					// replays aren't ours to pause.
+				case match != nil:
+					match.Pause()
+				default:
+					state.Pause()
+				}
 			}
 		}
 		// Read input
 		keyboardHandler.Update() // Note: This only works because sdl.PollEvent is called above until all events are processed.
+		mouseHandler.Update()
 		//fmt.Println(keyboardHandler.String() + "\n---")
-		state.ApplyInputs(keyboardHandler)
-
-		select {
-		case _, ok := <-blockFallTicker.C: // a new block falls every gametick
-			if ok {
-				state.Step()
-			}
+		w, h := window.GetSize()
+		switch {
+		case watcher != nil:
+			watcher.Update()
+		case player != nil:
+			player.Apply(gamestate.KeyboardInput(keyboardHandler, keyboard.SinglePlayer))
+			player.Tick()
+		case client != nil:
+			client.Update()
+			client.Apply(gamestate.KeyboardInput(keyboardHandler, keyboard.SinglePlayer))
+			client.Tick()
+		case match != nil:
+			gamepadHandler.Update()
+			match.Apply(versusInputs(keyboardHandler, gamepadHandler))
+			match.Tick()
 		default:
+			if bot != nil {
+				state.Apply(bot.Watched(state, gamestate.KeyboardInput(keyboardHandler, keyboard.SinglePlayer)))
+			} else {
+				state.ApplyInputs(keyboardHandler)
+			}
+			state.ApplyMouse(mouseHandler, 0, 0, w, h)
+			state.Tick()
+			if resumed && !state.Paused() {
+				// The saved game has been carried on, so stop offering it.
+				state.SetCaption()
+				resumed = false
+			}
+			if state.Paused() && !paused && state.Practice() {
+				logPosition(state)
+			}
+			paused = state.Paused()
+		}
+		names, games := playing(state, match, client)
+		for i, game := range games {
+			if publisher != nil {
+				publisher.Show(i+1, names[i], game)
+			}
+			if recorder != nil {
+				recorder.Update(names[i], game)
+			}
 		}
 
 		renderer.SetDrawColor(0, 0, 0, 255)
 		renderer.Clear() // Clear to the DrawColor (black)
-		w, h := window.GetSize()
-		state.Draw(renderer, 0, 0, w, h)
+		switch {
+		case watcher != nil:
+			drawGrid(renderer, watcher.Games(), w, h)
+		case player != nil:
+			player.State().Draw(renderer, 0, 0, w, h)
+		case client != nil:
+			drawSideBySide(renderer, client.Players(), w, h)
+		case match != nil:
+			drawSideBySide(renderer, match.Players, w, h)
+		default:
+			state.Draw(renderer, 0, 0, w, h)
+		}
 		renderer.Present() // NOTE: DO NOT USE sdl.GL_SwapWindow(window). It's done inside of the renderer so it will make the screen flicker badly.
 
 		<-ticker.C // wait based on framerate
 
 	}
+	if state != nil {
+		saveGame(state, options)
+	}
+}
+
+// drawSideBySide splits the window in two, with the first game on the left.
+func drawSideBySide(renderer *sdl.Renderer, games [2]*gamestate.State, w, h int) {
+	drawGrid(renderer, games[:], w, h)
+}
+
+// drawGrid splits the window into a grid with a cell for each game, filling
+// rows from the top left. Boards are taller than they are wide, so there are
+// about twice as many columns as rows.
+func drawGrid(renderer *sdl.Renderer, games []*gamestate.State, w, h int) {
+	cols := int(math.Ceil(math.Sqrt(float64(2 * len(games)))))
+	if cols > len(games) {
+		cols = len(games)
+	}
+	rows := (len(games) + cols - 1) / cols
+	for i, game := range games {
+		row, col := i/cols, i%cols
+		game.Draw(renderer, col*w/cols, row*h/rows, w/cols, h/rows)
+	}
+}
+
+// playing returns the games being played, or shown, in the window, with the
+// names of their players.
+func playing(state *gamestate.State, match *versus.Match, client *netplay.Client) ([]string, []*gamestate.State) {
+	switch {
+	case client != nil:
+		return []string{*playerName, "OPPONENT"}, []*gamestate.State{client.Local, client.Remote}
+	case match != nil:
+		return []string{"PLAYER 1", "PLAYER 2"}, match.Players[:]
+	case state != nil:
+		return []string{*playerName}, []*gamestate.State{state}
+	}
+	return nil, nil
+}
+
+// logPosition logs the board and falling piece of a game as a fumen, so that
+// positions can be shared and set up again with -fumen.
+func logPosition(state *gamestate.State) {
+	page, err := fumen.FromState(state)
+	if err != nil {
+		log.Println("Error writing position as fumen:", err)
+		return
+	}
+	text, err := fumen.Encode([]*fumen.Page{page})
+	if err != nil {
+		log.Println("Error writing position as fumen:", err)
+		return
+	}
+	log.Println("Position:", text)
+}
+
+// saveGame keeps a single player game to carry on next time, unless it's
+// over.
+func saveGame(state *gamestate.State, options modes.Options) {
+	if err := savegame.Save(*mode, options, state); err != nil {
+		log.Println("Error saving game:", err)
+	}
+}
+
+// setFade makes blocks disappear after they lock, as set by the -invisible
+// and -fade_seconds flags.
+func setFade(state *gamestate.State) {
+	switch {
+	case *invisible:
+		state.SetFade(&gamestate.Fade{})
+	case *fadeSeconds > 0:
+		state.SetFade(&gamestate.Fade{After: int(*fadeSeconds * gamestate.TicksPerSecond), Over: gamestate.TicksPerSecond})
+	}
+}
+
+// versusInputs returns what each player of a versus game pressed. They split
+// the keyboard between them, unless player two is using a game controller, in
+// which case player one gets the usual single player keys.
+func versusInputs(keyboardHandler *keyboard.Handler, gamepadHandler *gamepad.Handler) [2]gamestate.Input {
+	if *useGamepad {
+		return [2]gamestate.Input{
+			gamestate.KeyboardInput(keyboardHandler, keyboard.SinglePlayer),
+			gamestate.GamepadInput(gamepadHandler),
+		}
+	}
+	return [2]gamestate.Input{
+		gamestate.KeyboardInput(keyboardHandler, keyboard.Player1),
+		gamestate.KeyboardInput(keyboardHandler, keyboard.Player2),
+	}
 }
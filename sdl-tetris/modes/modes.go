@@ -0,0 +1,68 @@
+// Package modes holds the different ways to play a game: each sets goals on
+// top of a gamestate.State and decides when the game is over.
+package modes
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+
+	"github.com/omustardo/tetris/sdl-tetris/gamestate"
+)
+
+// Options are settings that only some modes use.
+type Options struct {
+	// Messiness of the garbage in dig modes. See NewDig.
+	Messiness float64
+	// Puzzles to play in the puzzle mode, instead of the ones that come with
+	// the game.
+	Puzzles []*Puzzle
+	// Setup is a board and pieces for zen mode to start from, like one read
+	// by FumenSetup.
+	Setup *Puzzle
+}
+
+func DefaultOptions() Options {
+	return Options{Messiness: DefaultMessiness}
+}
+
+// constructors maps the name of each mode to a function creating it.
+var constructors = map[string]func(Options) gamestate.Mode{
+	"sprint20":    func(Options) gamestate.Mode { return NewSprint(20) },
+	"sprint40":    func(Options) gamestate.Mode { return NewSprint(40) },
+	"sprint100":   func(Options) gamestate.Mode { return NewSprint(100) },
+	"ultra2":      func(Options) gamestate.Mode { return NewUltra(2) },
+	"ultra3":      func(Options) gamestate.Mode { return NewUltra(3) },
+	"marathon150": func(Options) gamestate.Mode { return NewMarathon(150) },
+	"marathon200": func(Options) gamestate.Mode { return NewMarathon(200) },
+	"dig10":       func(o Options) gamestate.Mode { return NewDig(10, o.Messiness) },
+	"dig18":       func(o Options) gamestate.Mode { return NewDig(18, o.Messiness) },
+	"dig100":      func(o Options) gamestate.Mode { return NewDig(100, o.Messiness) },
+	"master":      func(Options) gamestate.Mode { return NewMaster(false) },
+	"20g":         func(Options) gamestate.Mode { return NewMaster(true) },
+	"puzzle":      func(o Options) gamestate.Mode { return NewPuzzles(o.Puzzles) },
+	"zen":         func(o Options) gamestate.Mode { return NewZen(o.Setup) },
+}
+
+// New creates the mode with the given name. An empty name means an endless
+// game, which is represented by a nil mode.
+func New(name string, options Options) (gamestate.Mode, error) {
+	if name == "" {
+		return nil, nil
+	}
+	constructor, ok := constructors[name]
+	if !ok {
+		return nil, fmt.Errorf("unknown mode %q, expected one of: %s", name, strings.Join(Names(), ", "))
+	}
+	return constructor(options), nil
+}
+
+// Names returns the names of all modes, in alphabetical order.
+func Names() []string {
+	var names []string
+	for name := range constructors {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
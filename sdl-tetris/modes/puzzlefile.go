@@ -0,0 +1,236 @@
+package modes
+
+import (
+	"bufio"
+	"bytes"
+	"embed"
+	"fmt"
+	"os"
+	"path"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/omustardo/tetris/sdl-tetris/tetronimoes"
+)
+
+// Puzzle is a starting board, a fixed set of pieces and a goal to reach using
+// them. The file format is described in puzzles/README.md.
+type Puzzle struct {
+	Name   string
+	Goal   Goal
+	Pieces []tetronimoes.Kind
+	// Board holds the starting rows of the board, top first, using the same
+	// characters as puzzle files. The rows are placed at the bottom of the
+	// board.
+	Board []string
+}
+
+// GoalType is the kind of thing that a puzzle asks for.
+type GoalType int
+
+const (
+	// Clear at least N lines.
+	GoalLines GoalType = iota
+	// Clear lines so that the board is left empty.
+	GoalPerfectClear
+	// Clear exactly N lines with a T-spin.
+	GoalTSpin
+)
+
+// Goal is what needs to be done to solve a puzzle.
+type Goal struct {
+	Type GoalType
+	N    int
+}
+
+var tSpinNames = []string{"", "single", "double", "triple"}
+
+func (g Goal) String() string {
+	switch g.Type {
+	case GoalLines:
+		return fmt.Sprintf("clear %d lines", g.N)
+	case GoalPerfectClear:
+		return "perfect clear"
+	case GoalTSpin:
+		return "T-spin " + tSpinNames[g.N]
+	}
+	return "unknown goal"
+}
+
+func parseGoal(text string) (Goal, error) {
+	fields := strings.Fields(strings.ToLower(text))
+	switch {
+	case len(fields) == 2 && fields[0] == "lines":
+		n, err := strconv.Atoi(fields[1])
+		if err != nil || n < 1 {
+			return Goal{}, fmt.Errorf("bad number of lines %q", fields[1])
+		}
+		return Goal{Type: GoalLines, N: n}, nil
+	case len(fields) == 2 && fields[0] == "perfect" && fields[1] == "clear":
+		return Goal{Type: GoalPerfectClear}, nil
+	case len(fields) == 2 && fields[0] == "tspin":
+		for n, name := range tSpinNames {
+			if n > 0 && fields[1] == name {
+				return Goal{Type: GoalTSpin, N: n}, nil
+			}
+		}
+		return Goal{}, fmt.Errorf("bad T-spin %q, expected single, double or triple", fields[1])
+	}
+	return Goal{}, fmt.Errorf("unknown goal %q", text)
+}
+
+// boardKind returns the kind of shape that a character in a puzzle board
+// stands for, or zero for a plain block, and whether it's filled at all.
+func boardKind(c rune) (kind tetronimoes.Kind, filled bool, err error) {
+	switch c {
+	case '.':
+		return 0, false, nil
+	case 'X', 'G':
+		return 0, true, nil
+	}
+	if tetronimoes.NewShape(tetronimoes.Kind(c)) == nil {
+		return 0, false, fmt.Errorf("unknown board cell %q", c)
+	}
+	return tetronimoes.Kind(c), true, nil
+}
+
+// ParsePuzzle reads a puzzle in the format described in puzzles/README.md.
+// The name is used if the puzzle doesn't give itself one.
+func ParsePuzzle(name string, data []byte) (*Puzzle, error) {
+	p := &Puzzle{Name: name}
+	hasGoal := false
+	inBoard := false
+	scanner := bufio.NewScanner(bytes.NewReader(data))
+	for lineNum := 1; scanner.Scan(); lineNum++ {
+		line := strings.TrimSpace(scanner.Text())
+		if inBoard {
+			if line == "" {
+				continue
+			}
+			for _, c := range line {
+				if _, _, err := boardKind(c); err != nil {
+					return nil, fmt.Errorf("%s:%d: %v", name, lineNum, err)
+				}
+			}
+			if len(p.Board) > 0 && len(line) != len(p.Board[0]) {
+				return nil, fmt.Errorf("%s:%d: board rows must all be the same width", name, lineNum)
+			}
+			p.Board = append(p.Board, line)
+			continue
+		}
+		if line == "" || strings.HasPrefix(line, "#") {
+			continue
+		}
+		colon := strings.Index(line, ":")
+		if colon < 0 {
+			return nil, fmt.Errorf("%s:%d: expected key: value", name, lineNum)
+		}
+		key, value := strings.ToLower(strings.TrimSpace(line[:colon])), strings.TrimSpace(line[colon+1:])
+		switch key {
+		case "name":
+			p.Name = value
+		case "goal":
+			goal, err := parseGoal(value)
+			if err != nil {
+				return nil, fmt.Errorf("%s:%d: %v", name, lineNum, err)
+			}
+			p.Goal, hasGoal = goal, true
+		case "pieces":
+			for _, c := range strings.ToUpper(value) {
+				if c == ' ' {
+					continue
+				}
+				if tetronimoes.NewShape(tetronimoes.Kind(c)) == nil {
+					return nil, fmt.Errorf("%s:%d: unknown piece %q", name, lineNum, c)
+				}
+				p.Pieces = append(p.Pieces, tetronimoes.Kind(c))
+			}
+		case "board":
+			inBoard = true
+		default:
+			return nil, fmt.Errorf("%s:%d: unknown key %q", name, lineNum, key)
+		}
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, err
+	}
+	if !hasGoal {
+		return nil, fmt.Errorf("%s: missing goal", name)
+	}
+	if len(p.Pieces) == 0 {
+		return nil, fmt.Errorf("%s: missing pieces", name)
+	}
+	return p, nil
+}
+
+// puzzleExt is the extension of puzzle files.
+const puzzleExt = ".txt"
+
+// LoadPuzzles reads a single puzzle file, or a directory holding a pack of
+// them. Puzzles in a pack are played in the order of their file names.
+func LoadPuzzles(p string) ([]*Puzzle, error) {
+	info, err := os.Stat(p)
+	if err != nil {
+		return nil, err
+	}
+	if !info.IsDir() {
+		data, err := os.ReadFile(p)
+		if err != nil {
+			return nil, err
+		}
+		puzzle, err := ParsePuzzle(strings.TrimSuffix(filepath.Base(p), puzzleExt), data)
+		if err != nil {
+			return nil, err
+		}
+		return []*Puzzle{puzzle}, nil
+	}
+	matches, err := filepath.Glob(filepath.Join(p, "*"+puzzleExt))
+	if err != nil {
+		return nil, err
+	}
+	return loadPack(matches, os.ReadFile)
+}
+
+//go:embed puzzles/*.txt
+var builtinFiles embed.FS
+
+// builtinPuzzles returns the pack of puzzles that comes with the game.
+func builtinPuzzles() []*Puzzle {
+	matches, err := builtinFiles.ReadDir("puzzles")
+	if err != nil {
+		panic(err)
+	}
+	var files []string
+	for _, m := range matches {
+		if strings.HasSuffix(m.Name(), puzzleExt) {
+			files = append(files, path.Join("puzzles", m.Name()))
+		}
+	}
+	puzzles, err := loadPack(files, builtinFiles.ReadFile)
+	if err != nil {
+		panic(err)
+	}
+	return puzzles
+}
+
+func loadPack(files []string, readFile func(string) ([]byte, error)) ([]*Puzzle, error) {
+	if len(files) == 0 {
+		return nil, fmt.Errorf("no %s puzzle files found", puzzleExt)
+	}
+	sort.Strings(files)
+	var puzzles []*Puzzle
+	for _, f := range files {
+		data, err := readFile(f)
+		if err != nil {
+			return nil, err
+		}
+		puzzle, err := ParsePuzzle(strings.TrimSuffix(path.Base(filepath.ToSlash(f)), puzzleExt), data)
+		if err != nil {
+			return nil, err
+		}
+		puzzles = append(puzzles, puzzle)
+	}
+	return puzzles, nil
+}
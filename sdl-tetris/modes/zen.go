@@ -0,0 +1,65 @@
+package modes
+
+import (
+	"fmt"
+	"log"
+
+	"github.com/omustardo/tetris/sdl-tetris/gamestate"
+)
+
+// Zen is for practice: pieces don't fall on their own, and the game never
+// ends unless the stack tops out. Placements can be undone, the upcoming
+// pieces reordered and blocks painted onto the board with the mouse.
+type Zen struct {
+	setup *Puzzle
+}
+
+// NewZen creates a zen mode that starts from setup's board and pieces, with
+// random pieces after them, or from an empty board if setup is nil. The
+// setup's goal is ignored.
+func NewZen(setup *Puzzle) *Zen {
+	return &Zen{setup: setup}
+}
+
+// Setup returns the setup that the mode starts from, or nil if it starts from
+// an empty board.
+func (m *Zen) Setup() *Puzzle {
+	return m.setup
+}
+
+func (m *Zen) Start(s *gamestate.State) {
+	s.SetGravity(gamestate.Gravity{Rows: 0, Ticks: 1})
+	s.SetPractice(true)
+	if m.setup == nil {
+		return
+	}
+	if err := setBoard(s, m.setup.Board); err != nil {
+		// There's still practice to be had on an empty board.
+		log.Printf("Setup %q: %v", m.setup.Name, err)
+		return
+	}
+	s.SetQueue(m.setup.Pieces, false)
+}
+
+func (m *Zen) Tick(s *gamestate.State) {}
+
+func (m *Zen) HandleEvent(s *gamestate.State, e gamestate.Event) {}
+
+func (m *Zen) HUD(s *gamestate.State) []string {
+	stats := s.Stats()
+	return []string{
+		"ZEN",
+		fmt.Sprintf("%d PIECES %d LINES", stats.Pieces, stats.Lines),
+		nextPieces(s),
+	}
+}
+
+func (m *Zen) Results(s *gamestate.State) []string {
+	stats := s.Stats()
+	return []string{
+		"ZEN",
+		fmt.Sprintf("%d PIECES", stats.Pieces),
+		fmt.Sprintf("%d LINES", stats.Lines),
+		"Z TO UNDO",
+	}
+}
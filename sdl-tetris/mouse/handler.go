@@ -0,0 +1,36 @@
+package mouse
+
+import "github.com/veandco/go-sdl2/sdl"
+
+type Handler struct {
+	// Button states from sdl.GetMouseState, as a bitmask of sdl.BUTTON_*MASK.
+	state         uint32
+	previousState uint32
+	// Position of the cursor, with (0,0) at the top left of the window.
+	X, Y int
+}
+
+func NewHandler() *Handler {
+	return &Handler{}
+}
+
+// Update is expected to be called roughly once per frame, after events have
+// been processed by calling sdl.PollEvent, like the keyboard handler.
+func (h *Handler) Update() {
+	h.previousState = h.state
+	h.X, h.Y, h.state = sdl.GetMouseState()
+}
+
+func (h *Handler) LeftPressed() bool {
+	return h.state&sdl.BUTTON_LMASK != 0
+}
+func (h *Handler) RightPressed() bool {
+	return h.state&sdl.BUTTON_RMASK != 0
+}
+
+func (h *Handler) WasLeftPressed() bool {
+	return h.previousState&sdl.BUTTON_LMASK != 0
+}
+func (h *Handler) WasRightPressed() bool {
+	return h.previousState&sdl.BUTTON_RMASK != 0
+}
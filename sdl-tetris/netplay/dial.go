@@ -0,0 +1,16 @@
+package netplay
+
+import (
+	"net"
+
+	"github.com/omustardo/tetris/tetris-server/protocol"
+)
+
+// dial connects to a server over TCP, at an address like "localhost:7777".
+func dial(addr string) (conn, error) {
+	c, err := net.Dial("tcp", addr)
+	if err != nil {
+		return nil, err
+	}
+	return protocol.NewStream(c), nil
+}
@@ -0,0 +1,48 @@
+package replay
+
+import (
+	"errors"
+
+	"github.com/omustardo/tetris/sdl-tetris/fumen"
+)
+
+// Fumen plays the replay from frame from up to frame to, or to the end if to
+// is negative, and returns a fumen with a page for each piece placed in
+// between, showing the board before it was placed and where it went. If none
+// were placed it has a single page, of the board at frame from.
+func (r *Replay) Fumen(from, to int) (string, error) {
+	if r.Config.Big {
+		return "", errors.New("big games can't be written as fumen")
+	}
+	playback, err := r.Play()
+	if err != nil {
+		return "", err
+	}
+	for !playback.Done() && playback.Frame() < from {
+		playback.Step()
+	}
+	s := playback.State()
+	var pages []*fumen.Page
+	for !playback.Done() && (to < 0 || playback.Frame() < to) {
+		rows, pieces := s.Rows(), s.Stats().Pieces
+		playback.Step()
+		if s.Stats().Pieces != pieces+1 {
+			// No piece was placed, or the game restarted.
+			continue
+		}
+		kind, cells := s.PlacedCells()
+		page, err := fumen.NewPage(rows, kind, cells)
+		if err != nil {
+			return "", err
+		}
+		pages = append(pages, page)
+	}
+	if len(pages) == 0 {
+		page, err := fumen.FromState(s)
+		if err != nil {
+			return "", err
+		}
+		pages = append(pages, page)
+	}
+	return fumen.Encode(pages)
+}
@@ -0,0 +1,128 @@
+// Package savegame keeps a single player game that was quit partway through,
+// so that it can be resumed the next time the game is launched. There's only
+// ever one saved game, kept with the personal bests.
+package savegame
+
+import (
+	"encoding/json"
+	"fmt"
+	"log"
+	"reflect"
+	"time"
+
+	"github.com/omustardo/tetris/sdl-tetris/gamestate"
+	"github.com/omustardo/tetris/sdl-tetris/modes"
+	"github.com/omustardo/tetris/sdl-tetris/storage"
+)
+
+// fileName is the name the game is saved under.
+const fileName = "savegame.json"
+
+// Version of the format of the file around the game's state. Games saved in
+// any other version can't be resumed. The state has its own version,
+// gamestate.StateVersion, and is migrated from older ones as it's loaded.
+const Version = 1
+
+// Game is a saved game.
+type Game struct {
+	Version int
+	// Time is when the game was saved.
+	Time time.Time
+	// Mode is the name of the game's mode, as given to modes.New, and
+	// Messiness, Puzzles and Setup are the options it was created with.
+	Mode      string
+	Messiness float64
+	Puzzles   []*modes.Puzzle `json:",omitempty"`
+	Setup     *modes.Puzzle   `json:",omitempty"`
+	State     *gamestate.Saved
+}
+
+// Save keeps a game to be resumed later, replacing any game saved before.
+// Games that are over aren't worth resuming, so any saved game is deleted
+// instead.
+func Save(mode string, options modes.Options, s *gamestate.State) error {
+	if s.GameOver() {
+		return storage.Delete(fileName)
+	}
+	data, err := json.Marshal(&Game{
+		Version:   Version,
+		Time:      time.Now(),
+		Mode:      mode,
+		Messiness: options.Messiness,
+		Puzzles:   options.Puzzles,
+		Setup:     options.Setup,
+		State:     s.Save(),
+	})
+	if err != nil {
+		return err
+	}
+	return storage.Save(fileName, data)
+}
+
+// Load returns the saved game, or nil if there isn't one.
+func Load() (*Game, error) {
+	data, err := storage.Load(fileName)
+	if err != nil || data == nil {
+		return nil, err
+	}
+	g := &Game{}
+	if err := json.Unmarshal(data, g); err != nil {
+		return nil, fmt.Errorf("bad saved game: %v", err)
+	}
+	if g.Version != Version {
+		return nil, fmt.Errorf("saved game has version %d, expected %d", g.Version, Version)
+	}
+	if g.State == nil {
+		return nil, fmt.Errorf("saved game is missing its state")
+	}
+	return g, nil
+}
+
+// Matches returns whether the game was saved from one with the given mode,
+// setup and board, and so is the kind of game that's being launched. The seed
+// isn't compared since it's usually random.
+func (g *Game) Matches(mode string, setup *modes.Puzzle, config gamestate.Config) bool {
+	saved := g.State.Config
+	return g.Mode == mode && reflect.DeepEqual(g.Setup, setup) && saved.Width == config.Width &&
+		saved.Height == config.Height && saved.BufferHeight == config.BufferHeight && saved.Big == config.Big
+}
+
+// Resume carries the saved game on from where it was.
+func (g *Game) Resume() (*gamestate.State, error) {
+	mode, err := modes.New(g.Mode, g.Options())
+	if err != nil {
+		return nil, err
+	}
+	return gamestate.Restore(g.State, mode)
+}
+
+// Options returns the options that the game's mode was created with.
+func (g *Game) Options() modes.Options {
+	return modes.Options{Messiness: g.Messiness, Puzzles: g.Puzzles, Setup: g.Setup}
+}
+
+// Offer resumes the saved game if there is one and it matches the game being
+// launched, with the given mode, setup and board. It comes back paused, captioned
+// with how to carry it on or start a new game instead, and with the options
+// its mode was created with. ok is false if there's no game to resume. Errors
+// are logged rather than returned, since a broken saved game shouldn't stop
+// anyone playing.
+func Offer(mode string, setup *modes.Puzzle, config gamestate.Config) (s *gamestate.State, options modes.Options, ok bool) {
+	g, err := Load()
+	if err != nil {
+		log.Println("Error loading saved game:", err)
+		return nil, options, false
+	}
+	if g == nil || !g.Matches(mode, setup, config) {
+		return nil, options, false
+	}
+	s, err = g.Resume()
+	if err != nil {
+		log.Println("Error resuming saved game:", err)
+		return nil, options, false
+	}
+	log.Println("Resuming the game saved at", g.Time.Format(time.Stamp))
+	s.Pause()
+	s.SetCaption("SAVED GAME", "P TO CARRY ON", "R TO START OVER")
+	return s, g.Options(), true
+}
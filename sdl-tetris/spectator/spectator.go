@@ -0,0 +1,226 @@
+// Package spectator shows games to spectators, and watches games shown by
+// others: read only, drawn just like the games being played. The feed of games
+// is described in tetris-server/protocol/spectate.go.
+package spectator
+
+import (
+	"log"
+	"sort"
+	"strings"
+
+	"github.com/omustardo/tetris/sdl-tetris/gamestate"
+	"github.com/omustardo/tetris/tetris-server/protocol"
+)
+
+// Number of updates that can arrive before the game reads them.
+const receiveBuffer = 1024
+
+// conn is a connection to a feed, over any transport.
+type conn interface {
+	// Receive blocks until an update arrives, or the connection is closed.
+	Receive() (protocol.Update, error)
+	Close() error
+}
+
+// Client watches a feed of games.
+type Client struct {
+	conn     conn
+	received chan protocol.Update
+	lost     chan error // Gets an error once the connection is lost.
+
+	games map[int]*game
+	// status says why there are no games, if there aren't any, and idle
+	// shows it.
+	status string
+	idle   *gamestate.State
+}
+
+// game is one of the games in a feed.
+type game struct {
+	frame protocol.Frame
+	// state shows the frame. It's only ever drawn.
+	state *gamestate.State
+}
+
+// Watch starts connecting to a feed. The address's format depends on how the
+// game connects: see dial.
+func Watch(addr string) (*Client, error) {
+	c, err := dial(addr)
+	if err != nil {
+		return nil, err
+	}
+	client := &Client{
+		conn:     c,
+		received: make(chan protocol.Update, receiveBuffer),
+		lost:     make(chan error, 1),
+		games:    make(map[int]*game),
+		status:   "WAITING FOR GAMES",
+	}
+	go client.receive()
+	return client, nil
+}
+
+// receive passes on updates from the feed until the connection is lost.
+func (c *Client) receive() {
+	for {
+		u, err := c.conn.Receive()
+		if err != nil {
+			c.lost <- err
+			return
+		}
+		c.received <- u
+	}
+}
+
+// Update applies any updates that have arrived. It's expected to be called
+// once per frame.
+func (c *Client) Update() {
+	for {
+		select {
+		case u := <-c.received:
+			c.apply(u)
+		case err := <-c.lost:
+			log.Println("Stopped watching:", err)
+			c.games = make(map[int]*game)
+			c.status, c.idle = "DISCONNECTED", nil
+		default:
+			return
+		}
+	}
+}
+
+func (c *Client) apply(u protocol.Update) {
+	if u.Gone {
+		delete(c.games, u.Game)
+		return
+	}
+	g := c.games[u.Game]
+	if g == nil {
+		if u.Full == nil {
+			log.Printf("Ignoring update to unknown game %d", u.Game)
+			return
+		}
+		g = &game{}
+		c.games[u.Game] = g
+	}
+	g.frame.Apply(u)
+	switch {
+	case g.state == nil || u.Full != nil || u.Over != nil || u.Paused != nil:
+		// Games can't be taken out of being over or paused, so start
+		// afresh.
+		g.state = g.newState()
+	case len(u.Rows) > 0:
+		g.state.SetRows(g.frame.Rows)
+	}
+}
+
+// newState returns a game showing the latest frame.
+func (g *game) newState() *gamestate.State {
+	config := gamestate.DefaultConfig()
+	config.Width, config.Height = g.frame.Width, g.frame.Height
+	if err := config.Validate(); err != nil {
+		log.Printf("Can't show %q: %v", g.frame.Name, err)
+		config = gamestate.DefaultConfig()
+	}
+	s := gamestate.NewState(config)
+	s.SetMode(&view{g})
+	s.SetRows(g.frame.Rows)
+	if g.frame.Paused {
+		s.Pause()
+	}
+	if g.frame.Over {
+		s.End()
+	}
+	return s
+}
+
+// Games returns the games being watched, in the order they were added to the
+// feed. If there aren't any, it returns a single empty board saying why.
+func (c *Client) Games() []*gamestate.State {
+	var ids []int
+	for id := range c.games {
+		ids = append(ids, id)
+	}
+	sort.Ints(ids)
+	var states []*gamestate.State
+	for _, id := range ids {
+		states = append(states, c.games[id].state)
+	}
+	if len(states) == 0 {
+		if c.idle == nil {
+			g := &game{frame: protocol.Frame{
+				Width:   gamestate.DefaultWidth,
+				Height:  gamestate.DefaultHeight,
+				Results: []string{c.status},
+				Over:    true,
+			}}
+			c.idle = g.newState()
+		}
+		states = append(states, c.idle)
+	}
+	return states
+}
+
+// Close stops watching.
+func (c *Client) Close() {
+	c.conn.Close()
+}
+
+// view is the mode of a game being watched, which shows whatever its latest
+// frame does.
+type view struct {
+	g *game
+}
+
+func (m *view) Start(s *gamestate.State) {}
+
+func (m *view) Tick(s *gamestate.State) {}
+
+func (m *view) HandleEvent(s *gamestate.State, e gamestate.Event) {}
+
+func (m *view) HUD(s *gamestate.State) []string {
+	f := m.g.frame
+	if f.Queue == "" {
+		return f.HUD
+	}
+	for _, line := range f.HUD {
+		if strings.HasPrefix(line, "NEXT ") {
+			// The game already shows what's next.
+			return f.HUD
+		}
+	}
+	return append(append([]string(nil), f.HUD...), "NEXT "+strings.Join(strings.Split(f.Queue, ""), " "))
+}
+
+func (m *view) Results(s *gamestate.State) []string {
+	return m.g.frame.Results
+}
+
+// Capture returns what spectators see of a game, under the given name.
+func Capture(name string, s *gamestate.State) protocol.Frame {
+	var queue []byte
+	for _, kind := range s.Queue() {
+		queue = append(queue, byte(kind))
+	}
+	stats := s.Stats()
+	f := protocol.Frame{
+		Name:   name,
+		Width:  s.Width(),
+		Height: s.Height(),
+		Rows:   s.RowsWithPiece(),
+		Queue:  string(queue),
+		HUD:    s.HUD(),
+		Over:   s.GameOver(),
+		Paused: s.Paused(),
+		Stats: protocol.Stats{
+			Score:  s.Score(),
+			Level:  s.Level(),
+			Pieces: stats.Pieces,
+			Lines:  stats.Lines,
+		},
+	}
+	if f.Over {
+		f.Results = s.Results()
+	}
+	return f
+}
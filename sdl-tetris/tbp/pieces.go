@@ -0,0 +1,226 @@
+package tbp
+
+import (
+	"encoding/json"
+	"fmt"
+	"strings"
+
+	"github.com/omustardo/tetris/sdl-tetris/gamestate"
+	"github.com/omustardo/tetris/sdl-tetris/tetronimoes"
+)
+
+// pieceNames are the names of the kinds of piece in the protocol. The pieces
+// that this game calls S and Z are shaped like the ones that everyone else
+// calls Z and S, so their names are swapped, to keep the shapes the same.
+var pieceNames = map[tetronimoes.Kind]string{
+	tetronimoes.I: "I",
+	tetronimoes.O: "O",
+	tetronimoes.T: "T",
+	tetronimoes.L: "L",
+	tetronimoes.J: "J",
+	tetronimoes.S: "Z",
+	tetronimoes.Z: "S",
+}
+
+// PieceName returns the name of a kind of piece in the protocol.
+func PieceName(kind tetronimoes.Kind) string {
+	return pieceNames[kind]
+}
+
+// PieceKind returns the kind of piece with the given name in the protocol.
+func PieceKind(name string) (tetronimoes.Kind, error) {
+	for kind, n := range pieceNames {
+		if n == name {
+			return kind, nil
+		}
+	}
+	return 0, fmt.Errorf("unknown piece %q", name)
+}
+
+// northCells are the cells that each piece covers when it's turned North,
+// relative to its center.
+var northCells = map[string][][2]int{
+	"I": {{-1, 0}, {0, 0}, {1, 0}, {2, 0}},
+	"O": {{0, 0}, {1, 0}, {0, 1}, {1, 1}},
+	"T": {{-1, 0}, {0, 0}, {1, 0}, {0, 1}},
+	"L": {{-1, 0}, {0, 0}, {1, 0}, {1, 1}},
+	"J": {{-1, 1}, {-1, 0}, {0, 0}, {1, 0}},
+	"S": {{-1, 0}, {0, 0}, {0, 1}, {1, 1}},
+	"Z": {{-1, 1}, {0, 1}, {0, 0}, {1, 0}},
+}
+
+// offsets returns the cells that a piece covers when it's turned the given
+// number of times clockwise from North, relative to its center.
+func offsets(name string, turns int) [][2]int {
+	cells := append([][2]int(nil), northCells[name]...)
+	for ; turns > 0; turns-- {
+		for i, c := range cells {
+			cells[i] = [2]int{c[1], -c[0]}
+		}
+	}
+	return cells
+}
+
+// Cells returns the cells covered by a piece at l, each as its column and row
+// counted from the bottom left.
+func (l Location) Cells() ([][2]int, error) {
+	if northCells[l.Type] == nil {
+		return nil, fmt.Errorf("unknown piece %q", l.Type)
+	}
+	for turns, o := range Orientations {
+		if o != l.Orientation {
+			continue
+		}
+		cells := offsets(l.Type, turns)
+		for i := range cells {
+			cells[i][0] += l.X
+			cells[i][1] += l.Y
+		}
+		return cells, nil
+	}
+	return nil, fmt.Errorf("unknown orientation %q", l.Orientation)
+}
+
+// Locate returns where a piece of the given kind is if it covers the cells,
+// or false if they aren't its shape.
+func Locate(kind tetronimoes.Kind, cells [][2]int) (Location, bool) {
+	name := PieceName(kind)
+	if northCells[name] == nil || len(cells) == 0 {
+		return Location{}, false
+	}
+	covered := make(map[[2]int]bool)
+	for _, c := range cells {
+		covered[c] = true
+	}
+	for turns, o := range Orientations {
+		shape := offsets(name, turns)
+		if len(shape) != len(covered) {
+			continue
+		}
+		// Try each cell of the shape as the first of the cells.
+		for _, first := range shape {
+			l := Location{Type: name, Orientation: o, X: cells[0][0] - first[0], Y: cells[0][1] - first[1]}
+			matched := true
+			for _, c := range shape {
+				matched = matched && covered[[2]int{c[0] + l.X, c[1] + l.Y}]
+			}
+			if matched {
+				return l, true
+			}
+		}
+	}
+	return Location{}, false
+}
+
+// The size of the boards sent in the protocol, which is also the size of
+// board that bots expect to play on.
+const (
+	FieldWidth  = 10
+	FieldHeight = 40
+)
+
+// Cell is what fills a cell of a board: the name of a piece, "G" for garbage,
+// or nothing if it's empty.
+type Cell string
+
+// MarshalJSON encodes empty cells as null.
+func (c Cell) MarshalJSON() ([]byte, error) {
+	if c == "" {
+		return []byte("null"), nil
+	}
+	return json.Marshal(string(c))
+}
+
+// Field is a board as sent in the protocol: FieldHeight rows of FieldWidth
+// cells, bottom row first.
+type Field [][]Cell
+
+// emptyField returns a field with nothing on it.
+func emptyField() Field {
+	f := make(Field, FieldHeight)
+	for row := range f {
+		f[row] = make([]Cell, FieldWidth)
+	}
+	return f
+}
+
+// FieldOf returns a game's board, without its falling piece, as it's sent in
+// the protocol. Boards of other sizes than bots expect can't be sent.
+func FieldOf(b *gamestate.Board) (Field, error) {
+	f := emptyField()
+	for i, line := range b.Rows {
+		row := len(b.Rows) - 1 - i
+		if len(line) != FieldWidth {
+			return nil, fmt.Errorf("bots only play on boards %d wide, not %d", FieldWidth, len(line))
+		}
+		if row >= FieldHeight {
+			if strings.Trim(line, ".") != "" {
+				return nil, fmt.Errorf("bots only play on boards %d high, but there are blocks in row %d", FieldHeight, row+1)
+			}
+			continue
+		}
+		for col := 0; col < len(line); col++ {
+			switch c := line[col]; {
+			case c == '.':
+			case pieceNames[tetronimoes.Kind(c)] != "":
+				f[row][col] = Cell(pieceNames[tetronimoes.Kind(c)])
+			default:
+				f[row][col] = "G"
+			}
+		}
+	}
+	return f, nil
+}
+
+// Rows returns the rows of f as they're written in a gamestate.Board, top
+// first.
+func (f Field) Rows() []string {
+	var rows []string
+	for row := len(f) - 1; row >= 0; row-- {
+		line := make([]byte, len(f[row]))
+		for col, c := range f[row] {
+			switch kind, err := PieceKind(string(c)); {
+			case c == "":
+				line[col] = '.'
+			case c == "G":
+				line[col] = 'G'
+			case err == nil:
+				line[col] = byte(kind)
+			default:
+				line[col] = 'X'
+			}
+		}
+		rows = append(rows, string(line))
+	}
+	return rows
+}
+
+// Place returns the field left by filling the cells with the named piece and
+// clearing the rows that fills. Fields share the rows that they have in
+// common, so they mustn't be changed once they're made.
+func (f Field) Place(name string, cells [][2]int) Field {
+	placed := append(Field(nil), f...)
+	for _, c := range cells {
+		if c[1] < 0 || c[1] >= len(placed) || c[0] < 0 || c[0] >= len(placed[c[1]]) {
+			continue
+		}
+		if &placed[c[1]][0] == &f[c[1]][0] {
+			placed[c[1]] = append([]Cell(nil), f[c[1]]...)
+		}
+		placed[c[1]][c[0]] = Cell(name)
+	}
+	left := placed[:0]
+	for _, line := range placed {
+		full := true
+		for _, c := range line {
+			full = full && c != ""
+		}
+		if !full {
+			left = append(left, line)
+		}
+	}
+	for len(left) < len(f) {
+		left = append(left, make([]Cell, FieldWidth))
+	}
+	return left
+}
@@ -0,0 +1,170 @@
+// Package tbp plays games with bots in other processes that speak the Tetris
+// Bot Protocol, like Cold Clear, so that they can be watched in the game and
+// bots can be written in any language.
+//
+// Each message is a JSON object, sent one per line over the bot's standard
+// input and output. The bot starts by sending Info about itself, and the
+// frontend, which is this game, replies with Rules. The bot sends Ready if it
+// can play by them, or Error if not.
+//
+// The frontend then sends Start with the board, queue, combo and back to back
+// status of a game. Whenever a new piece comes into view it sends NewPiece.
+// For each piece it sends Suggest, and the bot replies with Suggestion, a list
+// of places to put it, best first. The frontend picks one and sends it back as
+// Play, which the bot plays out on its own copy of the board. Stop ends the
+// game, after which Start can begin another, and Quit asks the bot to exit.
+package tbp
+
+import (
+	"encoding/json"
+	"fmt"
+)
+
+// Type says what a message is for, and which of its fields are used.
+type Type string
+
+const (
+	// Bot to frontend, first: Name, Version, Author and Features of the bot.
+	Info Type = "info"
+	// Frontend to bot, in reply to Info: the rules of the game.
+	Rules Type = "rules"
+	// Bot to frontend, in reply to Rules: the bot can play by them.
+	Ready Type = "ready"
+	// Bot to frontend, in reply to Rules: the bot can't play by them, for the
+	// given Reason.
+	Error Type = "error"
+	// Frontend to bot: a game is starting, or starting over. Hold is the held
+	// piece, if there is one, and Queue the falling piece followed by the
+	// upcoming ones. Combo, BackToBack and Board are as they are now.
+	Start Type = "start"
+	// Frontend to bot: the game is over, or has changed too much to carry on.
+	Stop Type = "stop"
+	// Frontend to bot: pick where the falling piece goes.
+	Suggest Type = "suggest"
+	// Bot to frontend, in reply to Suggest: Moves to pick from, best first.
+	Suggestion Type = "suggestion"
+	// Frontend to bot: the falling piece is placed with Move.
+	Play Type = "play"
+	// Frontend to bot: Piece comes into view at the end of the queue.
+	NewPiece Type = "new_piece"
+	// Frontend to bot: exit.
+	Quit Type = "quit"
+)
+
+// Message is a single message of any type. Fields not used by its type are
+// left empty, and left out when it's sent.
+type Message struct {
+	Type Type `json:"type"`
+
+	Name     string   `json:"name"`
+	Version  string   `json:"version"`
+	Author   string   `json:"author"`
+	Features []string `json:"features"`
+	Reason   string   `json:"reason"`
+
+	Hold       *string  `json:"hold"`
+	Queue      []string `json:"queue"`
+	Combo      int      `json:"combo"`
+	BackToBack bool     `json:"back_to_back"`
+	Board      Field    `json:"board"`
+
+	Moves []Move `json:"moves"`
+	Move  Move   `json:"move"`
+	Piece string `json:"piece"`
+}
+
+// fields lists the fields of each type of message, besides its type. They're
+// always sent, even when empty, since bots written in stricter languages than
+// this one expect them.
+var fields = map[Type][]string{
+	Info:       {"name", "version", "author", "features"},
+	Error:      {"reason"},
+	Start:      {"hold", "queue", "combo", "back_to_back", "board"},
+	Suggestion: {"moves"},
+	Play:       {"move"},
+	NewPiece:   {"piece"},
+}
+
+// MarshalJSON encodes the type of the message and the fields that go with it.
+func (m Message) MarshalJSON() ([]byte, error) {
+	// Lists are sent empty rather than as null.
+	if m.Features == nil {
+		m.Features = []string{}
+	}
+	if m.Queue == nil {
+		m.Queue = []string{}
+	}
+	if m.Moves == nil {
+		m.Moves = []Move{}
+	}
+	type plain Message // Without this method, so that it's encoded as usual.
+	data, err := json.Marshal(plain(m))
+	if err != nil {
+		return nil, err
+	}
+	var all map[string]json.RawMessage
+	if err := json.Unmarshal(data, &all); err != nil {
+		return nil, err
+	}
+	kept := map[string]json.RawMessage{"type": all["type"]}
+	for _, field := range fields[m.Type] {
+		kept[field] = all[field]
+	}
+	return json.Marshal(kept)
+}
+
+// Encode returns the message as JSON, without a trailing newline.
+func Encode(m Message) ([]byte, error) {
+	return json.Marshal(m)
+}
+
+// Decode parses a message encoded by Encode.
+func Decode(data []byte) (Message, error) {
+	var m Message
+	if err := json.Unmarshal(data, &m); err != nil {
+		return Message{}, fmt.Errorf("bad message: %v", err)
+	}
+	if m.Type == "" {
+		return Message{}, fmt.Errorf("message has no type: %s", data)
+	}
+	return m, nil
+}
+
+// Move is where a piece is placed, and how it got there.
+type Move struct {
+	Location Location `json:"location"`
+	Spin     Spin     `json:"spin"`
+}
+
+// Location is where a piece is, by the name of its kind, which way it's
+// turned and the column and row of its center, counted from the bottom left.
+// See Cells.
+type Location struct {
+	Type        string      `json:"type"`
+	Orientation Orientation `json:"orientation"`
+	X           int         `json:"x"`
+	Y           int         `json:"y"`
+}
+
+// Orientation is which way a piece is turned. Pieces start off North, with
+// the flat side of a T down, and turning clockwise takes them East.
+type Orientation string
+
+const (
+	North Orientation = "north"
+	East  Orientation = "east"
+	South Orientation = "south"
+	West  Orientation = "west"
+)
+
+// Orientations lists the orientations in clockwise order, from North.
+var Orientations = []Orientation{North, East, South, West}
+
+// Spin says whether a piece was spun into place.
+type Spin string
+
+const (
+	NoSpin   Spin = "none"
+	MiniSpin Spin = "mini"
+	FullSpin Spin = "full"
+)
@@ -11,10 +11,29 @@ type Point struct {
 	X, Y float32
 }
 
+// Kind identifies which of the seven shapes a Shape is, by the letter that it
+// looks like. S and Z are the exception: S is shaped like the piece that the
+// guideline, and most other games, call Z, and Z like the one they call S.
+type Kind byte
+
+const (
+	I Kind = 'I'
+	J Kind = 'J'
+	L Kind = 'L'
+	O Kind = 'O'
+	S Kind = 'S'
+	T Kind = 'T'
+	Z Kind = 'Z'
+)
+
+// Kinds lists every kind of shape.
+var Kinds = []Kind{L, J, I, S, Z, O, T}
+
 type Shape struct {
 	R, G, B, A uint8
 	points     [][]bool // All points that make up this shape.
 	origin     Point    // Used as origin for all of the other points. Should be set based on the parent board.
+	kind       Kind     // Which of the seven shapes this is.
 }
 
 // Rotate 90 degrees:
@@ -43,10 +62,56 @@ func (s *Shape) Points() [][]bool {
 func (s *Shape) Color() (R, G, B, A uint8) {
 	return s.R, s.G, s.B, s.A
 }
+func (s *Shape) Kind() Kind {
+	return s.kind
+}
+
+// Copy returns a shape that can be moved and rotated without affecting s.
+func (s *Shape) Copy() *Shape {
+	c := *s
+	c.points = make([][]bool, len(s.points))
+	for i := range s.points {
+		c.points[i] = append([]bool(nil), s.points[i]...)
+	}
+	return &c
+}
+
+// GarbageColor is the color of rows pushed onto the board from below, rather
+// than built out of pieces.
+func GarbageColor() (R, G, B, A uint8) {
+	return 128, 128, 128, 255
+}
+
+// NewShape returns a new shape of the given kind, or nil if there's no such
+// kind.
+func NewShape(kind Kind) *Shape {
+	switch kind {
+	case I:
+		return NewLineShape()
+	case J:
+		return NewJShape()
+	case L:
+		return NewLShape()
+	case O:
+		return NewOShape()
+	case S:
+		return NewSShape()
+	case T:
+		return NewTShape()
+	case Z:
+		return NewZShape()
+	}
+	return nil
+}
+
+// RandomKind returns one of the seven kinds of shape, picked using r.
+func RandomKind(r *rand.Rand) Kind {
+	return Kinds[r.Intn(len(Kinds))]
+}
 
-func NewRandomShape() *Shape {
-	shapes := []func() *Shape{NewLShape, NewJShape, NewLineShape, NewSShape, NewZShape, NewOShape, NewTShape}
-	return shapes[rand.Intn(len(shapes))]()
+// NewRandomShape returns one of the seven shapes, picked using r.
+func NewRandomShape(r *rand.Rand) *Shape {
+	return NewShape(RandomKind(r))
 }
 
 // #
@@ -54,14 +119,15 @@ func NewRandomShape() *Shape {
 // ##
 func NewLShape() *Shape {
 	points := [][]bool{
-		{false, true, true},  // top
+		{false, true, true},  // bottom
 		{false, true, false}, // middle
-		{false, true, false}, // bottom
+		{false, true, false}, // top
 	}
 	return &Shape{
 		R: 0, G: 255, B: 50, A: 255,
 		points: points,
 		origin: Point{0, 0},
+		kind:   L,
 	}
 }
 
@@ -78,6 +144,7 @@ func NewJShape() *Shape {
 		R: 0, G: 255, B: 50, A: 255,
 		points: points,
 		origin: Point{0, 0},
+		kind:   J,
 	}
 }
 
@@ -97,6 +164,7 @@ func NewLineShape() *Shape {
 		R: 255, G: 50, B: 0, A: 255,
 		points: points,
 		origin: Point{0, 0},
+		kind:   I,
 	}
 }
 
@@ -112,6 +180,7 @@ func NewSShape() *Shape {
 		R: 50, G: 50, B: 200, A: 255,
 		points: points,
 		origin: Point{0, 0},
+		kind:   S,
 	}
 }
 
@@ -127,6 +196,7 @@ func NewZShape() *Shape {
 		R: 200, G: 50, B: 50, A: 255,
 		points: points,
 		origin: Point{0, 0},
+		kind:   Z,
 	}
 }
 
@@ -141,6 +211,7 @@ func NewOShape() *Shape {
 		R: 200, G: 200, B: 200, A: 255,
 		points: points,
 		origin: Point{0, 0},
+		kind:   O,
 	}
 }
 
@@ -156,6 +227,7 @@ func NewTShape() *Shape {
 		R: 200, G: 50, B: 50, A: 255,
 		points: points,
 		origin: Point{0, 0},
+		kind:   T,
 	}
 }
 
@@ -0,0 +1,89 @@
+package main
+
+import (
+	"fmt"
+	"log"
+	"strings"
+
+	"github.com/omustardo/tetris/glfw-tetris/gamestate"
+	"github.com/omustardo/tetris/glfw-tetris/modes"
+	"github.com/omustardo/tetris/glfw-tetris/tbp"
+)
+
+// bench has a bot play games by itself, as fast as it can, and prints how
+// each one went and how they went on average. The games are the same each
+// time for the same seed, so the results can be compared before and after
+// changing the rules or the bot.
+func bench(games int) {
+	options := modes.Options{Messiness: modes.DefaultMessiness}
+	if _, err := modes.New(*mode, options); err != nil {
+		log.Fatalln(err)
+	}
+	var engine *tbp.Bot
+	if *tbpBot != "" {
+		var err error
+		if engine, err = tbp.Launch(strings.Fields(*tbpBot)...); err != nil {
+			log.Fatalln(err)
+		}
+		defer engine.Close()
+		// The game waits for the bot, so that it plays the same however
+		// long it thinks.
+		engine.Wait = true
+		fmt.Printf("benchmarking %s %s by %s\n", engine.Info.Name, engine.Info.Version, engine.Info.Author)
+	}
+	// The engine logs every cleared row, which would bury the results.
+	log.SetOutput(nopWriter{})
+	var total gamestate.Stats
+	score, toppedOut := 0, 0
+	for i := 0; i < games; i++ {
+		config := gamestate.Config{
+			Width:        gamestate.DefaultWidth,
+			Height:       gamestate.DefaultHeight,
+			BufferHeight: gamestate.DefaultBufferHeight,
+			Seed:         *seed + int64(i),
+		}
+		s := gamestate.NewState(config)
+		m, _ := modes.New(*mode, options)
+		s.SetMode(m)
+		bot := gamestate.NewBot(*delay)
+		if engine != nil {
+			bot.Engine = engine
+		}
+		for !s.GameOver() && s.Stats().Pieces < *pieces {
+			s.Apply(bot.Input(s))
+			s.Tick()
+		}
+
+		stats := s.Stats()
+		result := "stopped"
+		switch {
+		case s.ToppedOut():
+			result = "topped out"
+			toppedOut++
+		case s.GameOver():
+			result = "finished"
+		}
+		fmt.Printf("game %d, seed %d: %s with score %d, %d lines, %d pieces in %s, %.2f keys per piece\n",
+			i+1, config.Seed, result, s.Score(), stats.Lines, stats.Pieces, gamestate.FormatTicks(stats.Ticks), keysPerPiece(stats))
+		total.Ticks += stats.Ticks
+		total.Pieces += stats.Pieces
+		total.Lines += stats.Lines
+		total.Keys += stats.Keys
+		score += s.Score()
+	}
+	fmt.Printf("average of %d games: score %d, %d lines, %d pieces in %s, %.2f keys per piece, %d topped out\n",
+		games, score/games, total.Lines/games, total.Pieces/games, gamestate.FormatTicks(total.Ticks/games), keysPerPiece(total), toppedOut)
+}
+
+func keysPerPiece(stats gamestate.Stats) float64 {
+	if stats.Pieces == 0 {
+		return 0
+	}
+	return float64(stats.Keys) / float64(stats.Pieces)
+}
+
+type nopWriter struct{}
+
+func (nopWriter) Write(p []byte) (int, error) {
+	return len(p), nil
+}
@@ -0,0 +1,107 @@
+// tetris-bot connects bots to a tetris-server to fill the seats in its games,
+// so that battles can be tried out without gathering lots of people. It can
+// also benchmark a bot by having it play games by itself, or play as a bot in
+// another program's games over the Tetris Bot Protocol. The bots play with the
+// engine of glfw-tetris, without opening any windows.
+package main
+
+import (
+	"flag"
+	"fmt"
+	"log"
+	"math/rand"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/omustardo/tetris/glfw-tetris/gamestate"
+	"github.com/omustardo/tetris/glfw-tetris/modes"
+	"github.com/omustardo/tetris/glfw-tetris/netplay"
+	"github.com/omustardo/tetris/tetris-server/protocol"
+)
+
+var (
+	serverAddr = flag.String("server", "localhost:7777", "host:port of the tetris-server to connect to over TCP")
+	count      = flag.Int("count", 1, "number of bots to connect")
+	name       = flag.String("name", "BOT", "name of the bots, followed by their number")
+	delay      = flag.Int("delay", 6, "ticks that the bots wait between key presses. Higher is slower.")
+	targeting  = flag.String("targeting", "", "targeting mode of the bots: random, attackers, kos or badges. Leave empty to pick one at random for each bot.")
+	benchGames = flag.Int("bench", 0, "instead of connecting to a server, have a bot play this many games by itself as fast as it can, and print how it did")
+	mode       = flag.String("mode", "", "with -bench, the game mode, one of: "+strings.Join(modes.Names(), ", ")+". Leave empty for endless games.")
+	pieces     = flag.Int("pieces", 1000, "with -bench, the number of pieces to stop each game after if it hasn't ended")
+	seed       = flag.Int64("seed", 1, "with -bench, the seed of the first game, counting up from there for the rest")
+	tbpBot     = flag.String("tbp_bot", "", "with -bench, command that starts a bot speaking the Tetris Bot Protocol to benchmark instead of the built-in one, like \"cold-clear\"")
+	serveBot   = flag.Bool("tbp", false, "instead of connecting to a server, play as a bot speaking the Tetris Bot Protocol over stdin and stdout, for another program's games")
+)
+
+func main() {
+	flag.Parse()
+	if *count < 1 {
+		log.Fatalln("-count must be at least 1")
+	}
+	if *delay < 0 {
+		log.Fatalln("-delay can't be negative")
+	}
+	if *benchGames > 0 {
+		bench(*benchGames)
+		return
+	}
+	if *serveBot {
+		serveTBP()
+		return
+	}
+	target := 0
+	if *targeting != "" {
+		for i, t := range protocol.Targetings {
+			if string(t) == *targeting {
+				target = i + 1
+			}
+		}
+		if target == 0 {
+			log.Fatalf("unknown targeting %q, expected one of %v", *targeting, protocol.Targetings)
+		}
+	}
+
+	var wg sync.WaitGroup
+	for i := 1; i <= *count; i++ {
+		botTarget := target
+		if botTarget == 0 {
+			botTarget = rand.Intn(len(protocol.Targetings)) + 1
+		}
+		wg.Add(1)
+		go func(name string) {
+			defer wg.Done()
+			play(name, botTarget)
+		}(fmt.Sprintf("%s%d", *name, i))
+	}
+	wg.Wait()
+}
+
+// play connects a bot and plays games until it's disconnected. The bot asks
+// for another game as soon as each one ends.
+func play(name string, target int) {
+	client, err := netplay.Connect(*serverAddr, name)
+	if err != nil {
+		log.Println(name, err)
+		return
+	}
+	defer client.Close()
+	log.Println(name, "connected")
+
+	bot := gamestate.NewBot(*delay)
+	ticker := time.NewTicker(time.Second / gamestate.TicksPerSecond)
+	defer ticker.Stop()
+	for range ticker.C {
+		client.Update()
+		if client.Closed() {
+			log.Println(name, "disconnected")
+			return
+		}
+		in := bot.Input(client.Local)
+		// Only sent once, since the server remembers it.
+		in.Target, target = target, 0
+		in.Restart = client.Local.GameOver()
+		client.Apply(in)
+		client.Tick()
+	}
+}
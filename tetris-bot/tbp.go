@@ -0,0 +1,107 @@
+package main
+
+import (
+	"io"
+	"log"
+	"os"
+	"reflect"
+
+	"github.com/omustardo/tetris/glfw-tetris/gamestate"
+	"github.com/omustardo/tetris/glfw-tetris/tbp"
+	"github.com/omustardo/tetris/glfw-tetris/tetronimoes"
+)
+
+// serveTBP plays as a bot in another program's game, speaking the Tetris Bot
+// Protocol over stdin and stdout, with the built-in bot picking the moves. It
+// never holds. Logs go to stderr as usual, out of the protocol's way.
+func serveTBP() {
+	stream := tbp.NewStream(os.Stdin, os.Stdout)
+	send := func(m tbp.Message) {
+		if err := stream.Send(m); err != nil {
+			log.Fatalln(err)
+		}
+	}
+	send(tbp.Message{Type: tbp.Info, Name: "tetris-bot", Version: "1", Author: "omustardo"})
+
+	bot := gamestate.NewBot(0)
+	// The game as the frontend last described it.
+	var field tbp.Field
+	var queue []tetronimoes.Kind
+	for {
+		m, err := stream.Receive()
+		if err == io.EOF {
+			return
+		}
+		if err != nil {
+			log.Fatalln(err)
+		}
+		switch m.Type {
+		case tbp.Rules:
+			send(tbp.Message{Type: tbp.Ready})
+		case tbp.Start:
+			field, queue = m.Board, nil
+			for _, name := range m.Queue {
+				queue = append(queue, pieceKind(name))
+			}
+		case tbp.NewPiece:
+			queue = append(queue, pieceKind(m.Piece))
+		case tbp.Suggest:
+			send(tbp.Message{Type: tbp.Suggestion, Moves: suggest(bot, field, queue)})
+		case tbp.Play:
+			if len(queue) == 0 || m.Move.Location.Type != tbp.PieceName(queue[0]) {
+				log.Fatalf("%s was played, but the falling piece is %v", m.Move.Location.Type, queue)
+			}
+			cells, err := m.Move.Location.Cells()
+			if err != nil {
+				log.Fatalln(err)
+			}
+			field, queue = field.Place(m.Move.Location.Type, cells), queue[1:]
+		case tbp.Stop:
+			field, queue = nil, nil
+		case tbp.Quit:
+			return
+		}
+	}
+}
+
+func pieceKind(name string) tetronimoes.Kind {
+	kind, err := tbp.PieceKind(name)
+	if err != nil {
+		log.Fatalln(err)
+	}
+	return kind
+}
+
+// suggest returns where the bot would put the first piece in the queue on
+// the field, or no moves if there's nowhere.
+func suggest(bot *gamestate.Bot, field tbp.Field, queue []tetronimoes.Kind) []tbp.Move {
+	if len(queue) == 0 {
+		return nil
+	}
+	// New pieces spawn at the top of the 20 rows that bots expect to be
+	// visible.
+	visible := 20
+	s := gamestate.NewState(gamestate.Config{Width: tbp.FieldWidth, Height: visible, BufferHeight: tbp.FieldHeight - visible})
+	if err := s.SetBoard(&gamestate.Board{Rows: field.Rows()}); err != nil {
+		log.Fatalln(err)
+	}
+	s.SetQueue(queue, true)
+	s.Tick()
+	if s.GameOver() {
+		return nil
+	}
+	inputs, _, _ := bot.Pick(s, true)
+	kind, _ := s.PieceCells()
+	for _, move := range s.Moves() {
+		if !reflect.DeepEqual(move.Inputs, inputs) {
+			continue
+		}
+		l, _ := tbp.Locate(kind, move.Cells)
+		spin := tbp.NoSpin
+		if move.TSpin {
+			spin = tbp.FullSpin
+		}
+		return []tbp.Move{{Location: l, Spin: spin}}
+	}
+	return nil
+}
@@ -0,0 +1,137 @@
+package main
+
+import (
+	"image"
+	"image/color"
+	"image/color/palette"
+	"image/draw"
+	"image/gif"
+	"image/png"
+	"os"
+	"path/filepath"
+
+	"github.com/omustardo/tetris/glfw-tetris/gamestate"
+	"github.com/omustardo/tetris/glfw-tetris/replay"
+)
+
+// savePNG plays a replay up to a frame, or to the end if frame is negative,
+// and saves a screenshot of it to p.
+func savePNG(p string, r *replay.Replay, frame int) error {
+	playback, err := r.Play()
+	if err != nil {
+		return err
+	}
+	for !playback.Done() && (frame < 0 || playback.Frame() < frame) {
+		playback.Step()
+	}
+	return writeFile(p, func(f *os.File) error {
+		return png.Encode(f, playback.State().Image(*width, *height))
+	})
+}
+
+// saveGIF plays a replay through and saves it to p as an animated GIF, at the
+// frame rate set by -fps. Each frame of the GIF only holds the area that
+// changed since the one before, which keeps long replays small.
+func saveGIF(p string, r *replay.Replay) error {
+	playback, err := r.Play()
+	if err != nil {
+		return err
+	}
+	anim := &gif.GIF{}
+	var previous *image.RGBA
+	for n := 0; ; n++ {
+		for frame := n * gamestate.TicksPerSecond / *fps; playback.Frame() < frame && !playback.Done(); {
+			playback.Step()
+		}
+		img := playback.State().Image(*width, *height)
+		// Delays are in hundredths of a second, so they're rounded in a way
+		// that keeps the GIF from drifting from the game's clock.
+		delay := (n+1)*100 / *fps - n*100 / *fps
+		changed := img.Bounds()
+		if previous != nil {
+			changed = difference(previous, img)
+		}
+		if changed.Empty() {
+			anim.Delay[len(anim.Delay)-1] += delay
+		} else {
+			anim.Image = append(anim.Image, paletted(img, changed))
+			anim.Delay = append(anim.Delay, delay)
+			anim.Disposal = append(anim.Disposal, gif.DisposalNone)
+		}
+		previous = img
+		if playback.Done() {
+			break
+		}
+	}
+	// Linger on the end of the game before looping.
+	anim.Delay[len(anim.Delay)-1] += 300
+	return writeFile(p, func(f *os.File) error {
+		return gif.EncodeAll(f, anim)
+	})
+}
+
+// difference returns the smallest rectangle holding every pixel that differs
+// between two images of the same size.
+func difference(a, b *image.RGBA) image.Rectangle {
+	var changed image.Rectangle
+	bounds := a.Bounds()
+	for y := bounds.Min.Y; y < bounds.Max.Y; y++ {
+		for x := bounds.Min.X; x < bounds.Max.X; x++ {
+			if a.RGBAAt(x, y) != b.RGBAAt(x, y) {
+				changed = changed.Union(image.Rect(x, y, x+1, y+1))
+			}
+		}
+	}
+	return changed
+}
+
+// paletted converts the area r of img to a paletted image. Games are drawn in
+// few enough colors that they usually fit in a palette exactly. If not, the
+// colors are matched to a standard palette instead.
+func paletted(img *image.RGBA, r image.Rectangle) *image.Paletted {
+	colors := exactPalette(img, r)
+	if colors == nil {
+		colors = palette.Plan9
+	}
+	out := image.NewPaletted(r, colors)
+	draw.Draw(out, r, img, r.Min, draw.Src)
+	return out
+}
+
+// exactPalette returns the colors in the area r of img, or nil if there are
+// more than fit in a GIF's palette.
+func exactPalette(img *image.RGBA, r image.Rectangle) color.Palette {
+	var colors color.Palette
+	seen := make(map[color.RGBA]bool)
+	for y := r.Min.Y; y < r.Max.Y; y++ {
+		for x := r.Min.X; x < r.Max.X; x++ {
+			c := img.RGBAAt(x, y)
+			if seen[c] {
+				continue
+			}
+			if len(colors) == 256 {
+				return nil
+			}
+			seen[c] = true
+			colors = append(colors, c)
+		}
+	}
+	return colors
+}
+
+// writeFile creates the file at p, along with its directory, and writes it
+// with write.
+func writeFile(p string, write func(*os.File) error) error {
+	if err := os.MkdirAll(filepath.Dir(p), 0755); err != nil {
+		return err
+	}
+	f, err := os.Create(p)
+	if err != nil {
+		return err
+	}
+	if err := write(f); err != nil {
+		f.Close()
+		return err
+	}
+	return f.Close()
+}
@@ -0,0 +1,139 @@
+// tetris-replay checks replays saved by the games' -record flag, by playing
+// each one through with the engine of glfw-tetris, without opening any
+// windows, and comparing how it turns out with what the replay claims. It can
+// also save screenshots of replays as PNGs and whole replays as animated GIFs,
+// drawn in software so no GPU is needed, and print the pieces placed in
+// replays as fumen, to share positions with fumen editors.
+package main
+
+import (
+	"flag"
+	"fmt"
+	"log"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"github.com/omustardo/tetris/glfw-tetris/gamestate"
+	"github.com/omustardo/tetris/glfw-tetris/replay"
+)
+
+var (
+	pngDir = flag.String("png", "", "directory to save a PNG screenshot of each replay into, taken at the time set by -at")
+	at     = flag.Duration("at", -1, "how far into each replay to take PNG screenshots, like 1m30s. Negative for the end of the replay.")
+	gifDir = flag.String("gif", "", "directory to save each replay into as an animated GIF")
+	fps    = flag.Int("fps", 20, "frames per second of GIFs, up to the game's 60 ticks per second")
+	width  = flag.Int("width", 300, "width of screenshots and GIFs, in pixels")
+	height = flag.Int("height", 500, "height of screenshots and GIFs, in pixels")
+	fumens = flag.Bool("fumen", false, "print a fumen of each replay, with a page for each piece placed between -from and -to")
+	from   = flag.Duration("from", 0, "how far into each replay to start fumens")
+	to     = flag.Duration("to", -1, "how far into each replay to end fumens. Negative for the end of the replay.")
+)
+
+func main() {
+	flag.Usage = func() {
+		fmt.Fprintf(flag.CommandLine.Output(), "Usage: %s replay_file...\n", os.Args[0])
+		flag.PrintDefaults()
+	}
+	flag.Parse()
+	if flag.NArg() == 0 {
+		flag.Usage()
+		os.Exit(2)
+	}
+	if *fps < 1 || *fps > gamestate.TicksPerSecond {
+		log.Fatalf("-fps must be from 1 to %d, got %d", gamestate.TicksPerSecond, *fps)
+	}
+	if *width < 1 || *height < 1 {
+		log.Fatalf("-width and -height must be positive, got %dx%d", *width, *height)
+	}
+	// The engine logs every cleared row, which would bury the results.
+	log.SetOutput(nopWriter{})
+
+	failed := false
+	for _, path := range flag.Args() {
+		r, err := replay.Load(path)
+		if err == nil {
+			err = verify(path, r)
+		}
+		if err == nil {
+			err = export(path, r)
+		}
+		if err != nil {
+			fmt.Printf("%s: FAILED: %v\n", path, err)
+			failed = true
+		}
+	}
+	if failed {
+		os.Exit(1)
+	}
+}
+
+// verify plays a replay through and prints its result if it's what the
+// replay claims.
+func verify(path string, r *replay.Replay) error {
+	result, err := r.Verify()
+	if err != nil {
+		return err
+	}
+	mode := r.Mode
+	if mode == "" {
+		mode = "endless"
+	}
+	fmt.Printf("%s: OK: %s %s by %s, score %d, %d lines, %d pieces in %s\n",
+		path, mode, describeConfig(r.Config), r.Name, result.Score, result.Lines, result.Pieces, gamestate.FormatTicks(result.Ticks))
+	return nil
+}
+
+// export saves the screenshot and GIF of a replay asked for by the flags,
+// named after its file, and prints its fumen if asked for.
+func export(path string, r *replay.Replay) error {
+	name := strings.TrimSuffix(filepath.Base(path), replay.Ext)
+	if *pngDir != "" {
+		p := filepath.Join(*pngDir, name+".png")
+		if err := savePNG(p, r, frameAt(*at)); err != nil {
+			return err
+		}
+		fmt.Printf("%s: saved screenshot to %s\n", path, p)
+	}
+	if *gifDir != "" {
+		p := filepath.Join(*gifDir, name+".gif")
+		if err := saveGIF(p, r); err != nil {
+			return err
+		}
+		fmt.Printf("%s: saved GIF to %s\n", path, p)
+	}
+	if *fumens {
+		text, err := r.Fumen(frameAt(*from), frameAt(*to))
+		if err != nil {
+			return err
+		}
+		fmt.Printf("%s: fumen: %s\n", path, text)
+	}
+	return nil
+}
+
+// frameAt returns the frame of a replay that's d into it, or -1 if d is
+// negative.
+func frameAt(d time.Duration) int {
+	if d < 0 {
+		return -1
+	}
+	return int(d * gamestate.TicksPerSecond / time.Second)
+}
+
+// describeConfig returns the size of the board, and whether it's big, like
+// 10x20 or 10x20 big.
+func describeConfig(c gamestate.Config) string {
+	s := fmt.Sprintf("%dx%d", c.Width, c.Height)
+	if c.Big {
+		s += " big"
+	}
+	return s
+}
+
+type nopWriter struct{}
+
+func (nopWriter) Write(p []byte) (int, error) {
+	return len(p), nil
+}
@@ -0,0 +1,45 @@
+package main
+
+import (
+	"net"
+
+	"github.com/omustardo/tetris/tetris-server/protocol"
+	"golang.org/x/net/websocket"
+)
+
+// conn is a connection to a game, over any transport.
+type conn interface {
+	Send(protocol.Message) error
+	// Receive blocks until a message arrives, or the connection is closed.
+	Receive() (protocol.Message, error)
+	Close() error
+}
+
+func newStreamConn(c net.Conn) conn {
+	return protocol.NewStream(c)
+}
+
+// wsConn sends each message in its own text frame.
+type wsConn struct {
+	ws *websocket.Conn
+}
+
+func (c wsConn) Send(m protocol.Message) error {
+	data, err := protocol.Encode(m)
+	if err != nil {
+		return err
+	}
+	return websocket.Message.Send(c.ws, string(data))
+}
+
+func (c wsConn) Receive() (protocol.Message, error) {
+	var data string
+	if err := websocket.Message.Receive(c.ws, &data); err != nil {
+		return protocol.Message{}, err
+	}
+	return protocol.Decode([]byte(data))
+}
+
+func (c wsConn) Close() error {
+	return c.ws.Close()
+}
@@ -0,0 +1,87 @@
+// tetris-server hosts versus games and battles between players connecting
+// from any of the frontends. Desktop games connect over TCP and browser games
+// over WebSocket, and the two can play each other. Spectators can watch every
+// game over WebSocket too.
+package main
+
+import (
+	"flag"
+	"log"
+	"net"
+	"net/http"
+
+	"github.com/omustardo/tetris/tetris-server/spectate"
+	"golang.org/x/net/websocket"
+)
+
+var (
+	tcpAddr      = flag.String("tcp_addr", ":7777", "address to listen on for games connecting over TCP. Leave empty to not listen for them.")
+	wsAddr       = flag.String("ws_addr", ":7778", "address to listen on for games connecting over WebSocket, at "+wsPath+". Leave empty to not listen for them.")
+	boardWidth   = flag.Int("board_width", 10, "number of columns in the board")
+	boardHeight  = flag.Int("board_height", 20, "number of visible rows in the board")
+	bufferHeight = flag.Int("buffer_height", 20, "number of hidden rows above the board where pieces spawn")
+	spectateAddr = flag.String("spectate_addr", "", "address to serve a feed of every game to spectators on, at "+spectate.Path+", like :7779. Leave empty to not serve one.")
+	players      = flag.Int("players", 2, "number of players in each game. 2 for versus games, or more for battles.")
+)
+
+const (
+	// wsPath is the path that WebSocket connections are accepted on.
+	wsPath = "/play"
+	// maxPlayers is the most players that can be in one game.
+	maxPlayers = 99
+)
+
+func main() {
+	flag.Parse()
+	if *tcpAddr == "" && *wsAddr == "" {
+		log.Fatalln("at least one of -tcp_addr and -ws_addr is needed")
+	}
+	if *boardWidth < 4 || *boardHeight < 4 || *bufferHeight < 0 {
+		log.Fatalf("board must be at least 4x4, got %dx%d with %d hidden rows", *boardWidth, *boardHeight, *bufferHeight)
+	}
+	if *players < 2 || *players > maxPlayers {
+		log.Fatalf("-players must be from 2 to %d, got %d", maxPlayers, *players)
+	}
+	s := newServer(*boardWidth, *boardHeight, *bufferHeight, *players)
+
+	errs := make(chan error)
+	if *spectateAddr != "" {
+		listener, err := net.Listen("tcp", *spectateAddr)
+		if err != nil {
+			log.Fatalln(err)
+		}
+		s.spectators = spectate.NewHub()
+		log.Printf("Serving games to spectators on %s%s", listener.Addr(), spectate.Path)
+		go func() {
+			errs <- s.spectators.Serve(listener)
+		}()
+	}
+	if *tcpAddr != "" {
+		listener, err := net.Listen("tcp", *tcpAddr)
+		if err != nil {
+			log.Fatalln(err)
+		}
+		log.Println("Listening for TCP connections on", listener.Addr())
+		go func() {
+			for {
+				c, err := listener.Accept()
+				if err != nil {
+					errs <- err
+					return
+				}
+				go s.serve(newStreamConn(c), c.RemoteAddr().String())
+			}
+		}()
+	}
+	if *wsAddr != "" {
+		mux := http.NewServeMux()
+		mux.Handle(wsPath, websocket.Handler(func(ws *websocket.Conn) {
+			s.serve(wsConn{ws}, ws.Request().RemoteAddr)
+		}))
+		log.Printf("Listening for WebSocket connections on %s%s", *wsAddr, wsPath)
+		go func() {
+			errs <- http.ListenAndServe(*wsAddr, mux)
+		}()
+	}
+	log.Fatalln(<-errs)
+}
@@ -0,0 +1,174 @@
+// Package protocol defines the messages passed between tetris-server and the
+// games that connect to it to play each other.
+//
+// Each message is a JSON object. Over TCP they're sent one per line, and over
+// WebSocket one per text frame. A client starts by sending Hello with the
+// version of the protocol it speaks, and the server replies with Welcome if
+// it speaks the same one, or Error and closes the connection if not.
+//
+// Once welcomed, the client waits in the lobby, and is sent Waiting whenever
+// someone joins or leaves it. Players are put in rooms of a size set by the
+// server, two for versus games or up to about fifty for battles. When a room
+// fills up the server sends everyone in it Start, with the seed and board size
+// of the game, and they each play it out locally.
+//
+// Each player attacks one other player at a time, their target, picked by the
+// server according to the targeting mode that the player asked for with
+// Target. Whenever their target, or anything else shown in their HUD, changes
+// the server sends them Status. Every piece a player locks is sent as Placed,
+// which the server passes on to everyone targeting them so they can see the
+// board. Garbage is sent as Attack, which reaches the targets as Garbage.
+//
+// When a player tops out they send Lost, and the server sends everyone left
+// in the room KO, and the player Result with their place. Disconnecting
+// counts as topping out. Once one player is left they're sent Result too, and
+// the game is over. Players can send Ready after getting Result to go back to
+// the lobby for another game.
+package protocol
+
+import (
+	"encoding/json"
+	"fmt"
+)
+
+// Version is the version of the protocol described here. It changes whenever
+// a change to the messages would confuse older clients or servers.
+const Version = 2
+
+// Type says what a message is for, and which of its fields are used.
+type Type string
+
+const (
+	// Client to server, first: Version and Name.
+	Hello Type = "hello"
+	// Server to client, in reply to Hello: Version.
+	Welcome Type = "welcome"
+	// Server to everyone in the lobby: Players are waiting for a room of
+	// Size to fill up.
+	Waiting Type = "waiting"
+	// Server to everyone in a room: a game is starting. Seed, Width, Height
+	// and BufferHeight set up the game. Names lists everyone in the room,
+	// and Player is the receiver's index in it.
+	Start Type = "start"
+	// Client to server: a piece locked. Piece is its kind, and Board the
+	// visible rows of the board afterwards, top first, in the format of
+	// gamestate.State.Rows. Passed on to the players targeting them, with
+	// Player set to whose board it is.
+	Placed Type = "placed"
+	// Client to server: Rows of garbage to send to the player's targets.
+	Attack Type = "attack"
+	// Server to client: Rows of garbage received from Player, including any
+	// bonus for their badges.
+	Garbage Type = "garbage"
+	// Client to server: use the given Targeting mode to pick targets.
+	Target Type = "target"
+	// Server to client: the player's HUD changed. Player is their target,
+	// picked with Targeting, and Attackers is how many players are targeting
+	// them. Alive is how many players are left in the game, and KOs and
+	// Badges are the player's own.
+	Status Type = "status"
+	// Client to server: the player topped out.
+	Lost Type = "lost"
+	// Server to everyone left in a room: Player was knocked out, and came in
+	// Place. By is who gets the credit, the last player to send them
+	// garbage, or -1 if no-one did.
+	KO Type = "ko"
+	// Server to client: the player is out of the game, or is the last one
+	// left, and came in Place. Won is set for the last one left.
+	Result Type = "result"
+	// Client to server: the player wants to play again.
+	Ready Type = "ready"
+	// Either way: Error says what went wrong. The connection is closed after
+	// sending it.
+	Error Type = "error"
+)
+
+// Message is a single message of any type. Fields not used by its type are
+// left empty.
+type Message struct {
+	Type    Type   `json:"type"`
+	Version int    `json:"version,omitempty"`
+	Name    string `json:"name,omitempty"`
+
+	Seed         int64 `json:"seed,omitempty"`
+	Width        int   `json:"width,omitempty"`
+	Height       int   `json:"height,omitempty"`
+	BufferHeight int   `json:"buffer_height,omitempty"`
+
+	Players int      `json:"players,omitempty"`
+	Size    int      `json:"size,omitempty"`
+	Names   []string `json:"names,omitempty"`
+	Player  int      `json:"player,omitempty"`
+
+	Piece string   `json:"piece,omitempty"`
+	Board []string `json:"board,omitempty"`
+	Rows  int      `json:"rows,omitempty"`
+
+	Targeting Targeting `json:"targeting,omitempty"`
+	Attackers int       `json:"attackers,omitempty"`
+	Alive     int       `json:"alive,omitempty"`
+	KOs       int       `json:"kos,omitempty"`
+	Badges    int       `json:"badges,omitempty"`
+	By        int       `json:"by,omitempty"`
+	Place     int       `json:"place,omitempty"`
+
+	Won   bool   `json:"won,omitempty"`
+	Error string `json:"error,omitempty"`
+}
+
+// Targeting is a way of picking which player to attack.
+type Targeting string
+
+const (
+	// Attack someone picked at random, changing after every attack.
+	Random Targeting = "random"
+	// Attack back everyone targeting the player, or someone at random if
+	// no-one is.
+	Attackers Targeting = "attackers"
+	// Attack whoever is closest to topping out, with the highest stack.
+	KOs Targeting = "kos"
+	// Attack whoever has the most badges, earned by knocking players out.
+	Badges Targeting = "badges"
+)
+
+// Targetings lists every targeting mode, with the default first.
+var Targetings = []Targeting{Random, Attackers, KOs, Badges}
+
+// ValidTargeting returns whether t is one of Targetings.
+func ValidTargeting(t Targeting) bool {
+	for _, valid := range Targetings {
+		if t == valid {
+			return true
+		}
+	}
+	return false
+}
+
+// Encode returns the message as JSON, without a trailing newline.
+func Encode(m Message) ([]byte, error) {
+	return json.Marshal(m)
+}
+
+// Decode parses a message encoded by Encode.
+func Decode(data []byte) (Message, error) {
+	var m Message
+	if err := json.Unmarshal(data, &m); err != nil {
+		return Message{}, fmt.Errorf("bad message: %v", err)
+	}
+	if m.Type == "" {
+		return Message{}, fmt.Errorf("message has no type: %s", data)
+	}
+	return m, nil
+}
+
+// CheckHello returns an error if m isn't a Hello in this version of the
+// protocol.
+func CheckHello(m Message) error {
+	if m.Type != Hello {
+		return fmt.Errorf("expected %q, got %q", Hello, m.Type)
+	}
+	if m.Version != Version {
+		return fmt.Errorf("unsupported protocol version %d, expected %d", m.Version, Version)
+	}
+	return nil
+}
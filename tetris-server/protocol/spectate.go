@@ -0,0 +1,139 @@
+package protocol
+
+// Spectators watch games through a feed of Updates, sent over WebSocket as
+// JSON with one Update per text frame. A feed can hold any number of games,
+// each with its own number. The first Update for a game carries its whole
+// Frame, and later ones only the parts that changed, so that a game sitting
+// still costs nothing to watch.
+
+// Frame is everything shown of a game at one moment.
+type Frame struct {
+	Name   string `json:"name,omitempty"`
+	Width  int    `json:"width"`
+	Height int    `json:"height"`
+	// Rows holds the visible rows of the board, top first, in the format of
+	// gamestate.State.Rows but with the falling piece drawn in using the
+	// letter of its kind.
+	Rows []string `json:"rows"`
+	// Queue holds the kinds of the upcoming pieces, next first.
+	Queue string `json:"queue,omitempty"`
+	// HUD holds the lines shown above the board, and Results those shown over
+	// it once the game is over.
+	HUD     []string `json:"hud,omitempty"`
+	Results []string `json:"results,omitempty"`
+	Over    bool     `json:"over,omitempty"`
+	Paused  bool     `json:"paused,omitempty"`
+	Stats   Stats    `json:"stats"`
+}
+
+// Stats are the numbers kept about a game.
+type Stats struct {
+	Score  int `json:"score,omitempty"`
+	Level  int `json:"level,omitempty"`
+	Pieces int `json:"pieces,omitempty"`
+	Lines  int `json:"lines,omitempty"`
+}
+
+// Update changes one game in a feed. Fields left empty haven't changed.
+type Update struct {
+	Game int `json:"game"`
+	// Full replaces the whole frame, and is sent first for each game.
+	Full *Frame `json:"full,omitempty"`
+	// Gone says the game was taken out of the feed.
+	Gone bool `json:"gone,omitempty"`
+	// Rows holds the rows that changed, by their index in Frame.Rows.
+	Rows    map[int]string `json:"rows,omitempty"`
+	Queue   *string        `json:"queue,omitempty"`
+	HUD     *[]string      `json:"hud,omitempty"`
+	Results *[]string      `json:"results,omitempty"`
+	Over    *bool          `json:"over,omitempty"`
+	Paused  *bool          `json:"paused,omitempty"`
+	Stats   *Stats         `json:"stats,omitempty"`
+}
+
+// Diff returns the update that turns frame old into new, and whether there's
+// anything in it. If the board changed size, it's a full update.
+func Diff(old, new Frame) (Update, bool) {
+	if old.Name != new.Name || old.Width != new.Width || old.Height != new.Height || len(old.Rows) != len(new.Rows) {
+		return Update{Full: &new}, true
+	}
+	var u Update
+	changed := false
+	for i, row := range new.Rows {
+		if row != old.Rows[i] {
+			if u.Rows == nil {
+				u.Rows = make(map[int]string)
+			}
+			u.Rows[i] = row
+			changed = true
+		}
+	}
+	if old.Queue != new.Queue {
+		u.Queue, changed = &new.Queue, true
+	}
+	if !equalLines(old.HUD, new.HUD) {
+		u.HUD, changed = &new.HUD, true
+	}
+	if !equalLines(old.Results, new.Results) {
+		u.Results, changed = &new.Results, true
+	}
+	if old.Over != new.Over {
+		u.Over, changed = &new.Over, true
+	}
+	if old.Paused != new.Paused {
+		u.Paused, changed = &new.Paused, true
+	}
+	if old.Stats != new.Stats {
+		u.Stats, changed = &new.Stats, true
+	}
+	return u, changed
+}
+
+func equalLines(a, b []string) bool {
+	if len(a) != len(b) {
+		return false
+	}
+	for i := range a {
+		if a[i] != b[i] {
+			return false
+		}
+	}
+	return true
+}
+
+// Apply changes the frame by an update. Rows outside of the board are
+// ignored.
+func (f *Frame) Apply(u Update) {
+	if u.Full != nil {
+		*f = *u.Full
+		return
+	}
+	if len(u.Rows) > 0 {
+		// Copy the rows so that frames the old ones were shared with keep
+		// them.
+		f.Rows = append([]string(nil), f.Rows...)
+		for i, row := range u.Rows {
+			if i >= 0 && i < len(f.Rows) {
+				f.Rows[i] = row
+			}
+		}
+	}
+	if u.Queue != nil {
+		f.Queue = *u.Queue
+	}
+	if u.HUD != nil {
+		f.HUD = *u.HUD
+	}
+	if u.Results != nil {
+		f.Results = *u.Results
+	}
+	if u.Over != nil {
+		f.Over = *u.Over
+	}
+	if u.Paused != nil {
+		f.Paused = *u.Paused
+	}
+	if u.Stats != nil {
+		f.Stats = *u.Stats
+	}
+}
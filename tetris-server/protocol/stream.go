@@ -0,0 +1,50 @@
+package protocol
+
+import (
+	"bufio"
+	"io"
+)
+
+// maxLine is the longest message accepted over a Stream. A board is the
+// biggest part of a message, so this leaves plenty of room.
+const maxLine = 1 << 16
+
+// Stream sends and receives messages over a byte stream like a TCP
+// connection, one per line.
+type Stream struct {
+	rw      io.ReadWriteCloser
+	scanner *bufio.Scanner
+}
+
+func NewStream(rw io.ReadWriteCloser) *Stream {
+	scanner := bufio.NewScanner(rw)
+	scanner.Buffer(make([]byte, 4096), maxLine)
+	return &Stream{rw: rw, scanner: scanner}
+}
+
+// Send writes a message. It isn't safe to call from more than one goroutine
+// at once.
+func (s *Stream) Send(m Message) error {
+	data, err := Encode(m)
+	if err != nil {
+		return err
+	}
+	_, err = s.rw.Write(append(data, '\n'))
+	return err
+}
+
+// Receive blocks until a message arrives. It returns io.EOF once the other
+// end has closed the connection.
+func (s *Stream) Receive() (Message, error) {
+	if !s.scanner.Scan() {
+		if err := s.scanner.Err(); err != nil {
+			return Message{}, err
+		}
+		return Message{}, io.EOF
+	}
+	return Decode(s.scanner.Bytes())
+}
+
+func (s *Stream) Close() error {
+	return s.rw.Close()
+}
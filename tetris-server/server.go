@@ -0,0 +1,502 @@
+package main
+
+import (
+	"fmt"
+	"log"
+	"math/rand"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/omustardo/tetris/tetris-server/protocol"
+	"github.com/omustardo/tetris/tetris-server/spectate"
+)
+
+const (
+	// Number of messages that can be waiting to go out to a player before
+	// they're considered too slow and disconnected.
+	sendBuffer = 256
+	// Longest name that players can have. Longer ones are cut short.
+	maxNameLength = 16
+)
+
+// server puts players into rooms as they connect and passes messages
+// between them.
+type server struct {
+	width, height, bufferHeight int
+	size                        int // Number of players in a room.
+
+	mu sync.Mutex // Guards everything below, and the players' fields.
+	// lobby holds the players waiting for a room to fill up, in the order
+	// they joined.
+	lobby []*player
+	rng   *rand.Rand
+	// spectators is the feed that games are shown in, if the server has one.
+	spectators *spectate.Hub
+	lastGame   int // Number of the last game added to the feed.
+}
+
+func newServer(width, height, bufferHeight, size int) *server {
+	return &server{
+		width:        width,
+		height:       height,
+		bufferHeight: bufferHeight,
+		size:         size,
+		rng:          rand.New(rand.NewSource(time.Now().UnixNano())),
+	}
+}
+
+// player is a connected game.
+type player struct {
+	name string
+	addr string
+	// out holds messages waiting to be sent. It's closed when the player
+	// disconnects.
+	out    chan protocol.Message
+	closed bool
+	// room is the room that the player is playing in, if any, and index
+	// their place in it. Players leave their room once they're out of the
+	// game.
+	room  *room
+	index int
+
+	targeting protocol.Targeting
+	target    *player
+	board     []string // As of the last piece they placed.
+	kos       int
+	badges    int
+	// lastAttacker is the last player to send them garbage, who gets the
+	// credit if they're knocked out.
+	lastAttacker *player
+	// status is the last Status sent to them, so that it's only sent again
+	// when something changes.
+	status status
+	pieces int // Pieces placed this game.
+	place  int // Where they came in the last game, or zero if still playing.
+	// game is the number of their game in the spectator feed, or zero if it
+	// isn't there.
+	game int
+}
+
+// status holds the fields of a Status message.
+type status struct {
+	target                        int
+	targeting                     protocol.Targeting
+	attackers, alive, kos, badges int
+}
+
+// send queues a message for the player. If they're too far behind, they're
+// disconnected.
+func (p *player) send(m protocol.Message) {
+	if p.closed {
+		return
+	}
+	select {
+	case p.out <- m:
+	default:
+		log.Println(p.addr, "is too far behind, disconnecting")
+		p.close()
+	}
+}
+
+func (p *player) close() {
+	if !p.closed {
+		p.closed = true
+		close(p.out)
+	}
+}
+
+// room is a single game between its players.
+type room struct {
+	players []*player // Everyone who started the game, in or out.
+	alive   int       // How many of them are still in.
+}
+
+// inGame returns whether p is still playing in r.
+func (r *room) inGame(p *player) bool {
+	return p.room == r
+}
+
+// attackers returns the players in the game who are targeting p.
+func (r *room) attackers(p *player) []*player {
+	var attackers []*player
+	for _, q := range r.players {
+		if r.inGame(q) && q.target == p {
+			attackers = append(attackers, q)
+		}
+	}
+	return attackers
+}
+
+// serve talks to a game until it disconnects.
+func (s *server) serve(c conn, addr string) {
+	defer c.Close()
+	hello, err := c.Receive()
+	if err == nil {
+		err = protocol.CheckHello(hello)
+	}
+	if err != nil {
+		log.Println(addr, "failed to say hello:", err)
+		c.Send(protocol.Message{Type: protocol.Error, Error: err.Error()})
+		return
+	}
+	if err := c.Send(protocol.Message{Type: protocol.Welcome, Version: protocol.Version}); err != nil {
+		log.Println(addr, err)
+		return
+	}
+
+	p := &player{
+		name:      cleanName(hello.Name),
+		addr:      addr,
+		out:       make(chan protocol.Message, sendBuffer),
+		targeting: protocol.Targetings[0],
+	}
+	log.Printf("%s connected as %q", addr, p.name)
+	// Messages are sent from their own goroutine so that a slow connection
+	// doesn't hold up anyone else.
+	sent := make(chan struct{})
+	go func() {
+		defer close(sent)
+		for m := range p.out {
+			if err := c.Send(m); err != nil {
+				log.Println(addr, err)
+				c.Close()
+			}
+		}
+	}()
+
+	s.mu.Lock()
+	s.join(p)
+	s.mu.Unlock()
+	for {
+		m, err := c.Receive()
+		if err != nil {
+			log.Println(addr, "disconnected:", err)
+			break
+		}
+		s.mu.Lock()
+		err = s.handle(p, m)
+		s.mu.Unlock()
+		if err != nil {
+			log.Println(addr, err)
+			s.mu.Lock()
+			p.send(protocol.Message{Type: protocol.Error, Error: err.Error()})
+			s.mu.Unlock()
+			break
+		}
+	}
+	s.mu.Lock()
+	s.leave(p)
+	p.close()
+	s.mu.Unlock()
+	// Let any last messages, like errors, go out before disconnecting.
+	<-sent
+}
+
+// cleanName makes a name that can be shown in the game's font.
+func cleanName(name string) string {
+	name = strings.ToUpper(strings.TrimSpace(name))
+	if name == "" {
+		name = "PLAYER"
+	}
+	if len(name) > maxNameLength {
+		name = name[:maxNameLength]
+	}
+	return name
+}
+
+// join puts p in the lobby, and starts a game if that fills a room.
+func (s *server) join(p *player) {
+	s.lobby = append(s.lobby, p)
+	if len(s.lobby) < s.size {
+		s.sendWaiting()
+		return
+	}
+	r := &room{players: s.lobby[:s.size:s.size]}
+	s.lobby = s.lobby[s.size:]
+	var names []string
+	for _, p := range r.players {
+		names = append(names, p.name)
+	}
+	log.Printf("Starting a game between %s", strings.Join(names, ", "))
+	s.start(r)
+}
+
+// sendWaiting tells everyone in the lobby how full the next room is.
+func (s *server) sendWaiting() {
+	for _, p := range s.lobby {
+		p.send(protocol.Message{Type: protocol.Waiting, Players: len(s.lobby), Size: s.size})
+	}
+}
+
+// start begins the game in a new room.
+func (s *server) start(r *room) {
+	seed := s.rng.Int63()
+	for seed == 0 {
+		// Zero means pick a seed at random, so the players would get
+		// different pieces.
+		seed = s.rng.Int63()
+	}
+	var names []string
+	for i, p := range r.players {
+		p.room, p.index = r, i
+		p.target, p.board, p.lastAttacker = nil, nil, nil
+		p.kos, p.badges, p.pieces, p.place = 0, 0, 0, 0
+		p.status = status{}
+		names = append(names, p.name)
+		s.unpublish(p)
+		s.lastGame++
+		p.game = s.lastGame
+		s.publish(p)
+	}
+	r.alive = len(r.players)
+	for _, p := range r.players {
+		p.send(protocol.Message{
+			Type:         protocol.Start,
+			Names:        names,
+			Player:       p.index,
+			Seed:         seed,
+			Width:        s.width,
+			Height:       s.height,
+			BufferHeight: s.bufferHeight,
+		})
+	}
+	s.retarget(r)
+}
+
+// leave takes p out of the lobby, or knocks them out of the game they're in.
+func (s *server) leave(p *player) {
+	for i, q := range s.lobby {
+		if q == p {
+			s.lobby = append(s.lobby[:i], s.lobby[i+1:]...)
+			s.sendWaiting()
+			break
+		}
+	}
+	if p.room != nil {
+		s.knockOut(p)
+	}
+	s.unpublish(p)
+}
+
+// knockOut takes p out of their game, crediting whoever attacked them last.
+// If that leaves one player, they win.
+func (s *server) knockOut(p *player) {
+	r := p.room
+	place := r.alive
+	r.alive--
+	p.room = nil
+	p.place = place
+	s.publish(p)
+	ko := protocol.Message{Type: protocol.KO, Player: p.index, By: -1, Place: place}
+	if by := p.lastAttacker; by != nil && r.inGame(by) {
+		by.kos++
+		// Knocking someone out earns their badges too.
+		by.badges += 1 + p.badges
+		ko.By = by.index
+		s.publish(by)
+	}
+	p.send(protocol.Message{Type: protocol.Result, Place: place, KOs: p.kos})
+	for _, q := range r.players {
+		if r.inGame(q) {
+			q.send(ko)
+		}
+	}
+	if r.alive > 1 {
+		s.retarget(r)
+		return
+	}
+	for _, q := range r.players {
+		if r.inGame(q) {
+			log.Printf("%q won", q.name)
+			q.room = nil
+			q.place = 1
+			s.publish(q)
+			q.send(protocol.Message{Type: protocol.Result, Place: 1, KOs: q.kos, Won: true})
+		}
+	}
+}
+
+// Badge points needed for each level of badges. Every level adds a quarter
+// to the garbage that a player sends.
+var badgeLevels = []int{2, 6, 14, 30}
+
+// withBadges returns rows of garbage with the bonus for p's badges added.
+func withBadges(p *player, rows int) int {
+	level := 0
+	for level < len(badgeLevels) && p.badges >= badgeLevels[level] {
+		level++
+	}
+	return rows + rows*level/4
+}
+
+// retarget picks a target for everyone in the game, and tells them about
+// anything that changed.
+func (s *server) retarget(r *room) {
+	for _, p := range r.players {
+		if !r.inGame(p) {
+			continue
+		}
+		previous := p.target
+		p.target = s.pickTarget(r, p)
+		if p.target != previous && p.target != nil {
+			// Show them their new target's board straight away.
+			p.send(protocol.Message{Type: protocol.Placed, Player: p.target.index, Board: p.target.board})
+		}
+	}
+	for _, p := range r.players {
+		if !r.inGame(p) {
+			continue
+		}
+		st := status{
+			target:    -1,
+			targeting: p.targeting,
+			attackers: len(r.attackers(p)),
+			alive:     r.alive,
+			kos:       p.kos,
+			badges:    p.badges,
+		}
+		if p.target != nil {
+			st.target = p.target.index
+		}
+		if st == p.status {
+			continue
+		}
+		p.status = st
+		p.send(protocol.Message{
+			Type:      protocol.Status,
+			Player:    st.target,
+			Targeting: st.targeting,
+			Attackers: st.attackers,
+			Alive:     st.alive,
+			KOs:       st.kos,
+			Badges:    st.badges,
+		})
+	}
+}
+
+// pickTarget returns who p should attack next using their targeting mode.
+func (s *server) pickTarget(r *room, p *player) *player {
+	var others []*player
+	for _, q := range r.players {
+		if q != p && r.inGame(q) {
+			others = append(others, q)
+		}
+	}
+	if len(others) == 0 {
+		return nil
+	}
+	switch p.targeting {
+	case protocol.Attackers:
+		if attackers := r.attackers(p); len(attackers) > 0 {
+			return s.pickFrom(attackers, p.target)
+		}
+	case protocol.KOs:
+		return s.pickFrom(highest(others, func(q *player) int { return stackHeight(q.board) }), p.target)
+	case protocol.Badges:
+		return s.pickFrom(highest(others, func(q *player) int { return q.badges }), p.target)
+	}
+	return s.pickFrom(others, p.target)
+}
+
+// pickFrom returns current if it's one of candidates, so that targets don't
+// change needlessly, or else one of them at random.
+func (s *server) pickFrom(candidates []*player, current *player) *player {
+	for _, q := range candidates {
+		if q == current {
+			return current
+		}
+	}
+	return candidates[s.rng.Intn(len(candidates))]
+}
+
+// highest returns the players with the highest value.
+func highest(players []*player, value func(*player) int) []*player {
+	var best []*player
+	bestValue := 0
+	for _, p := range players {
+		switch v := value(p); {
+		case len(best) == 0 || v > bestValue:
+			best, bestValue = []*player{p}, v
+		case v == bestValue:
+			best = append(best, p)
+		}
+	}
+	return best
+}
+
+// stackHeight returns the number of rows up to the highest block in a board
+// sent with Placed.
+func stackHeight(board []string) int {
+	for i, row := range board {
+		if strings.Trim(row, ".") != "" {
+			return len(board) - i
+		}
+	}
+	return 0
+}
+
+// handle acts on a message from p. An error means p broke the protocol and
+// should be disconnected.
+func (s *server) handle(p *player, msg protocol.Message) error {
+	r := p.room
+	switch msg.Type {
+	case protocol.Placed:
+		if r == nil {
+			break
+		}
+		p.board = msg.Board
+		p.pieces++
+		s.publish(p)
+		for _, q := range r.attackers(p) {
+			q.send(protocol.Message{Type: protocol.Placed, Player: p.index, Piece: msg.Piece, Board: msg.Board})
+		}
+		// Stacks changed, which matters to anyone targeting KOs.
+		s.retarget(r)
+	case protocol.Attack:
+		if r == nil || msg.Rows <= 0 {
+			break
+		}
+		targets := []*player{p.target}
+		if p.targeting == protocol.Attackers {
+			if attackers := r.attackers(p); len(attackers) > 0 {
+				targets = attackers
+			}
+		}
+		rows := withBadges(p, msg.Rows)
+		for _, t := range targets {
+			if t != nil {
+				t.lastAttacker = p
+				t.send(protocol.Message{Type: protocol.Garbage, Player: p.index, Rows: rows})
+			}
+		}
+		if p.targeting == protocol.Random {
+			// Pick someone new for the next attack.
+			p.target = nil
+		}
+		s.retarget(r)
+	case protocol.Target:
+		if !protocol.ValidTargeting(msg.Targeting) {
+			return fmt.Errorf("unknown targeting %q", msg.Targeting)
+		}
+		p.targeting = msg.Targeting
+		if r != nil {
+			s.retarget(r)
+		}
+	case protocol.Lost:
+		if r != nil {
+			s.knockOut(p)
+		}
+	case protocol.Ready:
+		inLobby := false
+		for _, q := range s.lobby {
+			inLobby = inLobby || q == p
+		}
+		if r == nil && !inLobby {
+			s.join(p)
+		}
+	default:
+		return fmt.Errorf("unexpected message %q", msg.Type)
+	}
+	return nil
+}
@@ -0,0 +1,139 @@
+// Package spectate serves feeds of games to spectators over WebSocket, in the
+// format described in protocol/spectate.go. It's used by tetris-server to
+// show the games it's hosting, and by the desktop games to show themselves.
+package spectate
+
+import (
+	"log"
+	"net"
+	"net/http"
+	"sort"
+	"sync"
+
+	"github.com/omustardo/tetris/tetris-server/protocol"
+	"golang.org/x/net/websocket"
+)
+
+// Path is the path that spectators connect to.
+const Path = "/watch"
+
+// Number of updates that can be waiting to go out to a spectator before
+// they're considered too slow and disconnected.
+const sendBuffer = 1024
+
+// Hub holds the latest frame of every game in a feed, and sends changes to
+// them on to every spectator.
+type Hub struct {
+	mu       sync.Mutex
+	frames   map[int]protocol.Frame
+	watchers map[chan protocol.Update]bool
+}
+
+func NewHub() *Hub {
+	return &Hub{
+		frames:   make(map[int]protocol.Frame),
+		watchers: make(map[chan protocol.Update]bool),
+	}
+}
+
+// Publish sets the latest frame of a game, adding it to the feed if it's new.
+// Spectators are only sent what changed.
+func (h *Hub) Publish(game int, f protocol.Frame) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	old, ok := h.frames[game]
+	h.frames[game] = f
+	u := protocol.Update{Full: &f}
+	if ok {
+		var changed bool
+		if u, changed = protocol.Diff(old, f); !changed {
+			return
+		}
+	}
+	u.Game = game
+	h.send(u)
+}
+
+// Remove takes a game out of the feed.
+func (h *Hub) Remove(game int) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	if _, ok := h.frames[game]; !ok {
+		return
+	}
+	delete(h.frames, game)
+	h.send(protocol.Update{Game: game, Gone: true})
+}
+
+// send queues an update for every spectator. Those who are too far behind are
+// disconnected.
+func (h *Hub) send(u protocol.Update) {
+	for w := range h.watchers {
+		select {
+		case w <- u:
+		default:
+			log.Println("Spectator is too far behind, disconnecting")
+			h.unwatch(w)
+		}
+	}
+}
+
+func (h *Hub) unwatch(w chan protocol.Update) {
+	if h.watchers[w] {
+		delete(h.watchers, w)
+		close(w)
+	}
+}
+
+// ServeHTTP accepts a spectator's WebSocket connection.
+func (h *Hub) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	websocket.Handler(h.watch).ServeHTTP(w, r)
+}
+
+// watch sends the feed to a spectator until they disconnect.
+func (h *Hub) watch(ws *websocket.Conn) {
+	defer ws.Close()
+	updates := make(chan protocol.Update, sendBuffer)
+	h.mu.Lock()
+	var games []int
+	for game := range h.frames {
+		games = append(games, game)
+	}
+	sort.Ints(games)
+	for _, game := range games {
+		f := h.frames[game]
+		updates <- protocol.Update{Game: game, Full: &f}
+	}
+	h.watchers[updates] = true
+	h.mu.Unlock()
+	addr := ws.Request().RemoteAddr
+	log.Println(addr, "is spectating")
+
+	// Spectators don't send anything, so reading only finds out when they
+	// leave.
+	go func() {
+		var discard string
+		for websocket.Message.Receive(ws, &discard) == nil {
+		}
+		h.mu.Lock()
+		h.unwatch(updates)
+		h.mu.Unlock()
+	}()
+	for u := range updates {
+		if err := websocket.JSON.Send(ws, u); err != nil {
+			log.Println(addr, err)
+			break
+		}
+	}
+	h.mu.Lock()
+	h.unwatch(updates)
+	h.mu.Unlock()
+	log.Println(addr, "stopped spectating")
+}
+
+// Serve accepts spectators on l at Path until it fails.
+func (h *Hub) Serve(l net.Listener) error {
+	mux := http.NewServeMux()
+	mux.Handle(Path, h)
+	return http.Serve(l, mux)
+}
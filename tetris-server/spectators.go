@@ -0,0 +1,48 @@
+package main
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/omustardo/tetris/tetris-server/protocol"
+)
+
+// publish shows the latest of p's game to spectators, if the server has any.
+// The server only sees the board after each piece is placed, so that's all
+// that spectators see too.
+func (s *server) publish(p *player) {
+	if s.spectators == nil || p.game == 0 {
+		return
+	}
+	f := protocol.Frame{
+		Name:   p.name,
+		Width:  s.width,
+		Height: s.height,
+		Rows:   p.board,
+		HUD:    []string{p.name, fmt.Sprintf("KOS %d BADGES %d", p.kos, p.badges)},
+		Stats:  protocol.Stats{Pieces: p.pieces},
+	}
+	if len(f.Rows) != s.height {
+		// Nothing placed yet, or a board that doesn't fit.
+		f.Rows = make([]string, s.height)
+		for i := range f.Rows {
+			f.Rows[i] = strings.Repeat(".", s.width)
+		}
+	}
+	if p.place > 0 {
+		f.Over = true
+		f.Results = []string{fmt.Sprintf("PLACE %d", p.place)}
+		if p.place == 1 {
+			f.Results = []string{"WINNER"}
+		}
+	}
+	s.spectators.Publish(p.game, f)
+}
+
+// unpublish takes p's game out of the spectator feed.
+func (s *server) unpublish(p *player) {
+	if s.spectators != nil && p.game != 0 {
+		s.spectators.Remove(p.game)
+	}
+	p.game = 0
+}
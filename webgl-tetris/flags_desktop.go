@@ -0,0 +1,10 @@
+//go:build !js
+// +build !js
+
+package main
+
+// setFlagsFromPage does nothing on desktop, where flags are set on the
+// command line.
+func setFlagsFromPage() error {
+	return nil
+}
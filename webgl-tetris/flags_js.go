@@ -0,0 +1,34 @@
+//go:build js
+// +build js
+
+package main
+
+import (
+	"flag"
+	"net/url"
+	"strings"
+
+	"github.com/gopherjs/gopherjs/js"
+)
+
+// setFlagsFromPage sets flags from the page's query string, since there's no
+// command line in the browser. E.g. serving the game at
+// /?mode=sprint40&seed=5 is like running it with -mode=sprint40 -seed=5.
+func setFlagsFromPage() error {
+	query, err := url.ParseQuery(strings.TrimPrefix(js.Global.Get("location").Get("search").String(), "?"))
+	if err != nil {
+		return err
+	}
+	for name, values := range query {
+		for _, value := range values {
+			if value == "" {
+				// Allow ?versus as well as ?versus=true.
+				value = "true"
+			}
+			if err := flag.Set(name, value); err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
@@ -0,0 +1,11 @@
+//go:build !js
+// +build !js
+
+package main
+
+import "github.com/goxjs/glfw"
+
+// onFocusLost would call f whenever the window loses focus. goxjs/glfw doesn't
+// expose focus callbacks on desktop, so this does nothing there. Use
+// glfw-tetris for a desktop build that pauses on focus loss.
+func onFocusLost(window *glfw.Window, f func()) {}
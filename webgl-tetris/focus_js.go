@@ -0,0 +1,24 @@
+//go:build js
+// +build js
+
+package main
+
+import (
+	"github.com/gopherjs/gopherjs/js"
+	"github.com/goxjs/glfw"
+)
+
+// onFocusLost calls f whenever the page is hidden (e.g. the tab is switched)
+// or the browser window loses focus. It uses the page visibility API since
+// glfw has no focus events in the browser.
+func onFocusLost(window *glfw.Window, f func()) {
+	document := js.Global.Get("document")
+	document.Call("addEventListener", "visibilitychange", func() {
+		if document.Get("hidden").Bool() {
+			f()
+		}
+	})
+	js.Global.Call("addEventListener", "blur", func() {
+		f()
+	})
+}
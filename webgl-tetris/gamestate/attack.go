@@ -0,0 +1,159 @@
+package gamestate
+
+// AttackTable says how many rows of garbage are sent to opponents for each
+// kind of clear. The rows from each part are added together.
+type AttackTable struct {
+	// Lines[n] is sent for clearing n lines at once without a T-spin.
+	Lines []int
+	// TSpin[n] is sent for clearing n lines with a T-spin, instead of Lines.
+	TSpin []int
+	// Combo[n] is added for a clear when the n pieces before it cleared lines
+	// too, so Combo[0] is added for a clear that doesn't carry on a combo.
+	// The last entry is used for any longer combo.
+	Combo []int
+	// BackToBack is added for a tetris or a T-spin clear when the last clear
+	// was also one of those.
+	BackToBack int
+	// PerfectClear is added for a clear that leaves the board empty.
+	PerfectClear int
+}
+
+// DefaultAttackTable is roughly the one used by modern guideline games.
+var DefaultAttackTable = AttackTable{
+	Lines:        []int{0, 0, 1, 2, 4},
+	TSpin:        []int{0, 2, 4, 6},
+	Combo:        []int{0, 1, 1, 2, 2, 3, 3, 4, 4, 4, 5},
+	BackToBack:   1,
+	PerfectClear: 10,
+}
+
+// lookup returns the entry at i in a table, or the last one if i is past the
+// end.
+func lookup(table []int, i int) int {
+	if len(table) == 0 {
+		return 0
+	}
+	if i >= len(table) {
+		i = len(table) - 1
+	}
+	return table[i]
+}
+
+// Attack decides how much garbage a player sends for their clears, and how
+// the garbage they receive is laid out.
+type Attack struct {
+	Table AttackTable
+	// ChangeHole is the chance from 0 to 1 that each row of garbage received
+	// has its hole in a different column from the row below it.
+	ChangeHole float64
+}
+
+// DefaultAttack uses the default table, with holes usually lined up so that
+// garbage can be dug through with tetrises.
+func DefaultAttack() Attack {
+	return Attack{Table: DefaultAttackTable, ChangeHole: 0.3}
+}
+
+// SetAttack changes how garbage is sent and received.
+func (s *State) SetAttack(a Attack) {
+	s.attack = a
+}
+
+// Combo returns the number of pieces in a row, after the first, that have
+// cleared lines. It's -1 if the last piece didn't clear any.
+func (s *State) Combo() int {
+	return s.combo
+}
+
+// BackToBack returns whether the last clear was a tetris or a T-spin, so the
+// next one of those gets a bonus.
+func (s *State) BackToBack() bool {
+	return s.backToBack
+}
+
+// attackFor works out how many rows of garbage to send for a piece that
+// cleared lines, and updates the combo and back to back counters.
+func (s *State) attackFor(lines int, tSpin bool) int {
+	if lines == 0 {
+		s.combo = -1
+		return 0
+	}
+	s.combo++
+	table := s.attack.Table
+	rows := lookup(table.Lines, lines)
+	if tSpin {
+		rows = lookup(table.TSpin, lines)
+	}
+	rows += lookup(table.Combo, s.combo)
+	difficult := lines >= 4 || tSpin
+	if difficult && s.backToBack {
+		rows += table.BackToBack
+	}
+	s.backToBack = difficult
+	if s.BoardEmpty() {
+		rows += table.PerfectClear
+	}
+	return rows
+}
+
+// ReceiveGarbage queues rows of garbage sent by an opponent. They're pushed
+// onto the board the next time a piece locks without clearing any lines,
+// unless they're cancelled out by attacking first.
+func (s *State) ReceiveGarbage(rows int) {
+	if rows > 0 && !s.gameOver {
+		s.record(Record{Type: RecordGarbage, Rows: rows})
+		s.incoming = append(s.incoming, rows)
+	}
+}
+
+// IncomingGarbage returns the number of rows of garbage waiting to be pushed
+// onto the board.
+func (s *State) IncomingGarbage() int {
+	total := 0
+	for _, rows := range s.incoming {
+		total += rows
+	}
+	return total
+}
+
+// cancel uses rows of attack to cancel out incoming garbage, oldest first,
+// and returns how many rows are left over to send.
+func (s *State) cancel(rows int) int {
+	for rows > 0 && len(s.incoming) > 0 {
+		if rows < s.incoming[0] {
+			s.incoming[0] -= rows
+			return 0
+		}
+		rows -= s.incoming[0]
+		s.incoming = s.incoming[1:]
+	}
+	return rows
+}
+
+// pushIncoming adds all of the incoming garbage to the board.
+func (s *State) pushIncoming() {
+	var holes []int
+	for _, rows := range s.incoming {
+		for i := 0; i < rows; i++ {
+			if s.garbageRng.Float64() < s.attack.ChangeHole {
+				s.hole = (s.hole + 1 + s.garbageRng.Intn(s.config.Width-1)) % s.config.Width
+			}
+			holes = append(holes, s.hole)
+		}
+	}
+	s.incoming = nil
+	// The first hole is for the row that ends up on top, which is the last
+	// one received.
+	for i, j := 0, len(holes)-1; i < j; i, j = i+1, j-1 {
+		holes[i], holes[j] = holes[j], holes[i]
+	}
+	s.AddGarbage(holes)
+}
+
+// garbageSeed is mixed into the game's seed for the garbage's random numbers,
+// so that they don't change the sequence of pieces.
+const garbageSeed = 0x6a09e667
+
+func newGarbageRng(seed int64) *Rand {
+	return NewRand(seed ^ garbageSeed)
+}
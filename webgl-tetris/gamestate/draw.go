@@ -0,0 +1,111 @@
+package gamestate
+
+import (
+	"strconv"
+
+	"github.com/omustardo/tetris/webgl-tetris/draw"
+)
+
+// Each line of the HUD above the board takes up this many rows of blocks.
+const hudLineHeight = 1.5
+
+// Draw the game state within the area with its upper left corner at (x,y).
+// Blocks are kept square, so the board is centered along whichever axis has
+// space left over. The hidden rows above the board aren't drawn.
+func (s *State) Draw(x, y, width, height float32) {
+	hud := s.hud()
+	x, y, blockSize := s.boardArea(x, y, width, height, len(hud))
+
+	// Draw the HUD above the board, first line at the top.
+	for i, line := range hud {
+		lineY := y - blockSize*hudLineHeight*(float32(len(hud)-i)-0.5)
+		drawTextCentered(x+blockSize*float32(s.config.Width)/2, lineY, textSize(line, blockSize*float32(s.config.Width), blockSize/5), line, 1, 1, 1, 1)
+	}
+	width = blockSize * float32(s.config.Width)
+	height = blockSize * float32(s.config.Height)
+
+	// Draw bounding box
+	draw.Line(x, y, x+width, y, 0.8, 0.8, 0.8, 1.0)               // top
+	draw.Line(x, y, x, y+height, 0.8, 0.8, 0.8, 1.0)              // left
+	draw.Line(x+width, y, x+width, y+height, 0.8, 0.8, 0.8, 1.0)  // right
+	draw.Line(x, y+height, x+width, y+height, 0.8, 0.8, 0.8, 1.0) // bottom
+
+	if s.paused {
+		// The board is hidden so pausing can't be used to plan ahead.
+		drawTextCentered(x+width/2, y+height/2, textSize("PAUSED", width*0.8, blockSize/2), "PAUSED", 1, 1, 1, 1)
+		return
+	}
+
+	// The board has row 0 at the bottom, but the screen has y=0 at the top.
+	drawBlock := func(col, row int, r, g, b, a float32) {
+		if row >= s.config.Height {
+			return
+		}
+		x1 := x + float32(col)*blockSize
+		y1 := y + height - float32(row+1)*blockSize
+		draw.RectFilled(x1, y1, x1+blockSize, y1+blockSize, r, g, b, a)
+	}
+
+	// Draw all of the stable blocks.
+	for row := 0; row < s.config.Height; row++ {
+		for col := 0; col < s.config.Width; col++ {
+			cell := s.board[row][col]
+			if cell == nil {
+				continue
+			}
+			if v := s.visibility(cell); v > 0 {
+				drawBlock(col, row, cell.R, cell.G, cell.B, cell.A*v)
+			}
+		}
+	}
+
+	// Draw the falling piece.
+	if s.fallingPiece != nil {
+		r, g, b, a := s.fallingPiece.Color()
+		for _, c := range s.cells(s.fallingPiece) {
+			drawBlock(c.col, c.row, r, g, b, a)
+		}
+	}
+
+	if s.countdown > 0 {
+		seconds := strconv.Itoa((s.countdown + TicksPerSecond - 1) / TicksPerSecond)
+		drawTextCentered(x+width/2, y+height/2, blockSize, seconds, 1, 1, 1, 1)
+	}
+
+	if s.gameOver {
+		// Darken the board and list the results over it, centered vertically.
+		draw.RectFilled(x, y, x+width, y+height, 0, 0, 0, 0.7)
+		results := s.results()
+		for i, line := range results {
+			lineY := y + height/2 + blockSize*2*(float32(i)+0.5-float32(len(results))/2)
+			drawTextCentered(x+width/2, lineY, textSize(line, width*0.9, blockSize/4), line, 1, 1, 1, 1)
+		}
+	}
+}
+
+// boardArea returns where Draw puts the board within the area with its upper
+// left corner at (x,y), when there are hudLines lines of HUD above it: the
+// upper left corner of the board, and the size of each block.
+func (s *State) boardArea(x, y, width, height float32, hudLines int) (boardX, boardY, blockSize float32) {
+	hudHeight := hudLineHeight * float32(hudLines)
+	rows := float32(s.config.Height) + hudHeight
+	blockSize = width / float32(s.config.Width)
+	if size := height / rows; size < blockSize {
+		blockSize = size
+	}
+	boardX = x + (width-blockSize*float32(s.config.Width))/2
+	boardY = y + (height-blockSize*rows)/2 + blockSize*hudHeight
+	return boardX, boardY, blockSize
+}
+
+// drawTextCentered draws text centered on (x,y), with each pixel of the font
+// taking up a size x size square.
+func drawTextCentered(x, y, size float32, text string, r, g, b, a float32) {
+	left := x - float32(textWidth(text))*size/2
+	top := y - float32(glyphHeight)*size/2
+	textPixels(text, func(px, py int) {
+		x1 := left + float32(px)*size
+		y1 := top + float32(py)*size
+		draw.RectFilled(x1, y1, x1+size, y1+size, r, g, b, a)
+	})
+}
@@ -0,0 +1,94 @@
+package gamestate
+
+import "unicode"
+
+// A tiny pixel font so text can be drawn with nothing but filled rectangles.
+// Each glyph is glyphWidth x glyphHeight pixels with rows listed top to bottom.
+// Lowercase letters are drawn as uppercase and unknown characters as spaces.
+const (
+	glyphWidth  = 3
+	glyphHeight = 5
+)
+
+var glyphs = map[rune][glyphHeight]string{
+	'0': {"###", "# #", "# #", "# #", "###"},
+	'1': {" # ", "## ", " # ", " # ", "###"},
+	'2': {"###", "  #", "###", "#  ", "###"},
+	'3': {"###", "  #", "###", "  #", "###"},
+	'4': {"# #", "# #", "###", "  #", "  #"},
+	'5': {"###", "#  ", "###", "  #", "###"},
+	'6': {"###", "#  ", "###", "# #", "###"},
+	'7': {"###", "  #", "  #", "  #", "  #"},
+	'8': {"###", "# #", "###", "# #", "###"},
+	'9': {"###", "# #", "###", "  #", "###"},
+	'A': {"###", "# #", "###", "# #", "# #"},
+	'B': {"## ", "# #", "## ", "# #", "## "},
+	'C': {"###", "#  ", "#  ", "#  ", "###"},
+	'D': {"## ", "# #", "# #", "# #", "## "},
+	'E': {"###", "#  ", "## ", "#  ", "###"},
+	'F': {"###", "#  ", "## ", "#  ", "#  "},
+	'G': {"###", "#  ", "# #", "# #", "###"},
+	'H': {"# #", "# #", "###", "# #", "# #"},
+	'I': {"###", " # ", " # ", " # ", "###"},
+	'J': {"  #", "  #", "  #", "# #", "###"},
+	'K': {"# #", "# #", "## ", "# #", "# #"},
+	'L': {"#  ", "#  ", "#  ", "#  ", "###"},
+	'M': {"# #", "###", "###", "# #", "# #"},
+	'N': {"## ", "# #", "# #", "# #", "# #"},
+	'O': {"###", "# #", "# #", "# #", "###"},
+	'P': {"###", "# #", "###", "#  ", "#  "},
+	'Q': {"###", "# #", "# #", "###", "  #"},
+	'R': {"## ", "# #", "## ", "# #", "# #"},
+	'S': {"###", "#  ", "###", "  #", "###"},
+	'T': {"###", " # ", " # ", " # ", " # "},
+	'U': {"# #", "# #", "# #", "# #", "###"},
+	'V': {"# #", "# #", "# #", "# #", " # "},
+	'W': {"# #", "# #", "###", "###", "# #"},
+	'X': {"# #", "# #", " # ", "# #", "# #"},
+	'Y': {"# #", "# #", " # ", " # ", " # "},
+	'Z': {"###", "  #", " # ", "#  ", "###"},
+	':': {"   ", " # ", "   ", " # ", "   "},
+	'.': {"   ", "   ", "   ", "   ", " # "},
+	'-': {"   ", "   ", "###", "   ", "   "},
+	'+': {"   ", " # ", "###", " # ", "   "},
+	'/': {"  #", "  #", " # ", "#  ", "#  "},
+	'%': {"# #", "  #", " # ", "#  ", "# #"},
+	'!': {" # ", " # ", " # ", "   ", " # "},
+}
+
+// textWidth returns the width of text in font pixels. Characters are separated
+// by a single blank pixel.
+func textWidth(text string) int {
+	n := len([]rune(text))
+	if n == 0 {
+		return 0
+	}
+	return n*(glyphWidth+1) - 1
+}
+
+// textPixels calls f with the position of each lit pixel in text, where (0,0)
+// is the top left pixel of the first character and y increases downward.
+func textPixels(text string, f func(x, y int)) {
+	for i, c := range []rune(text) {
+		glyph, ok := glyphs[unicode.ToUpper(c)]
+		if !ok {
+			continue
+		}
+		for y, row := range glyph {
+			for x, p := range row {
+				if p == '#' {
+					f(i*(glyphWidth+1)+x, y)
+				}
+			}
+		}
+	}
+}
+
+// textSize returns the largest font pixel size, no more than maxSize, at
+// which text fits within width.
+func textSize(text string, width, maxSize float32) float32 {
+	if w := textWidth(text); w > 0 && width/float32(w) < maxSize {
+		return width / float32(w)
+	}
+	return maxSize
+}
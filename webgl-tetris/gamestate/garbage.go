@@ -0,0 +1,77 @@
+package gamestate
+
+import (
+	"log"
+
+	"github.com/omustardo/tetris/webgl-tetris/tetronimoes"
+)
+
+// AddGarbage pushes rows of garbage onto the bottom of the board, moving
+// everything else up. Each row is full apart from one empty column, given by
+// holes. The first row ends up on top.
+// The falling piece is pushed up along with the board if it's in the way. If
+// it can't be, or if blocks are pushed out of the top of the hidden rows,
+// then the game is over.
+func (s *State) AddGarbage(holes []int) {
+	if s.gameOver || len(holes) == 0 {
+		return
+	}
+	n := len(holes)
+	if n > len(s.board) {
+		n = len(s.board)
+	}
+	overflow := false
+	for _, row := range s.board[len(s.board)-n:] {
+		if !empty(row) {
+			overflow = true
+		}
+	}
+
+	copy(s.board[n:], s.board[:len(s.board)-n])
+	r, g, b, a := tetronimoes.GarbageColor()
+	for i, hole := range holes[:n] {
+		row := make([]*block, s.config.Width)
+		for col := range row {
+			if col != hole {
+				row[col] = &block{R: r, G: g, B: b, A: a, garbage: true, lockedAt: s.ticks}
+			}
+		}
+		s.board[n-1-i] = row
+	}
+
+	if s.fallingPiece != nil {
+		origin := s.fallingPiece.Origin()
+		for i := 0; i < n && s.BoardIntersects(s.fallingPiece); i++ {
+			origin.Y++
+		}
+		if s.BoardIntersects(s.fallingPiece) {
+			log.Println("Garbage pushed into the falling piece -> game over")
+			s.topOut()
+			return
+		}
+	}
+	if overflow {
+		log.Println("Garbage pushed blocks off the top of the board -> game over")
+		s.topOut()
+	}
+}
+
+// GarbageRows returns the number of rows on the board that contain garbage.
+func (s *State) GarbageRows() int {
+	count := 0
+	for _, row := range s.board {
+		if hasGarbage(row) {
+			count++
+		}
+	}
+	return count
+}
+
+func hasGarbage(row []*block) bool {
+	for _, b := range row {
+		if b != nil && b.garbage {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,105 @@
+package gamestate
+
+import "github.com/omustardo/tetris/webgl-tetris/keyboard"
+
+// Input is what a player asked for in one tick. Each field is set on the tick
+// that its button is pressed, not while it's held down.
+type Input struct {
+	Left, Right                             bool
+	RotateClockwise, RotateCounterClockwise bool
+	HardDrop                                bool
+	// SoftDrop drops the piece as far as it goes without locking it, so that
+	// it can still be moved or turned into a gap under the stack.
+	SoftDrop       bool
+	Pause, Restart bool
+	// Practice controls, which do nothing outside of practice.
+	Undo, Cycle, Swap bool
+	// Target picks a targeting mode in online battles, counting from 1 in the
+	// order of protocol.Targetings. Zero keeps the current one.
+	Target int
+}
+
+// inputBits lists the buttons of an Input in the order of their bits.
+var inputBits = []func(*Input) *bool{
+	func(in *Input) *bool { return &in.Left },
+	func(in *Input) *bool { return &in.Right },
+	func(in *Input) *bool { return &in.RotateClockwise },
+	func(in *Input) *bool { return &in.RotateCounterClockwise },
+	func(in *Input) *bool { return &in.HardDrop },
+	func(in *Input) *bool { return &in.Pause },
+	func(in *Input) *bool { return &in.Restart },
+	func(in *Input) *bool { return &in.Undo },
+	func(in *Input) *bool { return &in.Cycle },
+	func(in *Input) *bool { return &in.Swap },
+	// Buttons added since Target was packed in go above it, so that inputs
+	// packed before them unpack the same. See bitFor.
+	func(in *Input) *bool { return &in.SoftDrop },
+}
+
+// Target is packed in above the first targetShift buttons, in targetBits
+// bits.
+const (
+	targetShift = 10
+	targetBits  = 3
+)
+
+// bitFor returns the bit that the i'th of inputBits is packed into.
+func bitFor(i int) uint {
+	if i < targetShift {
+		return uint(i)
+	}
+	return uint(i + targetBits)
+}
+
+// Bits packs the input into a number, with a bit for each button and Target
+// shifted in above the first of them, for compact encodings. See
+// InputFromBits.
+func (in Input) Bits() int {
+	n := in.Target << targetShift
+	for i, bit := range inputBits {
+		if *bit(&in) {
+			n |= 1 << bitFor(i)
+		}
+	}
+	return n
+}
+
+// InputFromBits unpacks an input packed by Bits.
+func InputFromBits(n int) Input {
+	in := Input{Target: n >> targetShift & (1<<targetBits - 1)}
+	for i, bit := range inputBits {
+		*bit(&in) = n&(1<<bitFor(i)) != 0
+	}
+	return in
+}
+
+// KeyboardInput returns the keys that were just pressed by a player using the
+// given bindings to move their piece. The keys to pause, restart and practice
+// are shared by every player.
+func KeyboardInput(h *keyboard.Handler, b keyboard.Bindings) Input {
+	return Input{
+		Left:                   h.IsKeyDown(b.Left) && !h.WasKeyDown(b.Left),
+		Right:                  h.IsKeyDown(b.Right) && !h.WasKeyDown(b.Right),
+		RotateClockwise:        h.IsKeyDown(b.RotateClockwise) && !h.WasKeyDown(b.RotateClockwise),
+		RotateCounterClockwise: h.IsKeyDown(b.RotateCounterClockwise) && !h.WasKeyDown(b.RotateCounterClockwise),
+		HardDrop:               h.IsKeyDown(b.HardDrop) && !h.WasKeyDown(b.HardDrop),
+		SoftDrop:               h.IsKeyDown(b.SoftDrop) && !h.WasKeyDown(b.SoftDrop),
+		Pause:                  h.PausePressed() && !h.WasPausePressed(),
+		Restart:                h.RestartPressed() && !h.WasRestartPressed(),
+		Undo:                   h.UndoPressed() && !h.WasUndoPressed(),
+		Cycle:                  h.CyclePressed() && !h.WasCyclePressed(),
+		Swap:                   h.SwapPressed() && !h.WasSwapPressed(),
+		Target:                 pressedTarget(h),
+	}
+}
+
+// pressedTarget returns which of keyboard.TargetKeys was just pressed,
+// counting from 1, or zero if none were.
+func pressedTarget(h *keyboard.Handler) int {
+	for i, key := range keyboard.TargetKeys {
+		if h.IsKeyDown(key) && !h.WasKeyDown(key) {
+			return i + 1
+		}
+	}
+	return 0
+}
@@ -0,0 +1,28 @@
+package gamestate
+
+import (
+	"math"
+
+	"github.com/omustardo/tetris/webgl-tetris/mouse"
+)
+
+// ApplyMouse paints blocks onto the board while practicing: the left button
+// fills the cell under the cursor and the right button empties it. The area
+// is the one passed to Draw, with its upper left corner at (x,y).
+func (s *State) ApplyMouse(mouseHandler *mouse.Handler, x, y, width, height float32) {
+	if !s.practice || s.Paused() {
+		return
+	}
+	left, right := mouseHandler.LeftPressed(), mouseHandler.RightPressed()
+	if !left && !right {
+		return
+	}
+	boardX, boardY, blockSize := s.boardArea(x, y, width, height, len(s.hud()))
+	// The board has row 0 at the bottom.
+	col := int(math.Floor(float64((float32(mouseHandler.X) - boardX) / blockSize)))
+	row := s.config.Height - 1 - int(math.Floor(float64((float32(mouseHandler.Y)-boardY)/blockSize)))
+	if col < 0 || col >= s.config.Width || row < 0 || row >= s.config.Height {
+		return
+	}
+	s.Paint(col, row, left)
+}
@@ -0,0 +1,167 @@
+package gamestate
+
+import (
+	"fmt"
+	"sort"
+
+	"github.com/omustardo/tetris/webgl-tetris/tetronimoes"
+)
+
+// Move is somewhere that the falling piece can lock, and the fewest keys to
+// press to get it there.
+type Move struct {
+	// Cells holds the cells covered by the piece once it locks, each as its
+	// column and row counted from the bottom left, like Board.PieceCells.
+	Cells [][2]int
+	// TSpin is whether the piece locks as a T-spin.
+	TSpin bool
+	// Inputs are the keys to press, one a tick, ending with a hard drop.
+	Inputs []Input
+}
+
+// Moves returns every place that the falling piece can lock from where it is
+// now, including those that it can only reach by soft dropping it and then
+// tucking it under the stack or spinning it into a slot. Places where a T
+// locks both with and without a T-spin are returned once for each. Gravity
+// is left out, as if every key was pressed before the piece fell any
+// further, so with fast gravity and no lock delay some moves need quicker
+// hands than others.
+func (s *State) Moves() []Move {
+	if s.fallingPiece == nil || s.gameOver {
+		return nil
+	}
+	var moves []Move
+	for _, p := range s.reachable(s.grid(), s.fallingPiece, s.lastRotated) {
+		m := Move{TSpin: p.tSpin, Inputs: p.inputs}
+		for _, c := range p.cells {
+			m.Cells = append(m.Cells, [2]int{c.col, c.row})
+		}
+		moves = append(moves, m)
+	}
+	return moves
+}
+
+// pose is where a piece is on its way down: the column and row of its
+// origin, how many times it's been turned clockwise, and whether the last
+// thing it did was turn.
+type pose struct {
+	col, row, turns int
+	spun            bool
+}
+
+// reachable returns every place on g that a piece can lock, by trying every
+// key in turn from every place that the piece can get to, fewest keys first.
+// spun is whether the last thing the piece did was turn.
+func (s *State) reachable(g grid, piece *tetronimoes.Shape, spun bool) []placement {
+	// The cells covered by the piece at an origin of 0, 0 for each way
+	// it can be turned.
+	var shapes [4][]cell
+	shape := piece.Copy()
+	*shape.Origin() = tetronimoes.Point{}
+	for turns := range shapes {
+		shapes[turns] = s.cells(shape)
+		shape.RotateClockwise()
+	}
+	at := func(p pose) []cell {
+		cells := make([]cell, len(shapes[p.turns]))
+		for i, c := range shapes[p.turns] {
+			cells[i] = cell{col: c.col + p.col, row: c.row + p.row}
+		}
+		return cells
+	}
+	scale := s.scale()
+	// drop returns where a piece falls to from p.
+	drop := func(p pose) pose {
+		for {
+			p.row -= scale
+			if !g.fits(at(p)) {
+				p.row += scale
+				return p
+			}
+			p.spun = false
+		}
+	}
+
+	type step struct {
+		pose  pose
+		from  int   // Index of the step that this one was reached from, or -1.
+		input Input // The key pressed to get here from there.
+	}
+	start := pose{col: int(piece.Origin().X), row: int(piece.Origin().Y), spun: spun}
+	if !g.fits(at(start)) {
+		return nil
+	}
+	steps := []step{{pose: start, from: -1}}
+	seen := map[pose]bool{start: true}
+	locked := make(map[string]bool)
+	var found []placement
+	for i := 0; i < len(steps); i++ {
+		p := steps[i].pose
+
+		// Drop the piece from here and see if it locks somewhere new.
+		landed := drop(p)
+		cells := at(landed)
+		tSpin := piece.Kind() == tetronimoes.T && landed.spun && s.cornersBlocked(g, landed) >= 3
+		if key := lockKey(cells, tSpin); !locked[key] {
+			locked[key] = true
+			var inputs []Input
+			for j := i; steps[j].from >= 0; j = steps[j].from {
+				inputs = append(inputs, steps[j].input)
+			}
+			for l, r := 0, len(inputs)-1; l < r; l, r = l+1, r-1 {
+				inputs[l], inputs[r] = inputs[r], inputs[l]
+			}
+			found = append(found, placement{cells: cells, inputs: append(inputs, Input{HardDrop: true}), tSpin: tSpin})
+		}
+
+		// Then try each key from here, in the order that Apply handles them.
+		next := []step{
+			{pose: pose{p.col, p.row, (p.turns + 3) % 4, true}, input: Input{RotateCounterClockwise: true}},
+			{pose: pose{p.col, p.row, (p.turns + 1) % 4, true}, input: Input{RotateClockwise: true}},
+			{pose: pose{p.col - scale, p.row, p.turns, false}, input: Input{Left: true}},
+			{pose: pose{p.col + scale, p.row, p.turns, false}, input: Input{Right: true}},
+		}
+		if landed.row != p.row {
+			next = append(next, step{pose: landed, input: Input{SoftDrop: true}})
+		}
+		for _, n := range next {
+			if piece.Kind() != tetronimoes.T {
+				// Only a T cares whether it turned last.
+				n.pose.spun = false
+			}
+			if seen[n.pose] || !g.fits(at(n.pose)) {
+				continue
+			}
+			seen[n.pose] = true
+			steps = append(steps, step{pose: n.pose, from: i, input: n.input})
+		}
+	}
+	return found
+}
+
+// cornersBlocked returns how many of the four corners around the center of a
+// T at p are walls, floor or filled, in the same way as isTSpin.
+func (s *State) cornersBlocked(g grid, p pose) int {
+	blocked := 0
+	for _, corner := range []cell{{0, 0}, {2, 0}, {0, 2}, {2, 2}} {
+		col := p.col + corner.col*s.scale()
+		row := p.row + corner.row*s.scale()
+		if row >= len(g) || g.filled(col, row) {
+			blocked++
+		}
+	}
+	return blocked
+}
+
+// lockKey returns a key that's the same for pieces that lock on the same
+// cells in the same way, whichever way round they're turned.
+func lockKey(cells []cell, tSpin bool) string {
+	sorted := append([]cell(nil), cells...)
+	sort.Slice(sorted, func(i, j int) bool {
+		if sorted[i].row != sorted[j].row {
+			return sorted[i].row < sorted[j].row
+		}
+		return sorted[i].col < sorted[j].col
+	})
+	return fmt.Sprint(sorted, tSpin)
+}
@@ -0,0 +1,157 @@
+package gamestate
+
+import "github.com/omustardo/tetris/webgl-tetris/tetronimoes"
+
+// snapshot is a copy of everything needed to go back to the moment a piece
+// spawned.
+type snapshot struct {
+	board [][]*block
+	piece tetronimoes.Kind // The piece that had just spawned.
+	queue []tetronimoes.Kind
+	stats Stats
+	score int
+	// combo and backToBack are the counters for attacks.
+	combo      int
+	backToBack bool
+}
+
+// SetPractice turns practice features on or off: undoing placements,
+// reordering the upcoming pieces and painting blocks onto the board. They're
+// off by default.
+func (s *State) SetPractice(on bool) {
+	s.practice = on
+	s.history = nil
+	if on && s.fallingPiece != nil {
+		s.remember()
+	}
+}
+
+// Practice returns whether practice features are on.
+func (s *State) Practice() bool {
+	return s.practice
+}
+
+// copyBoard returns a copy of board that can be changed without affecting
+// the original. Blocks are never changed once they're on the board, so they
+// can be shared.
+func copyBoard(board [][]*block) [][]*block {
+	c := make([][]*block, len(board))
+	for row := range board {
+		c[row] = append([]*block(nil), board[row]...)
+	}
+	return c
+}
+
+// remember adds the game as it is now, just after a piece spawned, to the
+// history that Undo goes back through.
+func (s *State) remember() {
+	s.history = append(s.history, snapshot{
+		board: copyBoard(s.board),
+		piece: s.fallingPiece.Kind(),
+		queue: append([]tetronimoes.Kind(nil), s.queue...),
+		stats: s.stats,
+		score: s.score,
+
+		combo:      s.combo,
+		backToBack: s.backToBack,
+	})
+}
+
+// restore goes back to a snapshot and spawns its piece again.
+func (s *State) restore(snap snapshot) {
+	s.board = copyBoard(snap.board)
+	s.queue = append([]tetronimoes.Kind{snap.piece}, snap.queue...)
+	s.stats = snap.stats
+	s.score = snap.score
+	s.combo, s.backToBack = snap.combo, snap.backToBack
+	s.gameOver, s.toppedOut = false, false
+	s.fallingPiece = nil
+	s.areTimer = 0
+	s.spawn()
+}
+
+// Undo takes back the last piece placed, putting it back at the top of the
+// board. It only works in practice, and can go back as far as the start of
+// the game, including after topping out.
+func (s *State) Undo() {
+	if !s.practice || len(s.history) == 0 {
+		return
+	}
+	// While a piece is falling, the last snapshot is of it, and it hasn't
+	// been placed yet. Otherwise the last snapshot is of the piece placed
+	// most recently.
+	falling := s.fallingPiece != nil && !s.gameOver
+	if falling {
+		s.history = s.history[:len(s.history)-1]
+	}
+	if len(s.history) == 0 {
+		// There's nothing before the first piece.
+		if falling {
+			s.remember()
+		}
+		return
+	}
+	snap := s.history[len(s.history)-1]
+	s.history = s.history[:len(s.history)-1]
+	s.restore(snap)
+}
+
+// CycleQueue moves the falling piece to the back of the known upcoming pieces
+// and brings in the next one. It only works in practice.
+func (s *State) CycleQueue() {
+	if !s.practice || s.fallingPiece == nil || s.gameOver {
+		return
+	}
+	s.fillQueue()
+	s.queue = append(s.queue, s.fallingPiece.Kind())
+	s.respawn()
+}
+
+// SwapNext swaps the falling piece with the next one. It only works in
+// practice.
+func (s *State) SwapNext() {
+	if !s.practice || s.fallingPiece == nil || s.gameOver {
+		return
+	}
+	s.fillQueue()
+	next := s.queue[0]
+	s.queue[0] = s.fallingPiece.Kind()
+	s.queue = append([]tetronimoes.Kind{next}, s.queue...)
+	s.respawn()
+}
+
+// respawn replaces the falling piece with the next one in the queue, as if
+// it had only just spawned.
+func (s *State) respawn() {
+	if len(s.history) > 0 {
+		s.history = s.history[:len(s.history)-1]
+	}
+	s.fallingPiece = nil
+	s.spawn()
+}
+
+// Paint fills a cell of the board with a plain block, or empties it. It only
+// works in practice, and not on cells covered by the falling piece.
+func (s *State) Paint(col, row int, fill bool) {
+	if !s.practice || s.gameOver || col < 0 || col >= s.config.Width || row < 0 || row >= len(s.board) {
+		return
+	}
+	if s.fallingPiece != nil {
+		for _, c := range s.cells(s.fallingPiece) {
+			if c.col == col && c.row == row {
+				return
+			}
+		}
+	}
+	// Painting is done every tick that the mouse button is held, so leave
+	// cells that are already painted alone rather than recording it again.
+	if b := s.board[row][col]; (fill && b != nil && b.kind == 0 && !b.garbage) || (!fill && b == nil) {
+		return
+	}
+	s.record(Record{Type: RecordPaint, Col: col, Row: row, Fill: fill})
+	if fill {
+		s.SetCell(col, row, 0)
+	} else {
+		s.ClearCell(col, row)
+	}
+}
@@ -0,0 +1,52 @@
+package gamestate
+
+import "math/rand"
+
+// Rand generates random numbers for a game. Unlike a plain *rand.Rand it can
+// be copied, which is done by starting again from the same seed and drawing
+// as many numbers as the original has, so copies carry on with the same
+// numbers.
+type Rand struct {
+	*rand.Rand
+	source *countingSource
+}
+
+// countingSource counts the numbers drawn from a source.
+type countingSource struct {
+	rand.Source
+	seed  int64
+	drawn int
+}
+
+func (s *countingSource) Int63() int64 {
+	s.drawn++
+	return s.Source.Int63()
+}
+
+// NewRand creates a generator with the given seed.
+func NewRand(seed int64) *Rand {
+	source := &countingSource{Source: rand.NewSource(seed), seed: seed}
+	return &Rand{Rand: rand.New(source), source: source}
+}
+
+// NewRandAt creates a generator that picks up where one with the given seed
+// would be after drawn numbers had been drawn from it.
+func NewRandAt(seed int64, drawn int) *Rand {
+	r := NewRand(seed)
+	for i := 0; i < drawn; i++ {
+		r.source.Int63()
+	}
+	return r
+}
+
+// Position returns the seed r started from and the number of numbers drawn
+// from it since, which NewRandAt takes to carry on from the same place.
+func (r *Rand) Position() (seed int64, drawn int) {
+	return r.source.seed, r.source.drawn
+}
+
+// Copy returns a generator that gives the same numbers from now on as r, but
+// separately from it.
+func (r *Rand) Copy() *Rand {
+	return NewRandAt(r.Position())
+}
@@ -46,6 +46,28 @@ func (keyEventList *glfwKeyEventList) Callback(w *glfw.Window, key glfw.Key, sca
   *keyEventList = append(*keyEventList, event)
 }
 
+// Bindings are the keys that move a player's piece, so that more than one
+// player can share a keyboard.
+type Bindings struct {
+  Left, Right                             glfw.Key
+  RotateClockwise, RotateCounterClockwise glfw.Key
+  HardDrop, SoftDrop                      glfw.Key
+}
+
+var (
+  // SinglePlayer uses the arrow keys, with space to drop and right shift to
+  // soft drop.
+  SinglePlayer = Bindings{Left: glfw.KeyLeft, Right: glfw.KeyRight, RotateClockwise: glfw.KeyDown, RotateCounterClockwise: glfw.KeyUp, HardDrop: glfw.KeySpace, SoftDrop: glfw.KeyRightShift}
+  // Player1 and Player2 split the keyboard in two for versus games: WASD
+  // with space to drop and left shift to soft drop, and the arrow keys with
+  // enter to drop and right shift to soft drop.
+  Player1 = Bindings{Left: glfw.KeyA, Right: glfw.KeyD, RotateClockwise: glfw.KeyS, RotateCounterClockwise: glfw.KeyW, HardDrop: glfw.KeySpace, SoftDrop: glfw.KeyLeftShift}
+  Player2 = Bindings{Left: glfw.KeyLeft, Right: glfw.KeyRight, RotateClockwise: glfw.KeyDown, RotateCounterClockwise: glfw.KeyUp, HardDrop: glfw.KeyEnter, SoftDrop: glfw.KeyRightShift}
+  // TargetKeys pick a targeting mode in online battles, in the order of
+  // protocol.Targetings.
+  TargetKeys = []glfw.Key{glfw.Key1, glfw.Key2, glfw.Key3, glfw.Key4}
+)
+
 type Handler struct {
   // State maps from keys to whether they are pressed.
   State         map[glfw.Key]bool
@@ -126,6 +148,21 @@ func (h *Handler) DownPressed() bool {
 func (h *Handler) SpacePressed() bool {
   return h.State[glfw.KeySpace]
 }
+func (h *Handler) PausePressed() bool {
+  return h.State[glfw.KeyP] || h.State[glfw.KeyEscape]
+}
+func (h *Handler) RestartPressed() bool {
+  return h.State[glfw.KeyR]
+}
+func (h *Handler) UndoPressed() bool {
+  return h.State[glfw.KeyZ] || h.State[glfw.KeyBackspace]
+}
+func (h *Handler) CyclePressed() bool {
+  return h.State[glfw.KeyC]
+}
+func (h *Handler) SwapPressed() bool {
+  return h.State[glfw.KeyX]
+}
 
 func (h *Handler) WasKeyDown(key glfw.Key) bool {
   return h.PreviousState[key]
@@ -145,6 +182,21 @@ func (h *Handler) WasDownPressed() bool {
 func (h *Handler) WasSpacePressed() bool {
   return h.PreviousState[glfw.KeySpace]
 }
+func (h *Handler) WasPausePressed() bool {
+  return h.PreviousState[glfw.KeyP] || h.PreviousState[glfw.KeyEscape]
+}
+func (h *Handler) WasRestartPressed() bool {
+  return h.PreviousState[glfw.KeyR]
+}
+func (h *Handler) WasUndoPressed() bool {
+  return h.PreviousState[glfw.KeyZ] || h.PreviousState[glfw.KeyBackspace]
+}
+func (h *Handler) WasCyclePressed() bool {
+  return h.PreviousState[glfw.KeyC]
+}
+func (h *Handler) WasSwapPressed() bool {
+  return h.PreviousState[glfw.KeyX]
+}
 
 // String prints out all of the currently pressed keys in human readable format.
 // TODO: Currently casts the keycode to a character. This works for standard
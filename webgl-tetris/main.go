@@ -3,13 +3,24 @@ package main
 import (
 	"flag"
 	"fmt"
+	"log"
+	"math"
+	"strings"
 	"time"
 
 	"github.com/goxjs/gl"
 	"github.com/goxjs/glfw"
 	"github.com/omustardo/tetris/webgl-tetris/draw"
+	"github.com/omustardo/tetris/webgl-tetris/fumen"
 	"github.com/omustardo/tetris/webgl-tetris/gamestate"
 	"github.com/omustardo/tetris/webgl-tetris/keyboard"
+	"github.com/omustardo/tetris/webgl-tetris/modes"
+	"github.com/omustardo/tetris/webgl-tetris/mouse"
+	"github.com/omustardo/tetris/webgl-tetris/netplay"
+	"github.com/omustardo/tetris/webgl-tetris/replay"
+	"github.com/omustardo/tetris/webgl-tetris/savegame"
+	"github.com/omustardo/tetris/webgl-tetris/spectator"
+	"github.com/omustardo/tetris/webgl-tetris/versus"
 
 	"github.com/goxjs/gl/glutil"
 )
@@ -17,11 +28,31 @@ import (
 var (
 	windowWidth  = flag.Int("window_width", 500, "initial window width")
 	windowHeight = flag.Int("window_height", 1000, "initial window height")
+	boardWidth   = flag.Int("board_width", gamestate.DefaultWidth, "number of columns in the board")
+	boardHeight  = flag.Int("board_height", gamestate.DefaultHeight, "number of visible rows in the board")
+	bufferHeight = flag.Int("buffer_height", gamestate.DefaultBufferHeight, "number of hidden rows above the board where pieces spawn")
+	mode         = flag.String("mode", "", "game mode, one of: "+strings.Join(modes.Names(), ", ")+". Leave empty for an endless game.")
+	seed         = flag.Int64("seed", 0, "seed for the pieces and garbage, so games can be replayed or raced. 0 picks one at random.")
+	messiness    = flag.Float64("messiness", modes.DefaultMessiness, "for dig modes, the chance from 0 to 1 that each garbage row's hole moves")
+	fumenSetup   = flag.String("fumen", "", "for zen mode, a setup to practice from as shared by fumen editors, like v115@vhAAgH. Its first page's board is used, followed by the pieces placed in it.")
+	puzzlePath   = flag.String("puzzle", "", "for the puzzle mode, a puzzle file or a directory holding a pack of them. Leave empty for the puzzles that come with the game.")
+	big          = flag.Bool("big", false, "make every piece twice the size, like playing on a board half as wide and high")
+	invisible    = flag.Bool("invisible", false, "hide blocks as soon as they lock, until the game is over")
+	fadeSeconds  = flag.Float64("fade_seconds", 0, "if positive, blocks fade out this many seconds after they lock, until the game is over")
+	versusGame   = flag.Bool("versus", false, "play a two player game on split boards, where clearing lines sends garbage to the other player")
+	serverAddr   = flag.String("server", "", "address of a tetris-server to play online against someone else: a WebSocket URL like ws://localhost:7778/play in the browser, or like localhost:7777 on desktop")
+	playerName   = flag.String("name", "PLAYER", "name to show to your opponent when playing online")
+	spectateAddr = flag.String("spectate", "", "address to serve this game to spectators on, like localhost:7780, on desktop only. They watch at ws://localhost:7780/watch.")
+	watchAddr    = flag.String("watch", "", "WebSocket URL of games to watch instead of playing, from -spectate or a tetris-server, like ws://localhost:7780/watch")
+	replayPath   = flag.String("replay", "", "replay file to play back instead of playing, from -record, or in the browser the name of one saved in the page's local storage")
+	resume       = flag.Bool("resume", true, "offer to carry on the single player game that was quit partway through last time, if it's the same mode on the same size of board")
+	watchBot     = flag.Bool("bot", false, "let the built-in bot play the single player game, to watch it play")
+	botDelay     = flag.Int("bot_delay", 6, "with -bot, ticks that the bot waits between key presses. Higher is slower.")
+	recordDir    = flag.String("record", "", "directory to save a replay of each game into once it's over, or in the browser any value to save them in the page's local storage. Leave empty to not record games.")
 )
 
 const (
-	gametick     = time.Second / 3
-	framerate    = time.Second / 60
+	framerate    = time.Second / gamestate.TicksPerSecond
 	vertexSource = `//#version 120 // OpenGL 2.1.
 //#version 100 // WebGL.
 attribute vec2 aVertexPosition;
@@ -43,6 +74,10 @@ void main() {
 )
 
 func main() {
+	flag.Parse()
+	if err := setFlagsFromPage(); err != nil {
+		panic(err)
+	}
 	err := glfw.Init(gl.ContextWatcher)
 	if err != nil {
 		panic(err)
@@ -52,7 +87,12 @@ func main() {
 
 	// Note when running on WebGL, the actual width and height parameters are ignored in
 	// favor of the browser window dimensions.
-	window, err := glfw.CreateWindow(*windowWidth, *windowHeight, "Tetris", nil, nil)
+	width := *windowWidth
+	if *versusGame || *serverAddr != "" || *watchAddr != "" {
+		// Room for two boards side by side.
+		width *= 2
+	}
+	window, err := glfw.CreateWindow(width, *windowHeight, "Tetris", nil, nil)
 	if err != nil {
 		panic(err)
 	}
@@ -102,44 +142,293 @@ func main() {
 		return
 	}
 
-	state := gamestate.NewState()
+	config := gamestate.Config{Width: *boardWidth, Height: *boardHeight, BufferHeight: *bufferHeight, Big: *big, Seed: *seed}
+	if err := config.Validate(); err != nil {
+		panic(err)
+	}
+	// Exactly one of state, match, client, watcher and player is set,
+	// depending on whether it's a single player, versus or online game, or
+	// other games are being watched, or a replay is being played back.
+	var state *gamestate.State
+	var match *versus.Match
+	var client *netplay.Client
+	var watcher *spectator.Client
+	var player *replay.Player
+	// options are those of the single player game's mode, and resumed is
+	// whether that game was saved last time and is being carried on.
+	options := modes.Options{Messiness: *messiness}
+	resumed := false
+	// paused is whether the single player game was paused on the last frame,
+	// so that practice positions can be logged as they're paused on.
+	paused := false
+	// bot plays the single player game in place of the keyboard, with -bot.
+	var bot *gamestate.Bot
+	if *watchBot {
+		if *botDelay < 0 {
+			panic("-bot_delay can't be negative")
+		}
+		bot = gamestate.NewBot(*botDelay)
+	}
+	switch {
+	case *watchAddr != "":
+		if *mode != "" || *versusGame || *serverAddr != "" || *spectateAddr != "" || *recordDir != "" || *replayPath != "" {
+			panic("-mode, -versus, -server, -spectate, -record and -replay can't be used with -watch")
+		}
+		w, err := spectator.Watch(*watchAddr)
+		if err != nil {
+			panic(err)
+		}
+		defer w.Close()
+		watcher = w
+	case *replayPath != "":
+		if *mode != "" || *versusGame || *serverAddr != "" || *spectateAddr != "" || *recordDir != "" {
+			panic("-mode, -versus, -server, -spectate and -record can't be used with -replay")
+		}
+		r, err := replay.Load(*replayPath)
+		if err != nil {
+			panic(err)
+		}
+		p, err := r.NewPlayer()
+		if err != nil {
+			panic(err)
+		}
+		player = p
+	case *serverAddr != "":
+		if *mode != "" || *versusGame {
+			panic("-mode and -versus can't be used with -server")
+		}
+		c, err := netplay.Connect(*serverAddr, *playerName)
+		if err != nil {
+			panic(err)
+		}
+		defer c.Close()
+		client = c
+	case *versusGame:
+		if *mode != "" {
+			panic("-mode can't be used with -versus")
+		}
+		match = versus.New(config)
+		fmt.Println("Seed:", match.Seed())
+		for _, p := range match.Players {
+			setFade(p)
+		}
+	default:
+		state = gamestate.NewState(config)
+		fmt.Println("Seed:", state.Seed())
+		if *puzzlePath != "" {
+			puzzles, err := modes.LoadPuzzles(*puzzlePath)
+			if err != nil {
+				panic(err)
+			}
+			options.Puzzles = puzzles
+		}
+		if *fumenSetup != "" {
+			if *mode != "zen" {
+				panic("-fumen only works with -mode=zen")
+			}
+			setup, err := modes.FumenSetup(*fumenSetup)
+			if err != nil {
+				panic(err)
+			}
+			options.Setup = setup
+		}
+		gameMode, err := modes.New(*mode, options)
+		if err != nil {
+			panic(err)
+		}
+		state.SetMode(gameMode)
+		setFade(state)
+		if *resume {
+			if s, o, ok := savegame.Offer(*mode, options.Setup, config); ok {
+				state, options, resumed = s, o, true
+			}
+		}
+	}
+	var publisher *spectator.Publisher
+	if *spectateAddr != "" {
+		p, err := spectator.Publish(*spectateAddr)
+		if err != nil {
+			panic(err)
+		}
+		publisher = p
+	}
+	var recorder *replay.Recorder
+	if *recordDir != "" {
+		recorder = replay.NewRecorder(*recordDir, *mode, options)
+		switch {
+		case client != nil:
+			client.Record()
+		case match != nil:
+			match.Record()
+		case !resumed:
+			// Resumed games can only be recorded if they were from the
+			// start, in which case they still are.
+			state.Record()
+		}
+	}
 	keyboardHandler, callback := keyboard.NewHandler()
 	window.SetKeyCallback(callback)
+	mouseHandler, buttonCallback, cursorCallback := mouse.NewHandler()
+	window.SetMouseButtonCallback(buttonCallback)
+	window.SetCursorPosCallback(cursorCallback)
+	// Pause when the game is hidden so it doesn't carry on unattended. Pages
+	// that are hidden may never be shown again, so single player games are
+	// saved too.
+	onFocusLost(window, func() {
+		switch {
+		case client != nil || watcher != nil || player != nil:
+			// Online games can't be paused, and watched games and replays
+			// aren't ours to pause.
+		case match != nil:
+			match.Pause()
+		default:
+			state.Pause()
+			saveGame(state, options)
+		}
+	})
 
 	ticker := time.NewTicker(framerate)
-	blockFallTicker := time.NewTicker(gametick)
 	for !window.ShouldClose() {
 		// Read input
 		keyboardHandler.Update()
-		state.ApplyInputs(keyboardHandler)
-		select {
-		case _, ok := <-blockFallTicker.C: // a new block falls every gametick
-			if ok {
-				state.Step()
-			}
+		mouseHandler.Update()
+		w, h := float32(draw.WindowSize[0]), float32(draw.WindowSize[1])
+		switch {
+		case watcher != nil:
+			watcher.Update()
+		case player != nil:
+			player.Apply(gamestate.KeyboardInput(keyboardHandler, keyboard.SinglePlayer))
+			player.Tick()
+		case client != nil:
+			client.Update()
+			client.Apply(gamestate.KeyboardInput(keyboardHandler, keyboard.SinglePlayer))
+			client.Tick()
+		case match != nil:
+			// The players split the keyboard between them.
+			match.Apply([2]gamestate.Input{
+				gamestate.KeyboardInput(keyboardHandler, keyboard.Player1),
+				gamestate.KeyboardInput(keyboardHandler, keyboard.Player2),
+			})
+			match.Tick()
 		default:
+			if bot != nil {
+				state.Apply(bot.Watched(state, gamestate.KeyboardInput(keyboardHandler, keyboard.SinglePlayer)))
+			} else {
+				state.ApplyInputs(keyboardHandler)
+			}
+			state.ApplyMouse(mouseHandler, 0, 0, w, h)
+			state.Tick()
+			if resumed && !state.Paused() {
+				// The saved game has been carried on, so stop offering it.
+				state.SetCaption()
+				resumed = false
+			}
+			if state.Paused() && !paused && state.Practice() {
+				logPosition(state)
+			}
+			paused = state.Paused()
+		}
+		names, games := playing(state, match, client)
+		for i, game := range games {
+			if publisher != nil {
+				publisher.Show(i+1, names[i], game)
+			}
+			if recorder != nil {
+				recorder.Update(names[i], game)
+			}
 		}
 
 		// Draw
 		gl.Clear(gl.COLOR_BUFFER_BIT | gl.DEPTH_BUFFER_BIT)
 
-		// Expect a 1:2 width:height ratio. If it's different, adjust the draw area.
-		width := float32(draw.WindowSize[0])
-		height := float32(draw.WindowSize[1])
 		switch {
-		case height > width*2:
-			// Window is too tall: draw board filling the width and at the top of the window.
-			state.Draw(0, 0, width, width*2)
-		case height < width*2:
-			// Window is too wide: draw board in the center & filling full height
-			newWidth := height / 2
-			state.Draw((width-newWidth)/2, 0, height/2, height)
+		case watcher != nil:
+			drawGrid(watcher.Games(), w, h)
+		case player != nil:
+			player.State().Draw(0, 0, w, h)
+		case client != nil:
+			drawSideBySide(client.Players(), w, h)
+		case match != nil:
+			drawSideBySide(match.Players, w, h)
 		default:
-			state.Draw(0, 0, width, height)
+			state.Draw(0, 0, w, h)
 		}
 
 		window.SwapBuffers()
 		glfw.PollEvents()
 		<-ticker.C // wait up to 1/60th of a second
 	}
+	if state != nil {
+		saveGame(state, options)
+	}
+}
+
+// drawSideBySide splits the window in two, with the first game on the left.
+func drawSideBySide(games [2]*gamestate.State, w, h float32) {
+	drawGrid(games[:], w, h)
+}
+
+// drawGrid splits the window into a grid with a cell for each game, filling
+// rows from the top left. Boards are taller than they are wide, so there are
+// about twice as many columns as rows.
+func drawGrid(games []*gamestate.State, w, h float32) {
+	cols := int(math.Ceil(math.Sqrt(float64(2 * len(games)))))
+	if cols > len(games) {
+		cols = len(games)
+	}
+	rows := (len(games) + cols - 1) / cols
+	cellWidth, cellHeight := w/float32(cols), h/float32(rows)
+	for i, game := range games {
+		row, col := i/cols, i%cols
+		game.Draw(float32(col)*cellWidth, float32(row)*cellHeight, cellWidth, cellHeight)
+	}
+}
+
+// playing returns the games being played, or shown, in the window, with the
+// names of their players.
+func playing(state *gamestate.State, match *versus.Match, client *netplay.Client) ([]string, []*gamestate.State) {
+	switch {
+	case client != nil:
+		return []string{*playerName, "OPPONENT"}, []*gamestate.State{client.Local, client.Remote}
+	case match != nil:
+		return []string{"PLAYER 1", "PLAYER 2"}, match.Players[:]
+	case state != nil:
+		return []string{*playerName}, []*gamestate.State{state}
+	}
+	return nil, nil
+}
+
+// logPosition logs the board and falling piece of a game as a fumen, so that
+// positions can be shared and set up again with -fumen.
+func logPosition(state *gamestate.State) {
+	page, err := fumen.FromState(state)
+	if err != nil {
+		log.Println("Error writing position as fumen:", err)
+		return
+	}
+	text, err := fumen.Encode([]*fumen.Page{page})
+	if err != nil {
+		log.Println("Error writing position as fumen:", err)
+		return
+	}
+	log.Println("Position:", text)
+}
+
+// saveGame keeps a single player game to carry on next time, unless it's
+// over.
+func saveGame(state *gamestate.State, options modes.Options) {
+	if err := savegame.Save(*mode, options, state); err != nil {
+		log.Println("Error saving game:", err)
+	}
+}
+
+// setFade makes blocks disappear after they lock, as set by the -invisible
+// and -fade_seconds flags.
+func setFade(state *gamestate.State) {
+	switch {
+	case *invisible:
+		state.SetFade(&gamestate.Fade{})
+	case *fadeSeconds > 0:
+		state.SetFade(&gamestate.Fade{After: int(*fadeSeconds * gamestate.TicksPerSecond), Over: gamestate.TicksPerSecond})
+	}
 }
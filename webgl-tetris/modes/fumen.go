@@ -0,0 +1,20 @@
+package modes
+
+import "github.com/omustardo/tetris/webgl-tetris/fumen"
+
+// FumenSetup reads a setup shared as a fumen, as a puzzle without a goal. Its
+// board is that of the fumen's first page, and its pieces are those placed on
+// each page, in order. It's named after the first page's comment.
+func FumenSetup(text string) (*Puzzle, error) {
+	pages, err := fumen.Decode(text)
+	if err != nil {
+		return nil, err
+	}
+	p := &Puzzle{Name: pages[0].Comment, Board: pages[0].Board}
+	for _, page := range pages {
+		if page.Piece != nil {
+			p.Pieces = append(p.Pieces, page.Piece.Kind)
+		}
+	}
+	return p, nil
+}
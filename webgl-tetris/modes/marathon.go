@@ -0,0 +1,127 @@
+package modes
+
+import (
+	"fmt"
+
+	"github.com/omustardo/tetris/webgl-tetris/gamestate"
+)
+
+// linesPerLevel is the number of lines to clear to go up a level in Marathon.
+const linesPerLevel = 10
+
+// marathonLockDelay is the number of ticks a piece can rest on the stack
+// before it locks in Marathon, half a second as in the guideline. Without it,
+// once pieces fall a row or more each tick they'd lock as soon as they land.
+const marathonLockDelay = 30
+
+// marathonGravity is the speed pieces fall at on each level, starting at level
+// 1. Levels past the end of the list use the last entry. It roughly follows
+// the curve from the Tetris guideline, which starts at one row a second and
+// ends with pieces dropping instantly.
+var marathonGravity = []gamestate.Gravity{
+	{Rows: 1, Ticks: 60},
+	{Rows: 1, Ticks: 48},
+	{Rows: 1, Ticks: 37},
+	{Rows: 1, Ticks: 28},
+	{Rows: 1, Ticks: 21},
+	{Rows: 1, Ticks: 16},
+	{Rows: 1, Ticks: 11},
+	{Rows: 1, Ticks: 8},
+	{Rows: 1, Ticks: 6},
+	{Rows: 1, Ticks: 4},
+	{Rows: 1, Ticks: 3},
+	{Rows: 1, Ticks: 2},
+	{Rows: 1, Ticks: 1},
+	{Rows: 100, Ticks: 69},
+	{Rows: 100, Ticks: 42},
+	{Rows: 100, Ticks: 26},
+	{Rows: 100, Ticks: 15},
+	{Rows: 100, Ticks: 9},
+	{Rows: 100, Ticks: 5},
+	{Rows: 20, Ticks: 1},
+}
+
+// Marathon is won by clearing a number of lines. The game speeds up every
+// linesPerLevel lines, and the goal is to score as many points as possible
+// along the way.
+type Marathon struct {
+	name  string
+	lines int // Number of lines to clear.
+
+	// Filled in once the game is over.
+	previous    int // The personal best score before this game.
+	hadPrevious bool
+	newBest     bool
+}
+
+func NewMarathon(lines int) *Marathon {
+	return &Marathon{name: fmt.Sprintf("marathon%d", lines), lines: lines}
+}
+
+func (m *Marathon) Start(s *gamestate.State) {
+	*m = Marathon{name: m.name, lines: m.lines}
+	s.SetLockDelay(marathonLockDelay)
+	m.setLevel(s, 1)
+}
+
+func (m *Marathon) setLevel(s *gamestate.State, level int) {
+	s.SetLevel(level)
+	if level > len(marathonGravity) {
+		level = len(marathonGravity)
+	}
+	s.SetGravity(marathonGravity[level-1])
+}
+
+func (m *Marathon) Copy() gamestate.Mode {
+	c := *m
+	return &c
+}
+
+func (m *Marathon) Tick(s *gamestate.State) {}
+
+func (m *Marathon) HandleEvent(s *gamestate.State, e gamestate.Event) {
+	switch e.Type {
+	case gamestate.LinesCleared:
+		lines := s.Stats().Lines
+		if lines >= m.lines {
+			s.End()
+			return
+		}
+		if level := 1 + lines/linesPerLevel; level != s.Level() {
+			m.setLevel(s, level)
+		}
+	case gamestate.GameEnded:
+		// Topping out still counts: the score so far is kept.
+		m.previous, m.hadPrevious, m.newBest = submit(s, m.name, s.Score(), false)
+	}
+}
+
+func (m *Marathon) HUD(s *gamestate.State) []string {
+	return []string{
+		fmt.Sprintf("SCORE %d", s.Score()),
+		fmt.Sprintf("LEVEL %d  LINES %d/%d", s.Level(), s.Stats().Lines, m.lines),
+	}
+}
+
+func (m *Marathon) Results(s *gamestate.State) []string {
+	stats := s.Stats()
+	results := []string{fmt.Sprintf("MARATHON %d", m.lines)}
+	if s.ToppedOut() {
+		results = append(results, "TOPPED OUT")
+	} else {
+		results = append(results, "COMPLETE!")
+	}
+	results = append(results,
+		fmt.Sprintf("SCORE %d", s.Score()),
+		fmt.Sprintf("LEVEL %d", s.Level()),
+		fmt.Sprintf("LINES %d", stats.Lines),
+		"TIME "+gamestate.FormatTicks(stats.Ticks),
+	)
+	switch {
+	case m.newBest:
+		results = append(results, "NEW BEST!")
+	case m.hadPrevious:
+		results = append(results, fmt.Sprintf("BEST %d", m.previous))
+	}
+	return results
+}
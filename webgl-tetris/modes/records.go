@@ -0,0 +1,77 @@
+package modes
+
+import (
+	"encoding/json"
+	"log"
+
+	"github.com/omustardo/tetris/webgl-tetris/gamestate"
+	"github.com/omustardo/tetris/webgl-tetris/storage"
+)
+
+// recordsFile is the name the records are saved under.
+const recordsFile = "records.json"
+
+// records maps the name of a mode, like "sprint40", to the player's personal
+// best in it. What the number means depends on the mode.
+type records map[string]int
+
+// loadRecords reads the saved personal bests. Errors are logged rather than
+// returned since a missing or broken record shouldn't stop anyone playing.
+func loadRecords() records {
+	r := records{}
+	data, err := storage.Load(recordsFile)
+	if err != nil {
+		log.Println("Error loading personal bests:", err)
+		return r
+	}
+	if data == nil {
+		return r
+	}
+	if err := json.Unmarshal(data, &r); err != nil {
+		log.Println("Error reading personal bests:", err)
+	}
+	return r
+}
+
+func (r records) save() {
+	data, err := json.Marshal(r)
+	if err != nil {
+		log.Println("Error encoding personal bests:", err)
+		return
+	}
+	if err := storage.Save(recordsFile, data); err != nil {
+		log.Println("Error saving personal bests:", err)
+	}
+}
+
+// submit saves value as the record for name if it beats the existing one.
+// It returns the previous record, if there was one, and whether value beat it.
+// Games being played back from a replay, played by a bot or played on a board
+// other than the default one never set a record, since records are only kept
+// by mode.
+func submit(s *gamestate.State, name string, value int, lowerIsBetter bool) (previous int, hadPrevious, improved bool) {
+	r := loadRecords()
+	previous, hadPrevious = r[name]
+	if s.PlayingBack() || s.BotPlayed() || !defaultBoard(s.Config()) {
+		return previous, hadPrevious, false
+	}
+	improved = !hadPrevious || (lowerIsBetter && value < previous) || (!lowerIsBetter && value > previous)
+	if improved {
+		r[name] = value
+		r.save()
+	}
+	return previous, hadPrevious, improved
+}
+
+// defaultBoard returns whether a game with the given config is played on the
+// default board. Its seed doesn't matter.
+func defaultBoard(c gamestate.Config) bool {
+	c.Seed = 0
+	return c == gamestate.DefaultConfig()
+}
+
+// best returns the record for name, if there is one.
+func best(name string) (int, bool) {
+	value, ok := loadRecords()[name]
+	return value, ok
+}
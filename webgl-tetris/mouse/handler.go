@@ -0,0 +1,65 @@
+// Wrapper class to handle mouse interaction with a glfw window, or the canvas
+// when running in a browser.
+// Keeps track of the buttons and cursor position between frames, like the
+// keyboard handler does for keys.
+
+package mouse
+
+import "github.com/goxjs/glfw"
+
+type Handler struct {
+	// State maps from buttons to whether they are pressed.
+	State         map[glfw.MouseButton]bool
+	PreviousState map[glfw.MouseButton]bool
+	// Position of the cursor in screen coordinates, with (0,0) at the top left
+	// of the window.
+	X, Y float64
+
+	// Button states as reported by glfw, copied into State by Update.
+	latest map[glfw.MouseButton]bool
+}
+
+// NewHandler returns a handler along with the callbacks for glfw to call when
+// a button is pressed or the cursor moves.
+func NewHandler() (*Handler, glfw.MouseButtonCallback, glfw.CursorPosCallback) {
+	h := &Handler{
+		State:         make(map[glfw.MouseButton]bool),
+		PreviousState: make(map[glfw.MouseButton]bool),
+		latest:        make(map[glfw.MouseButton]bool),
+	}
+	buttonCallback := func(w *glfw.Window, button glfw.MouseButton, action glfw.Action, mods glfw.ModifierKey) {
+		switch action {
+		case glfw.Press:
+			h.latest[button] = true
+		case glfw.Release:
+			h.latest[button] = false
+		}
+	}
+	cursorCallback := func(w *glfw.Window, x, y float64) {
+		h.X, h.Y = x, y
+	}
+	return h, buttonCallback, cursorCallback
+}
+
+// Update is expected to be called once per frame, along with the keyboard
+// handler's Update.
+func (h *Handler) Update() {
+	h.PreviousState, h.State = h.State, make(map[glfw.MouseButton]bool)
+	for button, pressed := range h.latest {
+		h.State[button] = pressed
+	}
+}
+
+func (h *Handler) LeftPressed() bool {
+	return h.State[glfw.MouseButtonLeft]
+}
+func (h *Handler) RightPressed() bool {
+	return h.State[glfw.MouseButtonRight]
+}
+
+func (h *Handler) WasLeftPressed() bool {
+	return h.PreviousState[glfw.MouseButtonLeft]
+}
+func (h *Handler) WasRightPressed() bool {
+	return h.PreviousState[glfw.MouseButtonRight]
+}
@@ -0,0 +1,95 @@
+//go:build js
+// +build js
+
+package netplay
+
+import (
+	"errors"
+	"io"
+
+	"github.com/gopherjs/gopherjs/js"
+	"github.com/omustardo/tetris/tetris-server/protocol"
+)
+
+// Number of messages that can arrive before the game reads them.
+const receiveBuffer = 1024
+
+// wsConn is a browser WebSocket, sending each message in its own text frame.
+type wsConn struct {
+	ws *js.Object
+	// Messages sent before the connection opens wait in pending.
+	open    bool
+	pending []string
+	// received gets each message as it arrives, and is closed along with the
+	// connection.
+	received chan protocol.Message
+	err      error
+}
+
+// dial connects to a server over WebSocket, at an address like
+// "ws://localhost:7778/play".
+func dial(addr string) (conn, error) {
+	if js.Global.Get("WebSocket") == js.Undefined {
+		return nil, errors.New("WebSocket is not available")
+	}
+	c := &wsConn{
+		ws:       js.Global.Get("WebSocket").New(addr),
+		received: make(chan protocol.Message, receiveBuffer),
+	}
+	c.ws.Call("addEventListener", "open", func() {
+		c.open = true
+		for _, data := range c.pending {
+			c.ws.Call("send", data)
+		}
+		c.pending = nil
+	})
+	c.ws.Call("addEventListener", "message", func(event *js.Object) {
+		m, err := protocol.Decode([]byte(event.Get("data").String()))
+		if err != nil {
+			c.err = err
+			c.ws.Call("close")
+			return
+		}
+		// Callbacks from JavaScript mustn't block, so if messages aren't
+		// being read fast enough the connection is given up on.
+		select {
+		case c.received <- m:
+		default:
+			c.err = errors.New("too many messages waiting to be read")
+			c.ws.Call("close")
+		}
+	})
+	c.ws.Call("addEventListener", "close", func() {
+		close(c.received)
+	})
+	return c, nil
+}
+
+func (c *wsConn) Send(m protocol.Message) error {
+	data, err := protocol.Encode(m)
+	if err != nil {
+		return err
+	}
+	if !c.open {
+		c.pending = append(c.pending, string(data))
+		return nil
+	}
+	c.ws.Call("send", string(data))
+	return nil
+}
+
+func (c *wsConn) Receive() (protocol.Message, error) {
+	m, ok := <-c.received
+	if !ok {
+		if c.err != nil {
+			return protocol.Message{}, c.err
+		}
+		return protocol.Message{}, io.EOF
+	}
+	return m, nil
+}
+
+func (c *wsConn) Close() error {
+	c.ws.Call("close")
+	return nil
+}
@@ -0,0 +1,292 @@
+package replay
+
+import (
+	"bytes"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"io"
+	"math"
+	"strings"
+	"time"
+	"unicode"
+
+	"github.com/omustardo/tetris/webgl-tetris/gamestate"
+	"github.com/omustardo/tetris/webgl-tetris/modes"
+	"github.com/omustardo/tetris/webgl-tetris/tetronimoes"
+)
+
+// Replay files start with magic and then the version of their format. The
+// rest is made of unsigned varints, except where noted, in this order:
+//
+//	name, mode: strings, as their length followed by their bytes
+//	messiness: float64 bits, 8 bytes little endian
+//	width, height, buffer height, big (0 or 1), seed (signed varint)
+//	fade: 0, or 1 followed by its after and over ticks
+//	puzzle: 0, or 1 followed by its name, goal type, goal N, pieces as a
+//	  string of their letters, and board as a count of rows followed by each
+//	  row as a string
+//	score, lines, pieces, ticks
+//	frames, number of records, and then each record as the number of frames
+//	  since the one before, its type and then whatever its type holds:
+//	  input: its Bits
+//	  garbage: rows
+//	  paint: column, row, fill (0 or 1)
+//	  pause, resume, end: nothing
+const (
+	magic = "TETRISREPLAY"
+	// Version of the format written. Older versions can still be read.
+	Version = 1
+)
+
+// Ext is the extension of replay files.
+const Ext = ".replay"
+
+// fileName returns a name for the replay's file, made of when it was saved
+// and who played.
+func (r *Replay) fileName() string {
+	name := time.Now().Format("20060102-150405.000")
+	if r.Name != "" {
+		// Names are chosen by players, so keep only what's safe in a file
+		// name.
+		name += "-" + strings.Map(func(c rune) rune {
+			if unicode.IsLetter(c) || unicode.IsDigit(c) {
+				return unicode.ToLower(c)
+			}
+			return '-'
+		}, r.Name)
+	}
+	return name + Ext
+}
+
+// Marshal encodes the replay in the format described above.
+func (r *Replay) Marshal() []byte {
+	w := &writer{}
+	w.WriteString(magic)
+	w.uint(Version)
+
+	w.string(r.Name)
+	w.string(r.Mode)
+	var messiness [8]byte
+	binary.LittleEndian.PutUint64(messiness[:], math.Float64bits(r.Messiness))
+	w.Write(messiness[:])
+
+	c := r.Config
+	w.uint(c.Width)
+	w.uint(c.Height)
+	w.uint(c.BufferHeight)
+	w.bool(c.Big)
+	w.int(c.Seed)
+
+	w.bool(r.Fade != nil)
+	if r.Fade != nil {
+		w.uint(r.Fade.After)
+		w.uint(r.Fade.Over)
+	}
+
+	w.bool(r.Puzzle != nil)
+	if p := r.Puzzle; p != nil {
+		w.string(p.Name)
+		w.uint(int(p.Goal.Type))
+		w.uint(p.Goal.N)
+		pieces := make([]byte, len(p.Pieces))
+		for i, kind := range p.Pieces {
+			pieces[i] = byte(kind)
+		}
+		w.string(string(pieces))
+		w.uint(len(p.Board))
+		for _, row := range p.Board {
+			w.string(row)
+		}
+	}
+
+	w.uint(r.Result.Score)
+	w.uint(r.Result.Lines)
+	w.uint(r.Result.Pieces)
+	w.uint(r.Result.Ticks)
+
+	w.uint(r.Recording.Frames)
+	w.uint(len(r.Recording.Records))
+	frame := 0
+	for _, rec := range r.Recording.Records {
+		w.uint(rec.Frame - frame)
+		frame = rec.Frame
+		w.uint(int(rec.Type))
+		switch rec.Type {
+		case gamestate.RecordInput:
+			w.uint(rec.Input.Bits())
+		case gamestate.RecordGarbage:
+			w.uint(rec.Rows)
+		case gamestate.RecordPaint:
+			w.uint(rec.Col)
+			w.uint(rec.Row)
+			w.bool(rec.Fill)
+		}
+	}
+	return w.Bytes()
+}
+
+// Unmarshal decodes a replay written by Marshal.
+func Unmarshal(data []byte) (*Replay, error) {
+	if !bytes.HasPrefix(data, []byte(magic)) {
+		return nil, errors.New("not a replay file")
+	}
+	rd := &reader{Reader: bytes.NewReader(data[len(magic):])}
+	if v := rd.uint(); rd.err == nil && (v < 1 || v > Version) {
+		return nil, fmt.Errorf("unsupported replay version %d, expected at most %d", v, Version)
+	}
+
+	r := &Replay{}
+	r.Name = rd.string()
+	r.Mode = rd.string()
+	var messiness [8]byte
+	if _, err := io.ReadFull(rd, messiness[:]); err != nil && rd.err == nil {
+		rd.err = err
+	}
+	r.Messiness = math.Float64frombits(binary.LittleEndian.Uint64(messiness[:]))
+
+	r.Config.Width = rd.uint()
+	r.Config.Height = rd.uint()
+	r.Config.BufferHeight = rd.uint()
+	r.Config.Big = rd.bool()
+	r.Config.Seed = rd.int()
+
+	if rd.bool() {
+		r.Fade = &gamestate.Fade{After: rd.uint(), Over: rd.uint()}
+	}
+
+	if rd.bool() {
+		p := &modes.Puzzle{Name: rd.string()}
+		p.Goal.Type = modes.GoalType(rd.uint())
+		p.Goal.N = rd.uint()
+		for _, c := range []byte(rd.string()) {
+			p.Pieces = append(p.Pieces, tetronimoes.Kind(c))
+		}
+		for n := rd.count(); n > 0; n-- {
+			p.Board = append(p.Board, rd.string())
+		}
+		r.Puzzle = p
+	}
+
+	r.Result.Score = rd.uint()
+	r.Result.Lines = rd.uint()
+	r.Result.Pieces = rd.uint()
+	r.Result.Ticks = rd.uint()
+
+	r.Recording.Frames = rd.uint()
+	frame := 0
+	for n := rd.count(); n > 0 && rd.err == nil; n-- {
+		frame += rd.uint()
+		rec := gamestate.Record{Frame: frame, Type: gamestate.RecordType(rd.uint())}
+		switch rec.Type {
+		case gamestate.RecordInput:
+			rec.Input = gamestate.InputFromBits(rd.uint())
+		case gamestate.RecordGarbage:
+			rec.Rows = rd.uint()
+		case gamestate.RecordPaint:
+			rec.Col = rd.uint()
+			rec.Row = rd.uint()
+			rec.Fill = rd.bool()
+		case gamestate.RecordPause, gamestate.RecordResume, gamestate.RecordEnd:
+		default:
+			return nil, fmt.Errorf("unknown record type %d", rec.Type)
+		}
+		r.Recording.Records = append(r.Recording.Records, rec)
+	}
+	if rd.err != nil {
+		return nil, fmt.Errorf("bad replay: %v", rd.err)
+	}
+	if frame > r.Recording.Frames {
+		return nil, fmt.Errorf("bad replay: record on frame %d of %d", frame, r.Recording.Frames)
+	}
+	return r, nil
+}
+
+type writer struct {
+	bytes.Buffer
+}
+
+func (w *writer) uint(n int) {
+	var buf [binary.MaxVarintLen64]byte
+	w.Write(buf[:binary.PutUvarint(buf[:], uint64(n))])
+}
+
+func (w *writer) int(n int64) {
+	var buf [binary.MaxVarintLen64]byte
+	w.Write(buf[:binary.PutVarint(buf[:], n)])
+}
+
+func (w *writer) bool(b bool) {
+	if b {
+		w.WriteByte(1)
+	} else {
+		w.WriteByte(0)
+	}
+}
+
+func (w *writer) string(s string) {
+	w.uint(len(s))
+	w.WriteString(s)
+}
+
+// reader reads what writer writes. After the first error it reads only
+// zeros, and the error is kept in err.
+type reader struct {
+	*bytes.Reader
+	err error
+}
+
+func (r *reader) uint() int {
+	if r.err != nil {
+		return 0
+	}
+	n, err := binary.ReadUvarint(r)
+	if err == nil && n > math.MaxInt32 {
+		err = fmt.Errorf("number %d is too large", n)
+	}
+	if err != nil {
+		r.err = err
+		return 0
+	}
+	return int(n)
+}
+
+func (r *reader) int() int64 {
+	if r.err != nil {
+		return 0
+	}
+	n, err := binary.ReadVarint(r)
+	if err != nil {
+		r.err = err
+	}
+	return n
+}
+
+func (r *reader) bool() bool {
+	return r.uint() != 0
+}
+
+// count reads the number of things that follow, each of which takes at least
+// a byte.
+func (r *reader) count() int {
+	n := r.uint()
+	if n > r.Len() {
+		if r.err == nil {
+			r.err = fmt.Errorf("count %d is more than the %d bytes left", n, r.Len())
+		}
+		return 0
+	}
+	return n
+}
+
+func (r *reader) string() string {
+	n := r.count()
+	if n == 0 {
+		return ""
+	}
+	b := make([]byte, n)
+	if _, err := io.ReadFull(r, b); err != nil && r.err == nil {
+		r.err = err
+	}
+	return string(b)
+}
@@ -0,0 +1,27 @@
+//go:build !js
+// +build !js
+
+package replay
+
+import (
+	"os"
+	"path/filepath"
+)
+
+// Save writes the replay into a new file in dir, and returns the file's path.
+func (r *Replay) Save(dir string) (string, error) {
+	if err := os.MkdirAll(dir, 0755); err != nil {
+		return "", err
+	}
+	p := filepath.Join(dir, r.fileName())
+	return p, os.WriteFile(p, r.Marshal(), 0644)
+}
+
+// Load reads a replay file.
+func Load(p string) (*Replay, error) {
+	data, err := os.ReadFile(p)
+	if err != nil {
+		return nil, err
+	}
+	return Unmarshal(data)
+}
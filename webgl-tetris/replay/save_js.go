@@ -0,0 +1,33 @@
+//go:build js
+// +build js
+
+package replay
+
+import (
+	"errors"
+
+	"github.com/omustardo/tetris/webgl-tetris/storage"
+)
+
+// storagePrefix is put in front of the names of replays in the browser's
+// storage, which stands in for the directory they're saved in.
+const storagePrefix = "replays/"
+
+// Save stores the replay in the browser's local storage, since pages can't
+// write files, and returns the name it's stored under. dir is ignored.
+func (r *Replay) Save(dir string) (string, error) {
+	name := storagePrefix + r.fileName()
+	return name, storage.Save(name, r.Marshal())
+}
+
+// Load reads a replay stored by Save, by the name that Save returned.
+func Load(name string) (*Replay, error) {
+	data, err := storage.Load(name)
+	if err != nil {
+		return nil, err
+	}
+	if data == nil {
+		return nil, errors.New("no replay saved as " + name)
+	}
+	return Unmarshal(data)
+}
@@ -0,0 +1,70 @@
+//go:build js
+// +build js
+
+package spectator
+
+import (
+	"encoding/json"
+	"errors"
+	"io"
+
+	"github.com/gopherjs/gopherjs/js"
+	"github.com/omustardo/tetris/tetris-server/protocol"
+)
+
+// wsConn is a browser WebSocket, receiving each update in its own text frame.
+type wsConn struct {
+	ws *js.Object
+	// received gets each update as it arrives, and is closed along with the
+	// connection.
+	received chan protocol.Update
+	err      error
+}
+
+// dial connects to a feed over WebSocket, at an address like
+// "ws://localhost:7780/watch".
+func dial(addr string) (conn, error) {
+	if js.Global.Get("WebSocket") == js.Undefined {
+		return nil, errors.New("WebSocket is not available")
+	}
+	c := &wsConn{
+		ws:       js.Global.Get("WebSocket").New(addr),
+		received: make(chan protocol.Update, receiveBuffer),
+	}
+	c.ws.Call("addEventListener", "message", func(event *js.Object) {
+		var u protocol.Update
+		if err := json.Unmarshal([]byte(event.Get("data").String()), &u); err != nil {
+			c.err = err
+			c.ws.Call("close")
+			return
+		}
+		// Callbacks from JavaScript mustn't block, so if updates aren't
+		// being read fast enough the connection is given up on.
+		select {
+		case c.received <- u:
+		default:
+			c.err = errors.New("too many updates waiting to be read")
+			c.ws.Call("close")
+		}
+	})
+	c.ws.Call("addEventListener", "close", func() {
+		close(c.received)
+	})
+	return c, nil
+}
+
+func (c *wsConn) Receive() (protocol.Update, error) {
+	u, ok := <-c.received
+	if !ok {
+		if c.err != nil {
+			return protocol.Update{}, c.err
+		}
+		return protocol.Update{}, io.EOF
+	}
+	return u, nil
+}
+
+func (c *wsConn) Close() error {
+	c.ws.Call("close")
+	return nil
+}
@@ -0,0 +1,20 @@
+//go:build js
+// +build js
+
+package spectator
+
+import (
+	"errors"
+
+	"github.com/omustardo/tetris/webgl-tetris/gamestate"
+)
+
+// Publisher would show games to spectators, but pages in the browser can't
+// listen for connections.
+type Publisher struct{}
+
+func Publish(addr string) (*Publisher, error) {
+	return nil, errors.New("games in the browser can't be served to spectators")
+}
+
+func (p *Publisher) Show(game int, name string, s *gamestate.State) {}
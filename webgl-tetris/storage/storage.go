@@ -0,0 +1,61 @@
+//go:build !js
+// +build !js
+
+// Package storage persists small blobs of data between runs of the game, like
+// personal bests. On desktop each blob is a file in the user's config
+// directory. In the browser they're kept in localStorage instead.
+package storage
+
+import (
+	"os"
+	"path/filepath"
+)
+
+// dir is the directory within the user's config directory that files are
+// kept in.
+const dir = "omustardo-tetris"
+
+func path(name string) (string, error) {
+	config, err := os.UserConfigDir()
+	if err != nil {
+		return "", err
+	}
+	return filepath.Join(config, dir, name), nil
+}
+
+// Load returns the data saved under name, or nil if nothing has been saved.
+func Load(name string) ([]byte, error) {
+	p, err := path(name)
+	if err != nil {
+		return nil, err
+	}
+	data, err := os.ReadFile(p)
+	if os.IsNotExist(err) {
+		return nil, nil
+	}
+	return data, err
+}
+
+// Save stores data under name, replacing anything saved there before.
+func Save(name string, data []byte) error {
+	p, err := path(name)
+	if err != nil {
+		return err
+	}
+	if err := os.MkdirAll(filepath.Dir(p), 0755); err != nil {
+		return err
+	}
+	return os.WriteFile(p, data, 0644)
+}
+
+// Delete removes anything saved under name.
+func Delete(name string) error {
+	p, err := path(name)
+	if err != nil {
+		return err
+	}
+	if err := os.Remove(p); err != nil && !os.IsNotExist(err) {
+		return err
+	}
+	return nil
+}
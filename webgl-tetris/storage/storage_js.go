@@ -0,0 +1,57 @@
+//go:build js
+// +build js
+
+package storage
+
+import (
+	"encoding/base64"
+	"errors"
+
+	"github.com/gopherjs/gopherjs/js"
+)
+
+// keyPrefix namespaces keys in localStorage, which is shared by everything
+// served from the same origin.
+const keyPrefix = "omustardo-tetris/"
+
+func localStorage() (*js.Object, error) {
+	ls := js.Global.Get("localStorage")
+	if ls == js.Undefined || ls == nil {
+		return nil, errors.New("localStorage is not available")
+	}
+	return ls, nil
+}
+
+// Load returns the data saved under name, or nil if nothing has been saved.
+func Load(name string) ([]byte, error) {
+	ls, err := localStorage()
+	if err != nil {
+		return nil, err
+	}
+	item := ls.Call("getItem", keyPrefix+name)
+	if item == nil {
+		return nil, nil
+	}
+	// localStorage only holds strings, so binary data is stored as base64.
+	return base64.StdEncoding.DecodeString(item.String())
+}
+
+// Save stores data under name, replacing anything saved there before.
+func Save(name string, data []byte) error {
+	ls, err := localStorage()
+	if err != nil {
+		return err
+	}
+	ls.Call("setItem", keyPrefix+name, base64.StdEncoding.EncodeToString(data))
+	return nil
+}
+
+// Delete removes anything saved under name.
+func Delete(name string) error {
+	ls, err := localStorage()
+	if err != nil {
+		return err
+	}
+	ls.Call("removeItem", keyPrefix+name)
+	return nil
+}
@@ -11,10 +11,29 @@ type Point struct {
 	X, Y float32
 }
 
+// Kind identifies which of the seven shapes a Shape is, by the letter that it
+// looks like. S and Z are the exception: S is shaped like the piece that the
+// guideline, and most other games, call Z, and Z like the one they call S.
+type Kind byte
+
+const (
+	I Kind = 'I'
+	J Kind = 'J'
+	L Kind = 'L'
+	O Kind = 'O'
+	S Kind = 'S'
+	T Kind = 'T'
+	Z Kind = 'Z'
+)
+
+// Kinds lists every kind of shape.
+var Kinds = []Kind{L, J, I, S, Z, O, T}
+
 type Shape struct {
 	R, G, B, A float32
 	points     [][]bool // All points that make up this shape.
 	origin     Point    // Used as origin for all of the other points. Should be set based on the parent board.
+	kind       Kind     // Which of the seven shapes this is.
 }
 
 // Rotate 90 degrees:
@@ -42,10 +61,56 @@ func (s *Shape) Points() [][]bool {
 func (s *Shape) Color() (R, G, B, A float32) {
 	return s.R, s.G, s.B, s.A
 }
+func (s *Shape) Kind() Kind {
+	return s.kind
+}
+
+// Copy returns a shape that can be moved and rotated without affecting s.
+func (s *Shape) Copy() *Shape {
+	c := *s
+	c.points = make([][]bool, len(s.points))
+	for i := range s.points {
+		c.points[i] = append([]bool(nil), s.points[i]...)
+	}
+	return &c
+}
+
+// GarbageColor is the color of rows pushed onto the board from below, rather
+// than built out of pieces.
+func GarbageColor() (R, G, B, A float32) {
+  return 0.5, 0.5, 0.5, 1.0
+}
+
+// NewShape returns a new shape of the given kind, or nil if there's no such
+// kind.
+func NewShape(kind Kind) *Shape {
+  switch kind {
+  case I:
+    return NewLineShape()
+  case J:
+    return NewJShape()
+  case L:
+    return NewLShape()
+  case O:
+    return NewOShape()
+  case S:
+    return NewSShape()
+  case T:
+    return NewTShape()
+  case Z:
+    return NewZShape()
+  }
+  return nil
+}
+
+// RandomKind returns one of the seven kinds of shape, picked using r.
+func RandomKind(r *rand.Rand) Kind {
+  return Kinds[r.Intn(len(Kinds))]
+}
 
-func NewRandomShape() *Shape {
-  shapes := []func()*Shape{NewLShape, NewJShape, NewLineShape, NewSShape, NewZShape, NewOShape, NewTShape}
-  return shapes[rand.Intn(len(shapes))]()
+// NewRandomShape returns one of the seven shapes, picked using r.
+func NewRandomShape(r *rand.Rand) *Shape {
+  return NewShape(RandomKind(r))
 }
 
 // #
@@ -61,6 +126,7 @@ func NewLShape() *Shape {
     R: 0, G: 1, B: 0.2, A: 1,
     points: points,
     origin: Point{0, 0},
+    kind:   L,
   }
 }
 
@@ -77,6 +143,7 @@ func NewJShape() *Shape {
     R: 0, G: 1, B: 0.2, A: 1,
     points: points,
     origin: Point{0, 0},
+    kind:   J,
   }
 }
 
@@ -96,6 +163,7 @@ func NewLineShape() *Shape {
     R: 1, G: 0.2, B: 0, A: 1,
     points: points,
     origin: Point{0, 0},
+    kind:   I,
   }
 }
 
@@ -111,6 +179,7 @@ func NewSShape() *Shape {
     R: 0.2, G: 0.2, B: 0.7, A: 1,
     points: points,
     origin: Point{0, 0},
+    kind:   S,
   }
 }
 
@@ -126,6 +195,7 @@ func NewZShape() *Shape {
     R: 0.7, G: 0.2, B: 0.2, A: 1,
     points: points,
     origin: Point{0, 0},
+    kind:   Z,
   }
 }
 
@@ -140,6 +210,7 @@ func NewOShape() *Shape {
     R: 0.7, G: 0.7, B: 0.7, A: 1,
     points: points,
     origin: Point{0, 0},
+    kind:   O,
   }
 }
 
@@ -155,6 +226,7 @@ func NewTShape() *Shape {
     R: 0.7, G: 0.2, B: 0.2, A: 1,
     points: points,
     origin: Point{0, 0},
+    kind:   T,
   }
 }
 
@@ -0,0 +1,163 @@
+// Package versus runs a game between two players on their own boards, where
+// clearing lines sends garbage over to the other player. The first to top out
+// loses.
+package versus
+
+import (
+	"fmt"
+	"time"
+
+	"github.com/omustardo/tetris/webgl-tetris/gamestate"
+)
+
+// Match is a series of versus games between the same two players. Both get
+// the same pieces, so only how they play decides who wins.
+type Match struct {
+	Players [2]*gamestate.State
+	wins    [2]int
+	winner  int // Index of the player who won the current game, or -1.
+}
+
+// New creates a match where both players have boards with the given config.
+func New(config gamestate.Config) *Match {
+	if config.Seed == 0 {
+		config.Seed = time.Now().UnixNano()
+	}
+	m := &Match{winner: -1}
+	for i := range m.Players {
+		m.Players[i] = gamestate.NewState(config)
+		m.Players[i].SetMode(&player{match: m, index: i})
+	}
+	return m
+}
+
+// Seed returns the seed that both players' games use.
+func (m *Match) Seed() int64 {
+	return m.Players[0].Seed()
+}
+
+// Over returns whether one of the players has won the current game.
+func (m *Match) Over() bool {
+	return m.winner >= 0
+}
+
+// Apply each player's input for this tick. Either player can pause or
+// restart the game for both of them.
+func (m *Match) Apply(inputs [2]gamestate.Input) {
+	pause, restart := false, false
+	for _, in := range inputs {
+		pause = pause || in.Pause
+		restart = restart || in.Restart
+	}
+	if restart {
+		m.Restart()
+		return
+	}
+	// Both games are always paused together, so toggling each keeps them
+	// in step.
+	for i, in := range inputs {
+		in.Pause, in.Restart = pause, false
+		m.Players[i].Apply(in)
+	}
+}
+
+// Tick advances both players' games.
+func (m *Match) Tick() {
+	for _, p := range m.Players {
+		p.Tick()
+	}
+}
+
+// Pause both players' games.
+func (m *Match) Pause() {
+	for _, p := range m.Players {
+		p.Pause()
+	}
+}
+
+// Record records both players' games, from now on. See State.Record.
+func (m *Match) Record() {
+	for _, p := range m.Players {
+		p.Record()
+	}
+}
+
+// Restart starts a new game for both players. The number of games each has
+// won carries over.
+func (m *Match) Restart() {
+	m.winner = -1
+	for _, p := range m.Players {
+		p.Restart()
+	}
+}
+
+// SetAttack changes how garbage is sent and received by both players.
+func (m *Match) SetAttack(a gamestate.Attack) {
+	for _, p := range m.Players {
+		p.SetAttack(a)
+	}
+}
+
+// send queues garbage for the opponent of player i.
+func (m *Match) send(i, rows int) {
+	m.Players[1-i].ReceiveGarbage(rows)
+}
+
+// lose ends the game with player i losing.
+func (m *Match) lose(i int) {
+	if m.Over() {
+		return
+	}
+	m.winner = 1 - i
+	m.wins[m.winner]++
+	m.Players[m.winner].End()
+}
+
+// player is the mode of each player's game, which connects it to the match.
+type player struct {
+	match *Match
+	index int
+	sent  int // Rows of garbage sent this game.
+}
+
+func (p *player) Start(s *gamestate.State) {
+	p.sent = 0
+}
+
+func (p *player) Tick(s *gamestate.State) {}
+
+func (p *player) HandleEvent(s *gamestate.State, e gamestate.Event) {
+	switch e.Type {
+	case gamestate.PieceLocked:
+		if e.Attack > 0 {
+			p.sent += e.Attack
+			p.match.send(p.index, e.Attack)
+		}
+	case gamestate.GameEnded:
+		if s.ToppedOut() {
+			p.match.lose(p.index)
+		}
+	}
+}
+
+func (p *player) HUD(s *gamestate.State) []string {
+	return []string{
+		fmt.Sprintf("PLAYER %d", p.index+1),
+		fmt.Sprintf("WINS %d", p.match.wins[p.index]),
+		fmt.Sprintf("SENT %d INCOMING %d", p.sent, s.IncomingGarbage()),
+	}
+}
+
+func (p *player) Results(s *gamestate.State) []string {
+	results := []string{fmt.Sprintf("PLAYER %d", p.index+1)}
+	if p.match.winner == p.index {
+		results = append(results, "YOU WIN!")
+	} else {
+		results = append(results, "YOU LOSE")
+	}
+	return append(results,
+		fmt.Sprintf("SENT %d", p.sent),
+		fmt.Sprintf("WINS %d - %d", p.match.wins[p.index], p.match.wins[1-p.index]),
+		"R FOR REMATCH",
+	)
+}